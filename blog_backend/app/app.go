@@ -3,61 +3,387 @@ package app
 import (
 	"blog_backend/app/config"
 	"blog_backend/app/controller"
+	"blog_backend/app/health"
+	"blog_backend/app/jobs"
+	"blog_backend/app/keyring"
+	"blog_backend/app/mail"
+	"blog_backend/app/metrics"
+	"blog_backend/app/ratelimit"
 	"blog_backend/app/repository"
 	"blog_backend/app/routes"
 	"blog_backend/app/services"
+	"blog_backend/app/tracing"
 	"blog_backend/app/utils"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"gorm.io/gorm"
 )
 
+var errShuttingDown = errors.New("shutting down")
+
+// keyRotationInterval is how often the signing keys are rotated, and those
+// other instances created picked up
+const keyRotationInterval = time.Minute
+
 type App struct {
-	cfg *config.Config
+	cfg    *config.Config
+	db     *gorm.DB
+	logger *slog.Logger
 
 	router *gin.Engine
 
-	authController    *controller.AuthController
-	postController    *controller.PostController
-	commentController *controller.CommentController
+	authController     *controller.AuthController
+	postController     *controller.PostController
+	commentController  *controller.CommentController
+	userController     *controller.UserController
+	searchController   *controller.SearchController
+	taxonomyController *controller.TaxonomyController
+	healthController   *controller.HealthController
+
+	postPublisher *jobs.PostPublisher
+	keyRotator    *jobs.KeyRotator
+	metrics       *metrics.Metrics
+
+	// draining is set once shutdown starts, failing the readiness probe
+	draining atomic.Bool
+	// shutdownTracing flushes the spans not exported yet
+	shutdownTracing func(context.Context) error
+	// redis holds the rate limit buckets when they are shared
+	redis *redis.Client
 }
 
-func NewApp(cfg *config.Config) (*App, error) {
+// Deps are the collaborators App is built from. NewApp connects them to the
+// configured database; tests inject their own.
+type Deps struct {
+	Repos *repository.Repositories
+	// DB is the connection behind Repos, if any. Its health is reported by
+	// /readyz and App closes it on shutdown.
+	DB *gorm.DB
+	// Logger receives the access logs and everything else App logs; nil
+	// uses slog.Default().
+	Logger *slog.Logger
+	// TracerProvider traces requests, services and queries; nil disables
+	// tracing.
+	TracerProvider trace.TracerProvider
+	// RateLimitStore keeps the rate limit buckets when rate limiting is
+	// enabled; nil keeps them in process.
+	RateLimitStore ratelimit.Store
+	// Mailer sends the verification and password reset emails; nil uses
+	// the configured MailTransport.
+	Mailer mail.Mailer
+}
 
-	router := gin.Default()
+func NewApp(cfg *config.Config, logger *slog.Logger) (*App, error) {
+	tracerProvider, shutdownTracing, err := tracing.NewProvider(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rate limit buckets shared with the other instances
+	var rateLimitStore ratelimit.Store
+	var redisClient *redis.Client
+	if cfg.RateLimitEnabled && cfg.RateLimitStore == "redis" {
+		opts, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			shutdownTracing(context.Background())
+			return nil, fmt.Errorf("invalid redis URL: %w", err)
+		}
+		redisClient = redis.NewClient(opts)
+		rateLimitStore = ratelimit.NewRedisStore(redisClient)
+	}
+	closeRedis := func() {
+		if redisClient != nil {
+			redisClient.Close()
+		}
+	}
 
 	// Initialize database connection
-	db, err := utils.InitDatabase(cfg)
+	db, err := utils.InitDatabase(cfg, logger)
 	if err != nil {
+		closeRedis()
+		shutdownTracing(context.Background())
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
-	// Set up Repositories and Services
-	userRepo := repository.NewUserRepository(db)
-	postRepo := repository.NewPostRepository(db)
-	commentRepo := repository.NewCommentRepository(db)
+	app, err := NewAppWithDeps(cfg, Deps{
+		Repos:          repository.NewGormRepositories(db),
+		DB:             db,
+		Logger:         logger,
+		TracerProvider: tracerProvider,
+		RateLimitStore: rateLimitStore,
+	})
+	if err != nil {
+		if sqlDB, dbErr := db.DB(); dbErr == nil {
+			sqlDB.Close()
+		}
+		closeRedis()
+		shutdownTracing(context.Background())
+		return nil, err
+	}
+	app.shutdownTracing = shutdownTracing
+	app.redis = redisClient
+	return app, nil
+}
+
+// NewAppWithDeps wires the services, controllers and routes on top of deps.
+func NewAppWithDeps(cfg *config.Config, deps Deps) (*App, error) {
 
-	authService := services.NewAuthService(cfg, userRepo)
-	postService := services.NewPostService(postRepo)
-	commentService := services.NewCommentService(commentRepo)
+	logger := deps.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	tracerProvider := deps.TracerProvider
+	if tracerProvider == nil {
+		tracerProvider = noop.NewTracerProvider()
+	}
+	// Requests are logged and recovered by our own middleware
+	router := gin.New()
+	if err := router.SetTrustedProxies(splitList(cfg.TrustedProxies)); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	repos := deps.Repos
+	a := &App{cfg: cfg, db: deps.DB, logger: logger, router: router, metrics: metrics.New()}
+
+	// Readiness checks
+	checks := []health.Check{{Name: "server", Check: a.checkServing}}
+	if deps.DB != nil {
+		migrationCheck, err := health.Migrations(deps.DB)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, health.Database(deps.DB), migrationCheck)
+		if err := a.metrics.InstrumentDB(deps.DB, cfg.Database.DBname); err != nil {
+			return nil, err
+		}
+		if err := tracing.InstrumentDB(deps.DB, tracerProvider); err != nil {
+			return nil, err
+		}
+	}
+
+	// Emails
+	mailer := deps.Mailer
+	if mailer == nil {
+		var err error
+		if mailer, err = mail.New(cfg); err != nil {
+			return nil, err
+		}
+	}
+	mailTemplates, err := mail.NewTemplates(cfg.MailTemplateDir, cfg.MailFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	// Signing keys, loaded before anything is signed with them
+	keys := keyring.New()
+	signingKeyService := services.NewSigningKeyService(cfg, repos.SigningKeys, keys)
+	if _, err := signingKeyService.RotateKeys(context.Background(), time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	// Set up Services
+	authService := services.NewTracedAuthService(
+		services.NewAuthService(cfg, repos.Tx, keys, repos.Users, repos.RefreshTokens, repos.LoginAttempts, repos.UserTokens,
+			repos.RecoveryCodes, repos.SIWENonces, repos.PersonalAccessTokens, mailer, mailTemplates, a.metrics),
+		tracerProvider)
+	postService := services.NewTracedPostService(
+		services.NewPostService(repos.Tx, repos.Posts, repos.Tags, repos.Categories, repos.Revisions, a.metrics),
+		tracerProvider)
+	commentService := services.NewTracedCommentService(
+		services.NewCommentService(repos.Tx, repos.Comments, repos.Posts, repos.Revisions, cfg.CommentMaxDepth, a.metrics),
+		tracerProvider)
+	userService := services.NewUserService(repos.Tx, repos.Users, repos.RefreshTokens, repos.RecoveryCodes,
+		repos.PersonalAccessTokens)
+	searchService := services.NewSearchService(repos.Search)
+	taxonomyService := services.NewTaxonomyService(repos.Tx, repos.Tags, repos.Categories)
+
+	// Background workers
+	a.postPublisher = jobs.NewPostPublisher(postService, cfg.PublishInterval, logger)
+	a.keyRotator = jobs.NewKeyRotator(signingKeyService, keyRotationInterval, logger)
 
 	// Initialize Controllers
-	authController := controller.NewAuthController(authService)
-	postController := controller.NewPostController(postService)
-	commentController := controller.NewCommentController(commentService)
+	a.authController = controller.NewAuthController(authService)
+	a.postController = controller.NewPostController(postService)
+	a.commentController = controller.NewCommentController(commentService)
+	a.userController = controller.NewUserController(userService)
+	a.searchController = controller.NewSearchController(searchService)
+	a.taxonomyController = controller.NewTaxonomyController(taxonomyService, postService)
+	a.healthController = controller.NewHealthController(checks)
+
+	// Rate limiting
+	rateLimits, err := ratelimit.ParseRules(cfg.RateLimitRoutes, cfg.RateLimitDefault)
+	if err != nil {
+		return nil, err
+	}
+	var rateLimitStore ratelimit.Store
+	if cfg.RateLimitEnabled {
+		rateLimitStore = deps.RateLimitStore
+		if rateLimitStore == nil {
+			rateLimitStore = ratelimit.NewMemoryStore()
+		}
+	}
 
 	// Set up routes
-	routes.SetupRoutes(cfg, router, authController, postController, commentController)
+	routes.SetupRoutes(cfg, router, authService, a.authController, a.postController, a.commentController,
+		a.userController, a.searchController, a.taxonomyController, a.healthController, logger, a.metrics, tracerProvider,
+		rateLimits, rateLimitStore)
+
+	return a, nil
+}
 
-	return &App{
-		cfg:               cfg,
-		router:            router,
-		authController:    authController,
-		postController:    postController,
-		commentController: commentController,
-	}, nil
+// Handler is the HTTP handler serving the API, for use with httptest.
+func (a *App) Handler() http.Handler {
+	return a.router
 }
 
+// Run serves on addr until SIGTERM or SIGINT, then shuts down gracefully.
 func (a *App) Run(addr string) error {
-	return a.router.Run(":" + addr)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	return a.Serve(ctx, addr)
+}
+
+// Serve serves on addr, and the metrics on the admin port if one is
+// configured, until ctx is done. It then fails the readiness probe, waits
+// ShutdownDelay, drains in-flight requests for up to ShutdownTimeout, stops
+// the background workers and closes the database.
+func (a *App) Serve(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", ":"+addr)
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to listen on port %s: %w", addr, err), a.release(context.Background()))
+	}
+	return a.serve(ctx, listener)
+}
+
+// serve is Serve on a listener that is already open.
+func (a *App) serve(ctx context.Context, listener net.Listener) error {
+	api := a.newServer("", a.router)
+	api.Addr = listener.Addr().String()
+	servers := []*http.Server{api}
+	if a.cfg.MetricsPort != "" {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("GET /metrics", a.metrics.Handler())
+		servers = append(servers, a.newServer(a.cfg.MetricsPort, adminMux))
+	}
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		a.postPublisher.Run(workerCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		a.keyRotator.Run(workerCtx)
+	}()
+
+	serveErr := make(chan error, len(servers))
+	for _, server := range servers {
+		go func() {
+			if server == api {
+				serveErr <- server.Serve(listener)
+				return
+			}
+			serveErr <- server.ListenAndServe()
+		}()
+		a.logger.Info("server listening", "addr", server.Addr)
+	}
+
+	var err error
+	select {
+	case err = <-serveErr:
+		// A listener failed, typically because its port is taken; the
+		// other servers are shut down below
+	case <-ctx.Done():
+		a.logger.Info("shutting down", "delay", a.cfg.ShutdownDelay, "timeout", a.cfg.ShutdownTimeout)
+		a.draining.Store(true)
+		time.Sleep(a.cfg.ShutdownDelay)
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+	defer cancel()
+	for _, server := range servers {
+		if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to drain requests: %w", shutdownErr))
+			server.Close()
+		}
+	}
+
+	// Let the workers finish their current pass before the database goes
+	stopWorkers()
+	workers.Wait()
+	return errors.Join(err, a.release(shutdownCtx))
+}
+
+// release flushes the traces and closes the connections the app opened.
+func (a *App) release(ctx context.Context) error {
+	var err error
+	if a.shutdownTracing != nil {
+		if tracingErr := a.shutdownTracing(ctx); tracingErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to flush traces: %w", tracingErr))
+		}
+	}
+	if a.redis != nil {
+		if redisErr := a.redis.Close(); redisErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close redis: %w", redisErr))
+		}
+	}
+	return errors.Join(err, a.closeDB())
+}
+
+func (a *App) newServer(port string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + port,
+		Handler:           handler,
+		ReadHeaderTimeout: a.cfg.ReadHeaderTimeout,
+		ReadTimeout:       a.cfg.ReadTimeout,
+		WriteTimeout:      a.cfg.WriteTimeout,
+		IdleTimeout:       a.cfg.IdleTimeout,
+	}
+}
+
+func (a *App) checkServing(context.Context) error {
+	if a.draining.Load() {
+		return errShuttingDown
+	}
+	return nil
+}
+
+func (a *App) closeDB() error {
+	if a.db == nil {
+		return nil
+	}
+	sqlDB, err := a.db.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated setting, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
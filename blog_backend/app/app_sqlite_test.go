@@ -0,0 +1,43 @@
+//go:build cgo && sqlite_fts5
+
+package app
+
+import (
+	"blog_backend/app/config"
+	"blog_backend/app/logging"
+	"blog_backend/app/repository"
+	"blog_backend/app/utils"
+	"blog_backend/migrations"
+	"path/filepath"
+	"testing"
+)
+
+// TestAPISQLite runs the API tests on migrated SQLite databases, which
+// exercises the GORM repositories. The migrations create FTS5 tables, hence
+// the build tag: go test -tags sqlite_fts5 ./app
+func TestAPISQLite(t *testing.T) {
+	runAPITests(t, func(t *testing.T) Deps {
+		cfg := &config.Config{Database: config.DatabaseConfig{
+			Driver:   "sqlite",
+			DBname:   filepath.Join(t.TempDir(), "blog.db"),
+			TimeZone: "UTC",
+		}}
+		db, err := utils.InitDatabase(cfg, logging.Discard())
+		if err != nil {
+			t.Fatalf("InitDatabase: %v", err)
+		}
+		t.Cleanup(func() {
+			if sqlDB, err := db.DB(); err == nil {
+				sqlDB.Close()
+			}
+		})
+		migrator, err := migrations.NewMigrator(db)
+		if err != nil {
+			t.Fatalf("NewMigrator: %v", err)
+		}
+		if _, err := migrator.Up(); err != nil {
+			t.Fatalf("migrate up: %v", err)
+		}
+		return Deps{Repos: repository.NewGormRepositories(db), DB: db, Logger: logging.Discard()}
+	})
+}
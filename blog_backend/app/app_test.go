@@ -0,0 +1,1391 @@
+package app
+
+import (
+	"blog_backend/app/config"
+	"blog_backend/app/keyring"
+	"blog_backend/app/logging"
+	"blog_backend/app/mail"
+	"blog_backend/app/models"
+	"blog_backend/app/repository"
+	"blog_backend/app/siwe"
+	"blog_backend/app/tracing"
+	"blog_backend/app/utils"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pquerna/otp/totp"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+}
+
+// apiTests run against every repository implementation.
+var apiTests = []struct {
+	name string
+	run  func(t *testing.T, env *testEnv)
+}{
+	{"RegisterAndLogin", testRegisterAndLogin},
+	{"ConcurrentFailedLogins", testConcurrentFailedLogins},
+	{"RefreshRotation", testRefreshRotation},
+	{"PostCRUD", testPostCRUD},
+	{"DraftVisibility", testDraftVisibility},
+	{"PostValidation", testPostValidation},
+	{"CommentThreads", testCommentThreads},
+	{"MissingRevisions", testMissingRevisions},
+	{"Search", testSearch},
+	{"PostAuthorization", testPostAuthorization},
+	{"CommentAuthorization", testCommentAuthorization},
+	{"RoleManagement", testRoleManagement},
+	{"EmailVerification", testEmailVerification},
+	{"PasswordReset", testPasswordReset},
+	{"TwoFactorAuth", testTwoFactorAuth},
+	{"SignInWithEthereum", testSignInWithEthereum},
+	{"PersonalAccessTokens", testPersonalAccessTokens},
+	{"SigningKeys", testSigningKeys},
+	{"Probes", testProbes},
+	{"Metrics", testMetrics},
+	{"Tracing", testTracing},
+}
+
+func TestAPIMemory(t *testing.T) {
+	runAPITests(t, func(t *testing.T) Deps {
+		return memoryDeps()
+	})
+}
+
+func runAPITests(t *testing.T, newDeps func(t *testing.T) Deps) {
+	for _, tc := range apiTests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newTestEnv(t, newDeps(t)))
+		})
+	}
+}
+
+func memoryDeps() Deps {
+	return Deps{Repos: repository.NewMemoryRepositories(repository.NewMemoryStore()), Logger: logging.Discard()}
+}
+
+// testEnv is a running server on fresh repositories.
+type testEnv struct {
+	t      *testing.T
+	server *httptest.Server
+	repos  *repository.Repositories
+	// withDB is set when the app runs on a real database
+	withDB bool
+	// spans holds the spans the app ended
+	spans *tracetest.InMemoryExporter
+	// mail holds the emails the app sent
+	mail *mail.MemoryMailer
+}
+
+func newTestEnv(t *testing.T, deps Deps) *testEnv {
+	return newTestEnvWithConfig(t, testConfig(), deps)
+}
+
+func newTestEnvWithConfig(t *testing.T, cfg *config.Config, deps Deps) *testEnv {
+	provider, spans := tracing.NewTestProvider()
+	deps.TracerProvider = provider
+	mailer := mail.NewMemoryMailer()
+	deps.Mailer = mailer
+	app, err := NewAppWithDeps(cfg, deps)
+	if err != nil {
+		t.Fatalf("NewAppWithDeps: %v", err)
+	}
+	server := httptest.NewServer(app.Handler())
+	t.Cleanup(server.Close)
+	return &testEnv{t: t, server: server, repos: deps.Repos, withDB: deps.DB != nil, spans: spans, mail: mailer}
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		JWTSecret:       "test-secret",
+		JWTAlgorithm:    "EdDSA",
+		JWTIssuer:       "blog_backend",
+		JWTAudience:     "blog_backend",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: time.Hour,
+		PublishInterval: time.Minute,
+		CommentMaxDepth: 5,
+		ShutdownTimeout: 5 * time.Second,
+		MetricsToken:    "metrics-token",
+		AppURL:          "https://blog.example.com",
+		MailTransport:   "memory",
+		MailFrom:        "Blog <no-reply@blog.example.com>",
+
+		EmailVerificationTTL: time.Hour,
+		PasswordResetTTL:     time.Hour,
+		MFAIssuer:            "Blog",
+		MFAChallengeTTL:      5 * time.Minute,
+		SIWEChainIDs:         "1,10",
+		SIWENonceTTL:         10 * time.Minute,
+		JWTKeyRotation:       720 * time.Hour,
+		JWTKeyPrepublish:     24 * time.Hour,
+	}
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   map[string]any
+}
+
+// do sends body as JSON, authenticated with token when it is not empty.
+func (e *testEnv) do(method, path, token string, body any) response {
+	e.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			e.t.Fatalf("marshal request: %v", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, e.server.URL+path, reader)
+	if err != nil {
+		e.t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := e.server.Client().Do(req)
+	if err != nil {
+		e.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	r := response{status: resp.StatusCode, header: resp.Header, body: map[string]any{}}
+	if err := json.NewDecoder(resp.Body).Decode(&r.body); err != nil && err != io.EOF {
+		e.t.Fatalf("%s %s: decode response: %v", method, path, err)
+	}
+	return r
+}
+
+// expect fails the test unless r has the given status and, for errors, the
+// given envelope code.
+func (e *testEnv) expect(r response, status int, code string) {
+	e.t.Helper()
+	if r.status != status {
+		e.t.Fatalf("got status %d, want %d: %v", r.status, status, r.body)
+	}
+	if code != "" && r.body["code"] != code {
+		e.t.Fatalf("got code %v, want %s: %v", r.body["code"], code, r.body)
+	}
+}
+
+// signUp registers a user, verifies their email address and returns their
+// id and an access token.
+func (e *testEnv) signUp(name string) (int, string) {
+	e.t.Helper()
+	id := e.register(name)
+	r := e.do("POST", "/user/verify", "", map[string]any{"token": e.mailedToken(name, "/verify-email")})
+	e.expect(r, http.StatusOK, "")
+	return id, e.login(name)
+}
+
+// register registers a user without verifying their email address.
+func (e *testEnv) register(name string) int {
+	e.t.Helper()
+	r := e.do("POST", "/user/register", "", map[string]any{
+		"username": name,
+		"email":    name + "@example.com",
+		"password": "password123",
+	})
+	e.expect(r, http.StatusOK, "")
+	return int(r.body["user_id"].(float64))
+}
+
+var mailedLink = regexp.MustCompile(`https://blog\.example\.com(/[a-z-]+)\?token=([A-Za-z0-9_-]+)`)
+
+// mailedToken returns the token of the last link to path emailed to name.
+func (e *testEnv) mailedToken(name, path string) string {
+	e.t.Helper()
+	messages := e.mail.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].To != name+"@example.com" {
+			continue
+		}
+		if match := mailedLink.FindStringSubmatch(messages[i].Text); match != nil && match[1] == path {
+			return match[2]
+		}
+	}
+	e.t.Fatalf("no email to %s with a link to %s", name, path)
+	return ""
+}
+
+func (e *testEnv) login(name string) string {
+	e.t.Helper()
+	r := e.do("POST", "/user/login", "", map[string]any{
+		"email":    name + "@example.com",
+		"password": "password123",
+	})
+	e.expect(r, http.StatusOK, "")
+	return r.body["token"].(string)
+}
+
+// createAccessToken creates a personal access token with the scopes.
+func (e *testEnv) createAccessToken(session string, scopes ...string) string {
+	e.t.Helper()
+	r := e.do("POST", "/user/tokens", session, map[string]any{"name": "ci", "scopes": scopes})
+	e.expect(r, http.StatusOK, "")
+	token := r.body["token"].(string)
+	if !strings.HasPrefix(token, "blog_pat_") {
+		e.t.Fatalf("unexpected token %s", token)
+	}
+	return token
+}
+
+// grantRole gives a user a role behind the API's back and returns a token
+// carrying it.
+func (e *testEnv) grantRole(name string, id int, role models.Role) string {
+	e.t.Helper()
+	if _, err := e.repos.Users.UpdateUserRole(context.Background(), id, role); err != nil {
+		e.t.Fatalf("UpdateUserRole: %v", err)
+	}
+	return e.login(name)
+}
+
+func (e *testEnv) createPost(token string, body map[string]any) map[string]any {
+	e.t.Helper()
+	r := e.do("POST", "/post/", token, body)
+	e.expect(r, http.StatusOK, "")
+	return r.body["post_item"].(map[string]any)
+}
+
+func (e *testEnv) createComment(token string, userID int, postID int, parentID any, content string) map[string]any {
+	e.t.Helper()
+	r := e.do("POST", "/comment/", token, map[string]any{
+		"post_id":   postID,
+		"user_id":   userID,
+		"parent_id": parentID,
+		"content":   content,
+	})
+	e.expect(r, http.StatusOK, "")
+	return r.body["comment_item"].(map[string]any)
+}
+
+func id(item map[string]any, key string) int {
+	return int(item[key].(float64))
+}
+
+func testRegisterAndLogin(t *testing.T, env *testEnv) {
+	_, token := env.signUp("alice")
+
+	r := env.do("GET", "/user/profile", token, nil)
+	env.expect(r, http.StatusOK, "")
+	if r.body["email"] != "alice@example.com" || r.body["role"] != "user" {
+		t.Fatalf("unexpected profile %v", r.body)
+	}
+
+	r = env.do("POST", "/user/register", "", map[string]any{
+		"username": "alice2", "email": "alice@example.com", "password": "password123",
+	})
+	env.expect(r, http.StatusConflict, "conflict")
+
+	r = env.do("POST", "/user/login", "", map[string]any{"email": "alice@example.com", "password": "wrong-password"})
+	env.expect(r, http.StatusUnauthorized, "unauthorized")
+
+	env.expect(env.do("GET", "/user/profile", "", nil), http.StatusUnauthorized, "unauthorized")
+	env.expect(env.do("GET", "/user/profile", "not-a-token", nil), http.StatusUnauthorized, "unauthorized")
+}
+
+// testConcurrentFailedLogins fails logins at once right after a success
+// cleared the counter of the account, so that they race to create it.
+func testConcurrentFailedLogins(t *testing.T, env *testEnv) {
+	env.signUp("alice")
+	statuses := make(chan int, 4)
+	for range cap(statuses) {
+		go func() {
+			body := strings.NewReader(`{"email":"alice@example.com","password":"wrong-password"}`)
+			resp, err := env.server.Client().Post(env.server.URL+"/user/login", "application/json", body)
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	for range cap(statuses) {
+		if status := <-statuses; status != http.StatusUnauthorized {
+			t.Fatalf("got status %d, want 401", status)
+		}
+	}
+	// Every failure was counted, so the fifth locks the account
+	r := env.do("POST", "/user/login", "", map[string]any{"email": "alice@example.com", "password": "wrong-password"})
+	env.expect(r, http.StatusUnauthorized, "unauthorized")
+	r = env.do("POST", "/user/login", "", map[string]any{"email": "alice@example.com", "password": "password123"})
+	env.expect(r, http.StatusLocked, "")
+}
+
+func testRefreshRotation(t *testing.T, env *testEnv) {
+	env.signUp("alice")
+	r := env.do("POST", "/user/login", "", map[string]any{"email": "alice@example.com", "password": "password123"})
+	env.expect(r, http.StatusOK, "")
+	first := r.body["refresh_token"].(string)
+
+	r = env.do("POST", "/user/refresh", "", map[string]any{"refresh_token": first})
+	env.expect(r, http.StatusOK, "")
+	second := r.body["refresh_token"].(string)
+	access := r.body["token"].(string)
+	env.expect(env.do("GET", "/user/profile", access, nil), http.StatusOK, "")
+
+	// Replaying a rotated token ends the session
+	env.expect(env.do("POST", "/user/refresh", "", map[string]any{"refresh_token": first}), http.StatusUnauthorized, "unauthorized")
+	env.expect(env.do("POST", "/user/refresh", "", map[string]any{"refresh_token": second}), http.StatusUnauthorized, "unauthorized")
+	env.expect(env.do("GET", "/user/profile", access, nil), http.StatusUnauthorized, "unauthorized")
+}
+
+func testPostCRUD(t *testing.T, env *testEnv) {
+	aliceID, token := env.signUp("alice")
+
+	post := env.createPost(token, map[string]any{
+		"title": "First post", "content": "Hello from the test suite", "tags": []string{"Go", "Testing"},
+	})
+	postID := id(post, "post_id")
+	if post["status"] != "published" || fmt.Sprint(post["tags"]) != "[Go Testing]" {
+		t.Fatalf("unexpected post %v", post)
+	}
+
+	r := env.do("GET", fmt.Sprintf("/post/%d", postID), "", nil)
+	env.expect(r, http.StatusOK, "")
+
+	r = env.do("PUT", fmt.Sprintf("/post/%d", postID), token, map[string]any{
+		"post_id": postID, "title": "First post, edited", "content": "Edited content here", "tags": []string{"go"},
+	})
+	env.expect(r, http.StatusOK, "")
+	updated := r.body["post_item"].(map[string]any)
+	if updated["title"] != "First post, edited" || fmt.Sprint(updated["tags"]) != "[Go]" {
+		t.Fatalf("unexpected updated post %v", updated)
+	}
+
+	r = env.do("GET", "/post", "", nil)
+	env.expect(r, http.StatusOK, "")
+	if r.body["total"] != float64(1) {
+		t.Fatalf("got %v posts, want 1", r.body["total"])
+	}
+	r = env.do("GET", "/tag/go/posts", "", nil)
+	env.expect(r, http.StatusOK, "")
+	if r.body["total"] != float64(1) {
+		t.Fatalf("got %v posts tagged go, want 1", r.body["total"])
+	}
+
+	r = env.do("GET", fmt.Sprintf("/post/%d/revisions", postID), token, nil)
+	env.expect(r, http.StatusOK, "")
+
+	commentID := id(env.createComment(token, aliceID, postID, nil, "A comment that goes with the post"), "id")
+	env.expect(env.do("DELETE", fmt.Sprintf("/post/%d", postID), token, nil), http.StatusOK, "")
+	env.expect(env.do("GET", fmt.Sprintf("/post/%d", postID), "", nil), http.StatusNotFound, "not_found")
+
+	// The history goes with the post and its comments
+	postRevisions, err := env.repos.Revisions.ListPostRevisions(context.Background(), postID)
+	if err != nil || len(postRevisions) != 0 {
+		t.Fatalf("got %d post revisions after delete, want 0 (%v)", len(postRevisions), err)
+	}
+	commentRevisions, err := env.repos.Revisions.ListCommentRevisions(context.Background(), commentID)
+	if err != nil || len(commentRevisions) != 0 {
+		t.Fatalf("got %d comment revisions after delete, want 0 (%v)", len(commentRevisions), err)
+	}
+
+	user, err := env.repos.Users.RetriveUser(context.Background(), &models.User{ID: aliceID})
+	if err != nil {
+		t.Fatalf("RetriveUser: %v", err)
+	}
+	if user.NumberOfPosts != 0 {
+		t.Fatalf("got %d posts counted after delete, want 0", user.NumberOfPosts)
+	}
+}
+
+func testDraftVisibility(t *testing.T, env *testEnv) {
+	_, alice := env.signUp("alice")
+	_, bob := env.signUp("bob")
+
+	draft := env.createPost(alice, map[string]any{
+		"title": "Draft", "content": "Not ready for readers", "status": "draft",
+	})
+	path := fmt.Sprintf("/post/%d", id(draft, "post_id"))
+
+	env.expect(env.do("GET", path, "", nil), http.StatusNotFound, "not_found")
+	env.expect(env.do("GET", path, bob, nil), http.StatusNotFound, "not_found")
+	env.expect(env.do("GET", path, alice, nil), http.StatusOK, "")
+
+	r := env.do("GET", "/post", "", nil)
+	env.expect(r, http.StatusOK, "")
+	if r.body["total"] != float64(0) {
+		t.Fatalf("draft listed publicly: %v", r.body)
+	}
+
+	// Comments stay with their post once it is archived
+	aliceID := id(draft, "user_id")
+	archived := env.createPost(alice, map[string]any{
+		"title": "Archived", "content": "Read while it lasted", "tags": []string{"Retired"},
+	})
+	comment := env.createComment(alice, aliceID, id(archived, "post_id"), nil, "First!")
+	r = env.do("PUT", fmt.Sprintf("/post/%d", id(archived, "post_id")), alice, map[string]any{
+		"post_id": id(archived, "post_id"), "title": "Archived", "content": "Read while it lasted", "status": "archived",
+	})
+	env.expect(r, http.StatusOK, "")
+	path = fmt.Sprintf("/comment/%d", id(comment, "id"))
+	env.expect(env.do("GET", path, "", nil), http.StatusNotFound, "not_found")
+	env.expect(env.do("GET", path, bob, nil), http.StatusNotFound, "not_found")
+	env.expect(env.do("GET", path, alice, nil), http.StatusOK, "")
+
+	// So do the tags only unpublished posts carry
+	env.createPost(alice, map[string]any{"title": "Tagged draft", "content": "Not ready for readers", "status": "draft",
+		"tags": []string{"Secret"}})
+	r = env.do("GET", "/tag", "", nil)
+	env.expect(r, http.StatusOK, "")
+	if tags := r.body["tags"].([]any); len(tags) != 0 {
+		t.Fatalf("tags of unpublished posts listed: %v", tags)
+	}
+}
+
+func testPostValidation(t *testing.T, env *testEnv) {
+	_, token := env.signUp("alice")
+
+	r := env.do("POST", "/post/", token, map[string]any{"title": "Hi", "content": "short"})
+	env.expect(r, http.StatusBadRequest, "validation_failed")
+	details := r.body["details"].([]any)
+	if len(details) != 2 {
+		t.Fatalf("got %d field errors, want 2: %v", len(details), details)
+	}
+
+	r = env.do("POST", "/post/", token, map[string]any{
+		"title": "Categorised", "content": "In a category that does not exist", "category_id": 42,
+	})
+	env.expect(r, http.StatusBadRequest, "validation_failed")
+
+	env.expect(env.do("POST", "/post/", "", map[string]any{}), http.StatusUnauthorized, "unauthorized")
+	env.expect(env.do("GET", "/no/such/route", "", nil), http.StatusNotFound, "not_found")
+}
+
+func testCommentThreads(t *testing.T, env *testEnv) {
+	aliceID, alice := env.signUp("alice")
+	bobID, bob := env.signUp("bob")
+	postID := id(env.createPost(alice, map[string]any{"title": "Threads", "content": "Discuss below please"}), "post_id")
+
+	root := env.createComment(bob, bobID, postID, nil, "First!")
+	reply := env.createComment(alice, aliceID, postID, id(root, "id"), "Welcome")
+	if reply["depth"] != float64(1) || reply["path"] != fmt.Sprintf("%s/%010d", root["path"], id(reply, "id")) {
+		t.Fatalf("unexpected reply %v", reply)
+	}
+
+	r := env.do("GET", fmt.Sprintf("/comment/post/%d?mode=tree", postID), "", nil)
+	env.expect(r, http.StatusOK, "")
+	threads := r.body["threads"].([]any)
+	if len(threads) != 1 || len(threads[0].(map[string]any)["replies"].([]any)) != 1 {
+		t.Fatalf("unexpected threads %v", threads)
+	}
+
+	// Deleting a comment with replies leaves a placeholder
+	rootPath := fmt.Sprintf("/comment/%d", id(root, "id"))
+	env.expect(env.do("DELETE", rootPath, bob, nil), http.StatusOK, "")
+	r = env.do("GET", rootPath, "", nil)
+	env.expect(r, http.StatusOK, "")
+	if r.body["comment_item"].(map[string]any)["deleted"] != true {
+		t.Fatalf("expected a placeholder, got %v", r.body)
+	}
+	if revisions, _ := env.repos.Revisions.ListCommentRevisions(context.Background(), id(root, "id")); len(revisions) != 0 {
+		t.Fatalf("got %d revisions of a deleted comment, want 0", len(revisions))
+	}
+	r = env.do("POST", "/comment/", bob, map[string]any{
+		"post_id": postID, "user_id": bobID, "parent_id": id(root, "id"), "content": "Too late",
+	})
+	env.expect(r, http.StatusConflict, "conflict")
+
+	// and the placeholder goes with its last reply
+	env.expect(env.do("DELETE", fmt.Sprintf("/comment/%d", id(reply, "id")), alice, nil), http.StatusOK, "")
+	env.expect(env.do("GET", rootPath, "", nil), http.StatusNotFound, "not_found")
+	if revisions, _ := env.repos.Revisions.ListCommentRevisions(context.Background(), id(reply, "id")); len(revisions) != 0 {
+		t.Fatalf("got %d revisions of a deleted comment, want 0", len(revisions))
+	}
+}
+
+func testMissingRevisions(t *testing.T, env *testEnv) {
+	aliceID, alice := env.signUp("alice")
+	postID := id(env.createPost(alice, map[string]any{"title": "Revised", "content": "Only one revision so far"}), "post_id")
+	commentID := id(env.createComment(alice, aliceID, postID, nil, "Only one revision"), "id")
+
+	for _, base := range []string{fmt.Sprintf("/post/%d", postID), fmt.Sprintf("/comment/%d", commentID)} {
+		env.expect(env.do("GET", base+"/revisions/1", alice, nil), http.StatusOK, "")
+		env.expect(env.do("GET", base+"/revisions/999", alice, nil), http.StatusNotFound, "not_found")
+		env.expect(env.do("POST", base+"/revisions/999/restore", alice, nil), http.StatusNotFound, "not_found")
+		env.expect(env.do("GET", base+"/diff?from=1&to=999", alice, nil), http.StatusNotFound, "not_found")
+		env.expect(env.do("GET", base+"/diff?from=999&to=1", alice, nil), http.StatusNotFound, "not_found")
+	}
+}
+
+func testSearch(t *testing.T, env *testEnv) {
+	aliceID, alice := env.signUp("alice")
+	titled := env.createPost(alice, map[string]any{"status": "published", "title": "Goroutines explained",
+		"content": "How goroutines and channels work together, with <script>alert(1)</script> inline."})
+	mentioned := env.createPost(alice, map[string]any{"status": "published", "title": "Buffered channels",
+		"content": "Buffered channels decouple the goroutines on either side."})
+	env.createPost(alice, map[string]any{"status": "draft", "title": "Goroutines draft",
+		"content": "Unpublished notes on goroutines."})
+	comment := env.createComment(alice, aliceID, id(titled, "post_id"), nil, "Great write-up on goroutines")
+
+	search := func(query string) []map[string]any {
+		t.Helper()
+		r := env.do("GET", "/search?"+query, "", nil)
+		env.expect(r, http.StatusOK, "")
+		var results []map[string]any
+		for _, result := range r.body["results"].([]any) {
+			results = append(results, result.(map[string]any))
+		}
+		return results
+	}
+	kinds := func(results []map[string]any) []string {
+		var got []string
+		for _, result := range results {
+			got = append(got, fmt.Sprintf("%s %d", result["type"], id(result, "id")))
+		}
+		return got
+	}
+	post := func(item map[string]any) string { return fmt.Sprintf("post %d", id(item, "post_id")) }
+
+	// Drafts are left out, and a match in the title ranks first
+	results := search("q=goroutines&type=post")
+	if got, want := kinds(results), []string{post(titled), post(mentioned)}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// Snippets are HTML with the text escaped
+	snippet := results[0]["snippet"].(string)
+	if strings.Contains(snippet, "<script>") || !strings.Contains(snippet, "&lt;script&gt;") {
+		t.Fatalf("snippet is not escaped: %s", snippet)
+	}
+	if env.withDB && !strings.Contains(snippet, "<mark>goroutines</mark>") {
+		t.Fatalf("snippet is not highlighted: %s", snippet)
+	}
+
+	if got, want := kinds(search("q="+url.QueryEscape("goroutines -buffered")+"&type=post")), []string{post(titled)}; !slices.Equal(got, want) {
+		t.Fatalf("excluding: got %v, want %v", got, want)
+	}
+	if got, want := kinds(search("q="+url.QueryEscape(`"channels work"`))), []string{post(titled)}; !slices.Equal(got, want) {
+		t.Fatalf("phrase: got %v, want %v", got, want)
+	}
+	if got, want := kinds(search("q=goroutines&type=comment")), []string{fmt.Sprintf("comment %d", id(comment, "id"))}; !slices.Equal(got, want) {
+		t.Fatalf("comments: got %v, want %v", got, want)
+	}
+}
+
+func testPostAuthorization(t *testing.T, env *testEnv) {
+	_, alice := env.signUp("alice")
+	bobID, bob := env.signUp("bob")
+	postID := id(env.createPost(alice, map[string]any{"title": "Mine", "content": "Only I may change this"}), "post_id")
+	path := fmt.Sprintf("/post/%d", postID)
+	edit := map[string]any{"post_id": postID, "title": "Hijacked", "content": "Somebody else wrote this"}
+
+	env.expect(env.do("PUT", path, bob, edit), http.StatusForbidden, "forbidden")
+	env.expect(env.do("DELETE", path, bob, nil), http.StatusForbidden, "forbidden")
+	env.expect(env.do("GET", path+"/revisions", bob, nil), http.StatusForbidden, "forbidden")
+
+	// Moderators handle comments, not posts
+	moderator := env.grantRole("bob", bobID, models.RoleModerator)
+	env.expect(env.do("DELETE", path, moderator, nil), http.StatusForbidden, "forbidden")
+
+	admin := env.grantRole("bob", bobID, models.RoleAdmin)
+	env.expect(env.do("PUT", path, admin, edit), http.StatusOK, "")
+	env.expect(env.do("DELETE", path, admin, nil), http.StatusOK, "")
+}
+
+func testCommentAuthorization(t *testing.T, env *testEnv) {
+	aliceID, alice := env.signUp("alice")
+	bobID, bob := env.signUp("bob")
+	postID := id(env.createPost(alice, map[string]any{"title": "Open", "content": "Comments welcome here"}), "post_id")
+	comment := env.createComment(alice, aliceID, postID, nil, "My own comment")
+	path := fmt.Sprintf("/comment/%d", id(comment, "id"))
+
+	env.expect(env.do("PUT", path, bob, map[string]any{"content": "Edited by bob"}), http.StatusForbidden, "forbidden")
+	env.expect(env.do("DELETE", path, bob, nil), http.StatusForbidden, "forbidden")
+
+	moderator := env.grantRole("bob", bobID, models.RoleModerator)
+	env.expect(env.do("PUT", path, moderator, map[string]any{"content": "Edited by bob"}), http.StatusForbidden, "forbidden")
+	env.expect(env.do("DELETE", path, moderator, nil), http.StatusOK, "")
+}
+
+func testRoleManagement(t *testing.T, env *testEnv) {
+	aliceID, alice := env.signUp("alice")
+	bobID, _ := env.signUp("bob")
+	path := fmt.Sprintf("/admin/user/%d/role", bobID)
+
+	env.expect(env.do("PUT", path, alice, map[string]any{"role": "admin"}), http.StatusForbidden, "forbidden")
+
+	admin := env.grantRole("alice", aliceID, models.RoleAdmin)
+	env.expect(env.do("PUT", path, admin, map[string]any{"role": "root"}), http.StatusBadRequest, "validation_failed")
+	env.expect(env.do("PUT", "/admin/user/999/role", admin, map[string]any{"role": "moderator"}), http.StatusNotFound, "not_found")
+
+	r := env.do("PUT", path, admin, map[string]any{"role": "moderator"})
+	env.expect(r, http.StatusOK, "")
+	if r.body["role"] != "moderator" {
+		t.Fatalf("unexpected response %v", r.body)
+	}
+}
+
+func testEmailVerification(t *testing.T, env *testEnv) {
+	env.register("alice")
+	messages := env.mail.Messages()
+	if len(messages) != 1 || messages[0].Subject != "Verify your email address" ||
+		messages[0].From != "Blog <no-reply@blog.example.com>" || !strings.Contains(messages[0].Text, "Hi alice,") {
+		t.Fatalf("unexpected verification emails %+v", messages)
+	}
+	first := env.mailedToken("alice", "/verify-email")
+
+	// Unverified users can log in and read, but not write
+	token := env.login("alice")
+	r := env.do("GET", "/user/profile", token, nil)
+	env.expect(r, http.StatusOK, "")
+	if r.body["email_verified"] != false {
+		t.Fatalf("unexpected profile %v", r.body)
+	}
+	env.expect(env.do("GET", "/post", token, nil), http.StatusOK, "")
+	r = env.do("POST", "/post/", token, map[string]any{"title": "Spam", "content": "Buy now, buy now"})
+	env.expect(r, http.StatusForbidden, "forbidden")
+	if r.body["message"] != "email address is not verified" {
+		t.Fatalf("unexpected error %v", r.body)
+	}
+
+	// A new email replaces the link of the previous one
+	env.expect(env.do("POST", "/user/verify/resend", token, nil), http.StatusOK, "")
+	second := env.mailedToken("alice", "/verify-email")
+	env.expect(env.do("POST", "/user/verify", "", map[string]any{"token": first}), http.StatusBadRequest, "validation_failed")
+	env.expect(env.do("POST", "/user/verify", "", map[string]any{"token": second}), http.StatusOK, "")
+	env.expect(env.do("POST", "/user/verify", "", map[string]any{"token": second}), http.StatusBadRequest, "validation_failed")
+	env.expect(env.do("POST", "/user/verify/resend", token, nil), http.StatusConflict, "conflict")
+
+	// Tokens issued from now on carry the verification
+	token = env.login("alice")
+	env.createPost(token, map[string]any{"title": "Verified", "content": "Now I may write"})
+}
+
+func testPasswordReset(t *testing.T, env *testEnv) {
+	_, session := env.signUp("alice")
+	sent := len(env.mail.Messages())
+
+	// Unknown addresses get the same answer and no email
+	r := env.do("POST", "/user/password/forgot", "", map[string]any{"email": "nobody@example.com"})
+	env.expect(r, http.StatusOK, "")
+	if len(env.mail.Messages()) != sent {
+		t.Fatal("an email was sent to an unknown address")
+	}
+
+	accessToken := env.createAccessToken(session, "read")
+	env.expect(env.do("POST", "/user/password/forgot", "", map[string]any{"email": "Alice@Example.com"}), http.StatusOK, "")
+	reset := env.mailedToken("alice", "/reset-password")
+	if strings.Contains(env.mail.Messages()[sent].Text, "/verify-email") {
+		t.Fatal("a reset email is usable as a verification email")
+	}
+	// Tokens only serve their purpose
+	env.expect(env.do("POST", "/user/verify", "", map[string]any{"token": reset}), http.StatusBadRequest, "validation_failed")
+
+	r = env.do("POST", "/user/password/reset", "", map[string]any{"token": reset, "password": "new-password"})
+	env.expect(r, http.StatusOK, "")
+	r = env.do("POST", "/user/password/reset", "", map[string]any{"token": reset, "password": "other-password"})
+	env.expect(r, http.StatusBadRequest, "validation_failed")
+
+	// The old password, sessions and personal access tokens are gone
+	env.expect(env.do("GET", "/user/profile", session, nil), http.StatusUnauthorized, "unauthorized")
+	env.expect(env.do("GET", "/user/profile", accessToken, nil), http.StatusUnauthorized, "unauthorized")
+	r = env.do("POST", "/user/login", "", map[string]any{"email": "alice@example.com", "password": "password123"})
+	env.expect(r, http.StatusUnauthorized, "unauthorized")
+	r = env.do("POST", "/user/login", "", map[string]any{"email": "alice@example.com", "password": "new-password"})
+	env.expect(r, http.StatusOK, "")
+}
+
+func testTwoFactorAuth(t *testing.T, env *testEnv) {
+	aliceID, session := env.signUp("alice")
+	adminID, _ := env.signUp("admin")
+	adminToken := env.grantRole("admin", adminID, models.RoleAdmin)
+	credentials := map[string]any{"email": "alice@example.com", "password": "password123"}
+
+	r := env.do("POST", "/user/mfa/enroll", session, nil)
+	env.expect(r, http.StatusOK, "")
+	secret := r.body["secret"].(string)
+	if uri := r.body["otpauth_uri"].(string); !strings.HasPrefix(uri, "otpauth://totp/Blog:alice@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Fatalf("unexpected otpauth uri %s", uri)
+	}
+	if qr, err := base64.StdEncoding.DecodeString(r.body["qr_code_png"].(string)); err != nil || !bytes.HasPrefix(qr, []byte("\x89PNG")) {
+		t.Fatalf("qr code is not a PNG image: %v", err)
+	}
+	// Enrolling alone does not change logins
+	env.expect(env.do("POST", "/user/login", "", credentials), http.StatusOK, "")
+
+	// Each code is accepted once. Codes of the next time step are accepted
+	// too, so later steps use those.
+	code := func(offset time.Duration) string {
+		code, err := totp.GenerateCode(secret, time.Now().Add(offset))
+		if err != nil {
+			t.Fatalf("GenerateCode: %v", err)
+		}
+		return code
+	}
+	env.expect(env.do("POST", "/user/mfa/confirm", session, map[string]any{"code": "000000"}), http.StatusBadRequest, "validation_failed")
+	firstCode := code(0)
+	r = env.do("POST", "/user/mfa/confirm", session, map[string]any{"code": firstCode})
+	env.expect(r, http.StatusOK, "")
+	recoveryCodes := r.body["recovery_codes"].([]any)
+	if len(recoveryCodes) != 10 {
+		t.Fatalf("got %d recovery codes, want 10", len(recoveryCodes))
+	}
+	env.expect(env.do("POST", "/user/mfa/enroll", session, nil), http.StatusConflict, "conflict")
+
+	// The password alone now only gets a challenge
+	challenge := func() string {
+		t.Helper()
+		r := env.do("POST", "/user/login", "", credentials)
+		env.expect(r, http.StatusOK, "")
+		if r.body["mfa_required"] != true || r.body["token"] != nil {
+			t.Fatalf("login was not challenged: %v", r.body)
+		}
+		return r.body["mfa_token"].(string)
+	}
+	mfaToken := challenge()
+	r = env.do("POST", "/user/login/mfa", "", map[string]any{"mfa_token": mfaToken, "code": firstCode})
+	env.expect(r, http.StatusBadRequest, "validation_failed")
+	r = env.do("POST", "/user/login/mfa", "", map[string]any{"mfa_token": mfaToken, "code": code(30 * time.Second)})
+	env.expect(r, http.StatusOK, "")
+	env.expect(env.do("GET", "/user/profile", r.body["token"].(string), nil), http.StatusOK, "")
+	r = env.do("POST", "/user/login/mfa", "", map[string]any{"mfa_token": mfaToken, "code": code(30 * time.Second)})
+	env.expect(r, http.StatusUnauthorized, "unauthorized")
+
+	// Recovery codes stand in for a code once, with or without dashes
+	recoveryCode := strings.ToUpper(strings.ReplaceAll(recoveryCodes[0].(string), "-", ""))
+	r = env.do("POST", "/user/login/mfa", "", map[string]any{"mfa_token": challenge(), "code": recoveryCode})
+	env.expect(r, http.StatusOK, "")
+	r = env.do("POST", "/user/login/mfa", "", map[string]any{"mfa_token": challenge(), "code": recoveryCode})
+	env.expect(r, http.StatusBadRequest, "validation_failed")
+
+	// Only admins reset it, which ends the sessions of the user and deletes
+	// their personal access tokens
+	accessToken := env.createAccessToken(session, "read")
+	env.expect(env.do("DELETE", fmt.Sprintf("/admin/user/%d/mfa", aliceID), session, nil), http.StatusForbidden, "forbidden")
+	env.expect(env.do("DELETE", fmt.Sprintf("/admin/user/%d/mfa", aliceID), adminToken, nil), http.StatusOK, "")
+	env.expect(env.do("GET", "/user/profile", session, nil), http.StatusUnauthorized, "unauthorized")
+	env.expect(env.do("GET", "/user/profile", accessToken, nil), http.StatusUnauthorized, "unauthorized")
+	r = env.do("POST", "/user/login", "", credentials)
+	env.expect(r, http.StatusOK, "")
+	session = r.body["token"].(string)
+	env.expect(env.do("POST", "/user/mfa/disable", session, map[string]any{"code": code(0)}), http.StatusConflict, "conflict")
+	env.expect(env.do("DELETE", "/admin/user/999/mfa", adminToken, nil), http.StatusNotFound, "not_found")
+}
+
+func testSignInWithEthereum(t *testing.T, env *testEnv) {
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("GeneratePrivateKey: %v", err)
+	}
+
+	// signed writes the message a wallet would for a fresh nonce, and signs
+	// it after edit had its way with it
+	signed := func(key *secp256k1.PrivateKey, edit func(m *siwe.Message)) map[string]any {
+		t.Helper()
+		r := env.do("GET", "/user/siwe/nonce", "", nil)
+		env.expect(r, http.StatusOK, "")
+		if r.body["domain"] != "blog.example.com" || fmt.Sprint(r.body["chain_ids"]) != "[1 10]" {
+			t.Fatalf("unexpected nonce response %v", r.body)
+		}
+		m := &siwe.Message{
+			Domain:    r.body["domain"].(string),
+			Address:   siwe.PubkeyToAddress(key.PubKey()),
+			Statement: "Sign in to the blog.",
+			URI:       "https://blog.example.com/login",
+			Version:   siwe.Version,
+			ChainID:   10,
+			Nonce:     r.body["nonce"].(string),
+			IssuedAt:  time.Now().UTC().Truncate(time.Second),
+		}
+		if edit != nil {
+			edit(m)
+		}
+		sig, err := siwe.Sign(siwe.TextHash([]byte(m.String())), key)
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		sig[64] += 27
+		return map[string]any{"message": m.String(), "signature": fmt.Sprintf("0x%x", sig)}
+	}
+
+	// The first sign-in creates an account named after the wallet
+	request := signed(key, nil)
+	r := env.do("POST", "/user/siwe/verify", "", request)
+	env.expect(r, http.StatusOK, "")
+	r = env.do("GET", "/user/profile", r.body["token"].(string), nil)
+	env.expect(r, http.StatusOK, "")
+	walletUserID := r.body["userId"]
+	if r.body["email_verified"] != true {
+		t.Fatalf("wallet account is not verified: %v", r.body)
+	}
+	// A nonce is only good once
+	env.expect(env.do("POST", "/user/siwe/verify", "", request), http.StatusUnauthorized, "unauthorized")
+	r = env.do("POST", "/user/siwe/verify", "", signed(key, nil))
+	env.expect(r, http.StatusOK, "")
+	if r = env.do("GET", "/user/profile", r.body["token"].(string), nil); r.body["userId"] != walletUserID {
+		t.Fatalf("signed in as user %v, want %v", r.body["userId"], walletUserID)
+	}
+
+	for name, edit := range map[string]func(m *siwe.Message){
+		"other domain":  func(m *siwe.Message) { m.Domain = "evil.example.com" },
+		"other chain":   func(m *siwe.Message) { m.ChainID = 5 },
+		"unknown nonce": func(m *siwe.Message) { m.Nonce = "0123456789abcdef" },
+		"expired": func(m *siwe.Message) {
+			expired := time.Now().Add(-time.Minute)
+			m.ExpirationTime = &expired
+		},
+	} {
+		r := env.do("POST", "/user/siwe/verify", "", signed(key, edit))
+		if r.status != http.StatusUnauthorized {
+			t.Errorf("%s: got status %d", name, r.status)
+		}
+	}
+	request = signed(key, nil)
+	otherKey, _ := secp256k1.GeneratePrivateKey()
+	request["signature"] = signed(otherKey, nil)["signature"]
+	env.expect(env.do("POST", "/user/siwe/verify", "", request), http.StatusUnauthorized, "unauthorized")
+	request["message"] = "Sign in please"
+	env.expect(env.do("POST", "/user/siwe/verify", "", request), http.StatusBadRequest, "validation_failed")
+
+	// Existing accounts link a wallet to sign in with it, but only one
+	// account may have a wallet
+	aliceID, session := env.signUp("alice")
+	env.expect(env.do("POST", "/user/siwe/link", "", signed(otherKey, nil)), http.StatusUnauthorized, "unauthorized")
+	env.expect(env.do("POST", "/user/siwe/link", session, signed(key, nil)), http.StatusConflict, "conflict")
+	r = env.do("POST", "/user/siwe/link", session, signed(otherKey, nil))
+	env.expect(r, http.StatusOK, "")
+	if r.body["wallet_address"] != siwe.PubkeyToAddress(otherKey.PubKey()).Hex() {
+		t.Fatalf("unexpected link response %v", r.body)
+	}
+	r = env.do("POST", "/user/siwe/verify", "", signed(otherKey, nil))
+	env.expect(r, http.StatusOK, "")
+	if r = env.do("GET", "/user/profile", r.body["token"].(string), nil); r.body["userId"] != float64(aliceID) {
+		t.Fatalf("signed in as user %v, want %d", r.body["userId"], aliceID)
+	}
+}
+
+func testPersonalAccessTokens(t *testing.T, env *testEnv) {
+	aliceID, session := env.signUp("alice")
+	adminID, _ := env.signUp("admin")
+	adminSession := env.grantRole("admin", adminID, models.RoleAdmin)
+	writer := env.createAccessToken(session, "posts:write", "posts:write")
+	reader := env.createAccessToken(session, "read")
+
+	// Each token is limited to its scopes
+	post := env.createPost(writer, map[string]any{"title": "From CI", "content": "Published by a script", "status": "draft"})
+	postID := id(post, "post_id")
+	if id(post, "user_id") != aliceID {
+		t.Fatalf("post is by user %v, want %d", post["user_id"], aliceID)
+	}
+	env.expect(env.do("GET", "/user/profile", writer, nil), http.StatusForbidden, "forbidden")
+	env.expect(env.do("POST", "/comment/", writer, map[string]any{"post_id": postID, "user_id": aliceID, "content": "Nice"}),
+		http.StatusForbidden, "forbidden")
+	env.expect(env.do("POST", "/post/", reader, map[string]any{"title": "From CI", "content": "Published by a script"}),
+		http.StatusForbidden, "forbidden")
+	env.expect(env.do("GET", "/user/profile", reader, nil), http.StatusOK, "")
+	// Reading drafts needs the read scope too
+	env.expect(env.do("GET", fmt.Sprintf("/post/%d", postID), writer, nil), http.StatusNotFound, "not_found")
+	env.expect(env.do("GET", fmt.Sprintf("/post/%d", postID), reader, nil), http.StatusOK, "")
+
+	// Managing the account takes a login session, whatever the scopes
+	everything := env.createAccessToken(adminSession, "read", "posts:write", "comments:write")
+	env.expect(env.do("POST", "/user/tokens", everything, map[string]any{"name": "more", "scopes": []string{"read"}}),
+		http.StatusForbidden, "forbidden")
+	env.expect(env.do("PUT", fmt.Sprintf("/admin/user/%d/role", aliceID), everything, map[string]any{"role": "moderator"}),
+		http.StatusForbidden, "forbidden")
+
+	env.expect(env.do("POST", "/user/tokens", session, map[string]any{"name": "ci", "scopes": []string{"admin"}}),
+		http.StatusBadRequest, "validation_failed")
+	env.expect(env.do("POST", "/user/tokens", session, map[string]any{"name": "ci", "scopes": []string{"read"},
+		"expires_at": time.Now().Add(-time.Hour).Format(time.RFC3339)}), http.StatusBadRequest, "validation_failed")
+	expiresAt := time.Now().Add(-time.Minute)
+	_, err := env.repos.PersonalAccessTokens.CreatePersonalAccessToken(context.Background(), &models.PersonalAccessToken{
+		UserID: aliceID, Name: "old", TokenHash: utils.HashToken("blog_pat_expired"), Scopes: "read", ExpiresAt: &expiresAt,
+	})
+	if err != nil {
+		t.Fatalf("CreatePersonalAccessToken: %v", err)
+	}
+	env.expect(env.do("GET", "/user/profile", "blog_pat_expired", nil), http.StatusUnauthorized, "unauthorized")
+
+	// A token that somehow has no scopes may make no request at all
+	_, err = env.repos.PersonalAccessTokens.CreatePersonalAccessToken(context.Background(), &models.PersonalAccessToken{
+		UserID: adminID, Name: "empty", TokenHash: utils.HashToken("blog_pat_unscoped"), Scopes: "",
+	})
+	if err != nil {
+		t.Fatalf("CreatePersonalAccessToken: %v", err)
+	}
+	env.expect(env.do("GET", "/user/profile", "blog_pat_unscoped", nil), http.StatusForbidden, "forbidden")
+	env.expect(env.do("POST", "/post/", "blog_pat_unscoped", map[string]any{"title": "Unscoped", "content": "Should never be posted"}),
+		http.StatusForbidden, "forbidden")
+	env.expect(env.do("POST", "/user/tokens", "blog_pat_unscoped", map[string]any{"name": "more", "scopes": []string{"read"}}),
+		http.StatusForbidden, "forbidden")
+	env.expect(env.do("PUT", fmt.Sprintf("/admin/user/%d/role", aliceID), "blog_pat_unscoped", map[string]any{"role": "moderator"}),
+		http.StatusForbidden, "forbidden")
+
+	// The list shows when tokens were used, newest first, but never the tokens
+	r := env.do("GET", "/user/tokens", session, nil)
+	env.expect(r, http.StatusOK, "")
+	tokens := r.body["access_tokens"].([]any)
+	if len(tokens) != 3 {
+		t.Fatalf("got %d tokens, want 3", len(tokens))
+	}
+	readItem := tokens[1].(map[string]any)
+	if readItem["last_used_at"] == nil || fmt.Sprint(readItem["scopes"]) != "[read]" || readItem["token"] != nil {
+		t.Fatalf("unexpected token item %v", readItem)
+	}
+	if fmt.Sprint(tokens[2].(map[string]any)["scopes"]) != "[posts:write]" {
+		t.Fatalf("unexpected token item %v", tokens[2])
+	}
+
+	// Revoked tokens stop working at once, and only their owner revokes them
+	tokenID := id(readItem, "token_id")
+	env.expect(env.do("DELETE", fmt.Sprintf("/user/tokens/%d", tokenID), adminSession, nil), http.StatusNotFound, "not_found")
+	env.expect(env.do("DELETE", fmt.Sprintf("/user/tokens/%d", tokenID), session, nil), http.StatusOK, "")
+	env.expect(env.do("GET", "/user/profile", reader, nil), http.StatusUnauthorized, "unauthorized")
+}
+
+func testSigningKeys(t *testing.T, env *testEnv) {
+	r := env.do("GET", "/.well-known/jwks.json", "", nil)
+	env.expect(r, http.StatusOK, "")
+	if !strings.Contains(r.header.Get("Cache-Control"), "max-age") {
+		t.Fatalf("the key set is not cacheable: %v", r.header)
+	}
+	keys := r.body["keys"].([]any)
+	if len(keys) != 1 {
+		t.Fatalf("got %d keys, want 1", len(keys))
+	}
+	jwk := keys[0].(map[string]any)
+	if jwk["kty"] != "OKP" || jwk["crv"] != "Ed25519" || jwk["alg"] != "EdDSA" || jwk["use"] != "sig" {
+		t.Fatalf("unexpected key %v", jwk)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(jwk["x"].(string))
+	if err != nil {
+		t.Fatalf("decode key: %v", err)
+	}
+
+	// Another service verifies tokens with the published key alone
+	_, session := env.signUp("alice")
+	claims := &utils.CustomClaims{}
+	_, err = jwt.ParseWithClaims(session, claims, func(token *jwt.Token) (any, error) {
+		if token.Header["kid"] != jwk["kid"] {
+			return nil, fmt.Errorf("unknown kid %v", token.Header["kid"])
+		}
+		return ed25519.PublicKey(x), nil
+	}, jwt.WithValidMethods([]string{"EdDSA"}), jwt.WithIssuer("blog_backend"), jwt.WithAudience("blog_backend"))
+	if err != nil {
+		t.Fatalf("verify with the published key: %v", err)
+	}
+	if claims.Email != "alice@example.com" {
+		t.Fatalf("token is for %s", claims.Email)
+	}
+
+	sign := func(key *keyring.Key, audience string) string {
+		t.Helper()
+		token, err := utils.CreateJWTToken(key, "blog_backend", audience, claims.UserID, claims.Email, claims.Role,
+			claims.SessionID, claims.EmailVerified, time.Minute)
+		if err != nil {
+			t.Fatalf("CreateJWTToken: %v", err)
+		}
+		return token
+	}
+	stored, err := env.repos.SigningKeys.ListSigningKeys(context.Background())
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("stored keys: %v, %v", stored, err)
+	}
+	private, err := keyring.OpenPrivateKey(stored[0].PrivateKey, stored[0].KID, "test-secret")
+	if err != nil {
+		t.Fatalf("OpenPrivateKey: %v", err)
+	}
+	signing, _ := keyring.NewKey(keyring.EdDSA, private, stored[0].ActivatesAt)
+	env.expect(env.do("GET", "/user/profile", sign(signing, "blog_backend"), nil), http.StatusOK, "")
+
+	// Tokens for another audience, or signed by an unknown key, are refused
+	env.expect(env.do("GET", "/user/profile", sign(signing, "another_service"), nil),
+		http.StatusUnauthorized, "unauthorized")
+	unknown, err := keyring.Generate(keyring.EdDSA, time.Now())
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	env.expect(env.do("GET", "/user/profile", sign(unknown, "blog_backend"), nil), http.StatusUnauthorized, "unauthorized")
+	// The public key is no HMAC secret
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	hmac.Header["kid"] = jwk["kid"]
+	forged, err := hmac.SignedString(x)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	env.expect(env.do("GET", "/user/profile", forged, nil), http.StatusUnauthorized, "unauthorized")
+}
+
+// TestSigningKeyRotation starts on keys created by earlier runs: the one
+// signing now, the one it replaced a minute ago and one replaced long ago.
+func TestSigningKeyRotation(t *testing.T) {
+	deps := memoryDeps()
+	cfg := testConfig()
+	now := time.Now()
+	var stored []*keyring.Key
+	for _, activatesAt := range []time.Time{now.Add(-60 * 24 * time.Hour), now.Add(-31 * 24 * time.Hour), now.Add(-time.Minute)} {
+		key, err := keyring.Generate(keyring.EdDSA, activatesAt)
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		sealed, err := keyring.SealPrivateKey(key, cfg.JWTSecret)
+		if err != nil {
+			t.Fatalf("SealPrivateKey: %v", err)
+		}
+		_, err = deps.Repos.SigningKeys.CreateSigningKey(context.Background(), &models.SigningKey{
+			KID: key.ID, Algorithm: key.Algorithm, PrivateKey: sealed, ActivatesAt: activatesAt,
+		})
+		if err != nil {
+			t.Fatalf("CreateSigningKey: %v", err)
+		}
+		stored = append(stored, key)
+	}
+	retired, previous, current := stored[0], stored[1], stored[2]
+
+	// The keys do not open with another secret
+	other := testConfig()
+	other.JWTSecret = "another-secret"
+	if _, err := NewAppWithDeps(other, deps); err == nil {
+		t.Fatal("started with keys it cannot decrypt")
+	}
+
+	env := newTestEnvWithConfig(t, cfg, deps)
+	r := env.do("GET", "/.well-known/jwks.json", "", nil)
+	env.expect(r, http.StatusOK, "")
+	var kids []string
+	for _, jwk := range r.body["keys"].([]any) {
+		kids = append(kids, jwk.(map[string]any)["kid"].(string))
+	}
+	if want := []string{previous.ID, current.ID}; !slices.Equal(kids, want) {
+		t.Fatalf("published %v, want %v", kids, want)
+	}
+	left, _ := deps.Repos.SigningKeys.ListSigningKeys(context.Background())
+	if len(left) != 2 || slices.ContainsFunc(left, func(k models.SigningKey) bool { return k.KID == retired.ID }) {
+		t.Fatalf("the retired key is still stored: %v", left)
+	}
+
+	// New tokens are signed by the current key, and those of the previous
+	// key stay valid until they expire
+	_, session := env.signUp("alice")
+	token, _, err := jwt.NewParser().ParseUnverified(session, &utils.CustomClaims{})
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	if token.Header["kid"] != current.ID {
+		t.Fatalf("signed by %v, want %s", token.Header["kid"], current.ID)
+	}
+	claims := token.Claims.(*utils.CustomClaims)
+	old, err := utils.CreateJWTToken(previous, cfg.JWTIssuer, cfg.JWTAudience, claims.UserID, claims.Email, claims.Role,
+		claims.SessionID, claims.EmailVerified, time.Minute)
+	if err != nil {
+		t.Fatalf("CreateJWTToken: %v", err)
+	}
+	env.expect(env.do("GET", "/user/profile", old, nil), http.StatusOK, "")
+}
+
+func testProbes(t *testing.T, env *testEnv) {
+	env.expect(env.do("GET", "/healthz", "", nil), http.StatusOK, "")
+
+	r := env.do("GET", "/readyz", "", nil)
+	env.expect(r, http.StatusOK, "")
+	checks := r.body["checks"].(map[string]any)
+	for name, result := range checks {
+		if result != "ok" {
+			t.Fatalf("check %s failed: %v", name, result)
+		}
+	}
+	if checks["server"] == nil {
+		t.Fatalf("server check missing: %v", checks)
+	}
+}
+
+func TestReadyzFailsWhileDraining(t *testing.T) {
+	app, err := NewAppWithDeps(testConfig(), memoryDeps())
+	if err != nil {
+		t.Fatalf("NewAppWithDeps: %v", err)
+	}
+	app.draining.Store(true)
+
+	rec := httptest.NewRecorder()
+	app.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d, want 503: %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	app.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("liveness failed while draining: %d", rec.Code)
+	}
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	cfg := testConfig()
+	// Fail fast instead of waiting out a connection the drain cannot close
+	cfg.ShutdownTimeout = 2 * time.Second
+	app, err := NewAppWithDeps(cfg, memoryDeps())
+	if err != nil {
+		t.Fatalf("NewAppWithDeps: %v", err)
+	}
+	started := make(chan struct{})
+	app.router.GET("/slow", func(c *gin.Context) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- app.serve(ctx, listener) }()
+
+	// A connection of its own per request, so that no idle one is left
+	// behind for the drain to wait on
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	defer client.CloseIdleConnections()
+	url := "http://" + listener.Addr().String()
+	resp, err := client.Get(url + "/healthz")
+	if err != nil {
+		t.Fatalf("healthz: %v", err)
+	}
+	resp.Body.Close()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := client.Get(url + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		raw, _ := io.ReadAll(resp.Body)
+		body <- string(raw)
+	}()
+	<-started
+	cancel()
+
+	if got := <-body; got != "done" {
+		t.Fatalf("in-flight request got %q, want done", got)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	if _, err := client.Get(url + "/healthz"); err == nil {
+		t.Fatal("server still accepts connections after shutdown")
+	}
+}
+
+func TestAccessLogs(t *testing.T) {
+	var logs bytes.Buffer
+	logger, err := logging.New(&logs, "info", "json")
+	if err != nil {
+		t.Fatalf("logging.New: %v", err)
+	}
+	deps := memoryDeps()
+	deps.Logger = logger
+	env := newTestEnv(t, deps)
+
+	_, token := env.signUp("alice")
+	req, _ := http.NewRequest("GET", env.server.URL+"/user/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Request-ID", "trace-123")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /user/profile: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Request-ID"); got != "trace-123" {
+		t.Fatalf("X-Request-ID = %q, want the forwarded ID", got)
+	}
+
+	var profileLog map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var record map[string]any
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("log line is not JSON: %s", line)
+		}
+		if record["route"] == "/user/profile" {
+			profileLog = record
+		}
+	}
+	if profileLog == nil {
+		t.Fatalf("no access log for /user/profile in:\n%s", logs.String())
+	}
+	if profileLog["request_id"] != "trace-123" || profileLog["status"] != float64(200) || profileLog["user_id"] == nil {
+		t.Fatalf("unexpected access log: %v", profileLog)
+	}
+	for _, secret := range []string{"password123", token} {
+		if bytes.Contains(logs.Bytes(), []byte(secret)) {
+			t.Fatalf("logs contain a secret:\n%s", logs.String())
+		}
+	}
+}
+
+func testMetrics(t *testing.T, env *testEnv) {
+	scrape := func(token string) (int, string) {
+		req, _ := http.NewRequest("GET", env.server.URL+"/metrics", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := env.server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("GET /metrics: %v", err)
+		}
+		defer resp.Body.Close()
+		raw, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(raw)
+	}
+	if status, _ := scrape(""); status != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated scrape got %d", status)
+	}
+	if status, _ := scrape("wrong"); status != http.StatusUnauthorized {
+		t.Fatalf("scrape with a wrong token got %d", status)
+	}
+
+	userID, token := env.signUp("alice")
+	env.expect(env.do("POST", "/user/login", "", map[string]any{
+		"email":    "alice@example.com",
+		"password": "wrong-password",
+	}), http.StatusUnauthorized, "unauthorized")
+	post := env.createPost(token, map[string]any{"title": "Metrics", "content": "Counting things."})
+	env.createComment(token, userID, id(post, "post_id"), nil, "Counted.")
+	env.do("GET", "/post/"+strconv.Itoa(id(post, "post_id")), "", nil)
+
+	status, body := scrape("metrics-token")
+	if status != http.StatusOK {
+		t.Fatalf("scrape got %d: %s", status, body)
+	}
+	want := []string{
+		"blog_user_registrations_total 1",
+		"blog_logins_total 1",
+		`blog_login_failures_total{reason="invalid_credentials"} 1`,
+		`blog_login_failures_total{reason="locked_out"} 0`,
+		"blog_posts_created_total 1",
+		"blog_comments_created_total 1",
+		`blog_http_request_duration_seconds_count{method="GET",route="/post/:post_id",status="200"} 1`,
+		`blog_http_request_duration_seconds_count{method="POST",route="/user/login",status="401"} 1`,
+	}
+	if env.withDB {
+		want = append(want, `blog_db_query_duration_seconds_count{operation="create",table="posts"} 1`, "go_sql_open_connections")
+	}
+	for _, line := range want {
+		if !strings.Contains(body, line) {
+			t.Errorf("metrics lack %q", line)
+		}
+	}
+}
+
+func testTracing(t *testing.T, env *testEnv) {
+	_, token := env.signUp("alice")
+	post := env.createPost(token, map[string]any{"title": "Traced", "content": "Follow this request."})
+	env.spans.Reset()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, _ := http.NewRequest("GET", env.server.URL+"/post/"+strconv.Itoa(id(post, "post_id")), nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := env.server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET /post/:post_id: %v", err)
+	}
+	resp.Body.Close()
+
+	byName := map[string]tracetest.SpanStub{}
+	for _, span := range env.spans.GetSpans() {
+		if span.SpanContext.TraceID().String() != traceID {
+			t.Fatalf("span %q is not part of the incoming trace", span.Name)
+		}
+		byName[span.Name] = span
+	}
+	server, ok := byName["GET /post/:post_id"]
+	if !ok {
+		t.Fatalf("no server span among %v", spanNames(byName))
+	}
+	if server.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("server span parent = %s, want the incoming span", server.Parent.SpanID())
+	}
+	service, ok := byName["PostService.RetrievePost"]
+	if !ok || service.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Fatalf("no service span under the server span among %v", spanNames(byName))
+	}
+	if env.withDB {
+		query, ok := byName["SELECT posts"]
+		if !ok || query.Parent.SpanID() != service.SpanContext.SpanID() {
+			t.Fatalf("no query span under the service span among %v", spanNames(byName))
+		}
+	}
+}
+
+func spanNames(spans map[string]tracetest.SpanStub) []string {
+	names := []string{}
+	for name := range spans {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestRateLimits(t *testing.T) {
+	cfg := testConfig()
+	cfg.RateLimitEnabled = true
+	cfg.RateLimitRoutes = "POST /user/login=2/1m,POST /comment/=1/1m"
+	env := newTestEnvWithConfig(t, cfg, memoryDeps())
+
+	// Logins are limited per IP, and both users log in from the same one
+	aliceID, alice := env.signUp("alice")
+	bobID, bob := env.signUp("bob")
+	r := env.do("POST", "/user/login", "", map[string]any{"email": "alice@example.com", "password": "password123"})
+	env.expect(r, http.StatusTooManyRequests, "too_many_requests")
+	if r.header.Get("RateLimit-Limit") != "2" || r.header.Get("RateLimit-Remaining") != "0" ||
+		r.header.Get("RateLimit-Policy") != "2;w=60" {
+		t.Fatalf("unexpected rate limit headers: %v", r.header)
+	}
+	if retry, err := strconv.Atoi(r.header.Get("Retry-After")); err != nil || retry < 1 || retry > 30 {
+		t.Fatalf("Retry-After = %q, want the seconds until the next token", r.header.Get("Retry-After"))
+	}
+
+	// Without trusted proxies, X-Forwarded-For cannot pick another bucket
+	req, _ := http.NewRequest("POST", env.server.URL+"/user/login", strings.NewReader(`{}`))
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /user/login: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("spoofed client IP got status %d, want 429", resp.StatusCode)
+	}
+
+	// Authenticated requests are limited per user
+	postID := id(env.createPost(alice, map[string]any{"title": "Limits", "content": "Comments are limited"}), "post_id")
+	env.createComment(alice, aliceID, postID, nil, "first")
+	r = env.do("POST", "/comment/", alice, map[string]any{"post_id": postID, "user_id": aliceID, "content": "second"})
+	env.expect(r, http.StatusTooManyRequests, "too_many_requests")
+	env.createComment(bob, bobID, postID, nil, "from bob")
+
+	// Routes without a rule are not limited when there is no default
+	r = env.do("GET", "/post", "", nil)
+	env.expect(r, http.StatusOK, "")
+	if r.header.Get("RateLimit-Limit") != "" {
+		t.Fatalf("unlimited route has rate limit headers: %v", r.header)
+	}
+}
@@ -0,0 +1,83 @@
+// Package apperror defines the kinds of failure the domain reports, so that
+// repositories and services can say what went wrong without knowing about
+// HTTP, and the HTTP layer can answer without knowing about the database.
+package apperror
+
+import "errors"
+
+// Error kinds. Match them with errors.Is; every *Error matches its Kind.
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrForbidden       = errors.New("forbidden")
+	ErrConflict        = errors.New("conflict")
+	ErrValidation      = errors.New("validation failed")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrLocked          = errors.New("locked")
+	ErrTooManyRequests = errors.New("too many requests")
+)
+
+// FieldError explains why one request field is invalid. Field is the name
+// the client sent it under and Rule the binding rule it broke, if any.
+type FieldError struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+// Error is a domain error with a message that is safe to show to clients.
+type Error struct {
+	Kind    error
+	Message string
+	Details []FieldError
+	// Err is the underlying cause; it is logged but never shown to clients
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func NotFound(message string) *Error {
+	return &Error{Kind: ErrNotFound, Message: message}
+}
+
+func Forbidden(message string) *Error {
+	return &Error{Kind: ErrForbidden, Message: message}
+}
+
+func Conflict(message string) *Error {
+	return &Error{Kind: ErrConflict, Message: message}
+}
+
+func Unauthorized(message string) *Error {
+	return &Error{Kind: ErrUnauthorized, Message: message}
+}
+
+func Locked(message string) *Error {
+	return &Error{Kind: ErrLocked, Message: message}
+}
+
+func TooManyRequests(message string) *Error {
+	return &Error{Kind: ErrTooManyRequests, Message: message}
+}
+
+// Validation reports invalid input, optionally naming the offending fields.
+func Validation(message string, details ...FieldError) *Error {
+	return &Error{Kind: ErrValidation, Message: message, Details: details}
+}
+
+// InvalidField is a validation error about a single field.
+func InvalidField(field, message string) *Error {
+	return Validation(message, FieldError{Field: field, Message: message})
+}
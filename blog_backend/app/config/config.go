@@ -1,41 +1,123 @@
 package config
 
 import (
-	"fmt"
-	"os"
-
-	"github.com/joho/godotenv"
+	"time"
 )
 
-type Config struct {
-	Database struct {
-		DBhost     string `json:"db_host"`
-		DBport     string `json:"db_port"`
-		DBuser     string `json:"db_user"`
-		DBpassword string `json:"db_password"`
-		DBname     string `json:"db_name"`
-	} `json:"database"`
-	ServerPort string `json:"server_port"`
-	JWTSecret  string
-}
+// Every setting can come from the config file under its yaml/toml key, from
+// the environment variable in its env tag and from the command-line flag
+// derived from that variable (DB_HOST becomes --db-host). Later sources
+// override earlier ones, and the default tag applies when none sets it.
+// Settings tagged secret are hidden by `config print --redacted` and can be
+// read from the file named by <ENV>_FILE instead.
 
-var AppConfig Config
+type DatabaseConfig struct {
+	// Driver is postgres, mysql or sqlite. For sqlite DBname is the path
+	// of the database file and the server settings are unused.
+	Driver     string `yaml:"driver" toml:"driver" env:"DB_DRIVER" default:"postgres" validate:"oneof=postgres mysql sqlite"`
+	DBhost     string `yaml:"db_host" toml:"db_host" env:"DB_HOST" validate:"required_unless=Driver sqlite"`
+	DBport     string `yaml:"db_port" toml:"db_port" env:"DB_PORT" validate:"required_unless=Driver sqlite,omitempty,numeric"`
+	DBuser     string `yaml:"db_user" toml:"db_user" env:"DB_USER" validate:"required_unless=Driver sqlite"`
+	DBpassword string `yaml:"db_password" toml:"db_password" env:"DB_PASSWORD" secret:"true" validate:"required_unless=Driver sqlite"`
+	DBname     string `yaml:"db_name" toml:"db_name" env:"DB_NAME" validate:"required"`
+	SSLMode    string `yaml:"ssl_mode" toml:"ssl_mode" env:"DB_SSLMODE" default:"disable" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+	TimeZone   string `yaml:"time_zone" toml:"time_zone" env:"DB_TIMEZONE" default:"UTC" validate:"timezone"`
+	// Pool limits of database/sql; a zero lifetime keeps connections forever
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"25" validate:"min=0"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"5" validate:"min=0"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" default:"30m" validate:"min=0"`
+	// ConnectRetries is how many more times to try reaching the database
+	// at startup, waiting ConnectBackoff and then twice as long each time.
+	ConnectRetries int           `yaml:"connect_retries" toml:"connect_retries" env:"DB_CONNECT_RETRIES" default:"5" validate:"min=0"`
+	ConnectBackoff time.Duration `yaml:"connect_backoff" toml:"connect_backoff" env:"DB_CONNECT_BACKOFF" default:"1s" validate:"min=0"`
+}
 
-func LoadConfig() (conf *Config, err error) {
-	if err := godotenv.Load(".env"); err != nil {
-		return nil, fmt.Errorf("error loading .env file: %w", err)
-	}
-	AppConfig.Database.DBhost = os.Getenv("DB_HOST")
-	AppConfig.Database.DBport = os.Getenv("DB_PORT")
-	AppConfig.Database.DBuser = os.Getenv("DB_USER")
-	AppConfig.Database.DBpassword = os.Getenv("DB_PASSWORD")
-	AppConfig.Database.DBname = os.Getenv("DB_NAME")
-	AppConfig.ServerPort = os.Getenv("SERVER_PORT")
-	AppConfig.JWTSecret = os.Getenv("JWT_SECRET")
-	if AppConfig.Database.DBhost == "" || AppConfig.Database.DBport == "" ||
-		AppConfig.Database.DBuser == "" || AppConfig.Database.DBpassword == "" ||
-		AppConfig.Database.DBname == "" || AppConfig.ServerPort == "" || AppConfig.JWTSecret == "" {
-		return nil, fmt.Errorf("missing required environment variables")
-	}
-	return &AppConfig, nil
+type Config struct {
+	Database   DatabaseConfig `yaml:"database" toml:"database"`
+	ServerPort string         `yaml:"server_port" toml:"server_port" env:"SERVER_PORT" validate:"required,numeric"`
+	// HTTP server timeouts; zero disables one
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" toml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" default:"5s" validate:"min=0"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"SERVER_READ_TIMEOUT" default:"15s" validate:"min=0"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" default:"30s" validate:"min=0"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" default:"60s" validate:"min=0"`
+	// On SIGTERM or SIGINT the server fails /readyz and keeps serving for
+	// ShutdownDelay, so load balancers stop routing to it, then waits up to
+	// ShutdownTimeout for in-flight requests before closing them.
+	ShutdownDelay   time.Duration `yaml:"shutdown_delay" toml:"shutdown_delay" env:"SHUTDOWN_DELAY" default:"0s" validate:"min=0"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"30s" validate:"gt=0"`
+	// Access tokens are signed with JWTAlgorithm by key pairs kept in the
+	// database, encrypted with JWTSecret. A new key signs every
+	// JWTKeyRotation, and is published at /.well-known/jwks.json
+	// JWTKeyPrepublish before. Tokens are issued by JWTIssuer for
+	// JWTAudience, which their verifiers check.
+	JWTSecret        string        `yaml:"jwt_secret" toml:"jwt_secret" env:"JWT_SECRET" secret:"true" validate:"required"`
+	JWTAlgorithm     string        `yaml:"jwt_algorithm" toml:"jwt_algorithm" env:"JWT_ALGORITHM" default:"RS256" validate:"oneof=RS256 EdDSA"`
+	JWTKeyRotation   time.Duration `yaml:"jwt_key_rotation" toml:"jwt_key_rotation" env:"JWT_KEY_ROTATION" default:"720h" validate:"gtfield=JWTKeyPrepublish"`
+	JWTKeyPrepublish time.Duration `yaml:"jwt_key_prepublish" toml:"jwt_key_prepublish" env:"JWT_KEY_PREPUBLISH" default:"24h" validate:"min=0"`
+	JWTIssuer        string        `yaml:"jwt_issuer" toml:"jwt_issuer" env:"JWT_ISSUER" default:"blog_backend" validate:"required"`
+	JWTAudience      string        `yaml:"jwt_audience" toml:"jwt_audience" env:"JWT_AUDIENCE" default:"blog_backend" validate:"required"`
+	AccessTokenTTL   time.Duration `yaml:"access_token_ttl" toml:"access_token_ttl" env:"ACCESS_TOKEN_TTL" default:"15m" validate:"gt=0"`
+	RefreshTokenTTL  time.Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL" default:"720h" validate:"gt=0"`
+	PublishInterval  time.Duration `yaml:"publish_interval" toml:"publish_interval" env:"PUBLISH_INTERVAL" default:"30s" validate:"gt=0"`
+	// /metrics is served on MetricsPort when set, which should only be
+	// reachable from the admin network. Otherwise it is served on the API
+	// port to requests bearing MetricsToken, and not at all without one.
+	MetricsPort  string `yaml:"metrics_port" toml:"metrics_port" env:"METRICS_PORT" validate:"omitempty,numeric,nefield=ServerPort"`
+	MetricsToken string `yaml:"metrics_token" toml:"metrics_token" env:"METRICS_TOKEN" secret:"true"`
+	// Spans are exported to stdout or over OTLP/HTTP to TracingEndpoint, or
+	// where the OTEL_EXPORTER_OTLP_* variables say without one. Requests
+	// without a sampled traceparent are traced at TracingSampleRatio.
+	TracingExporter    string  `yaml:"tracing_exporter" toml:"tracing_exporter" env:"TRACING_EXPORTER" default:"none" validate:"oneof=none stdout otlp"`
+	TracingEndpoint    string  `yaml:"tracing_endpoint" toml:"tracing_endpoint" env:"TRACING_ENDPOINT" validate:"omitempty,url"`
+	TracingSampleRatio float64 `yaml:"tracing_sample_ratio" toml:"tracing_sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1" validate:"min=0,max=1"`
+	// Users verify their email address with a link sent on registration,
+	// valid for EmailVerificationTTL, and reset a forgotten password with
+	// one valid for PasswordResetTTL. Links point to the web app at AppURL.
+	AppURL               string        `yaml:"app_url" toml:"app_url" env:"APP_URL" default:"http://localhost:3000" validate:"url"`
+	EmailVerificationTTL time.Duration `yaml:"email_verification_ttl" toml:"email_verification_ttl" env:"EMAIL_VERIFICATION_TTL" default:"48h" validate:"gt=0"`
+	PasswordResetTTL     time.Duration `yaml:"password_reset_ttl" toml:"password_reset_ttl" env:"PASSWORD_RESET_TTL" default:"1h" validate:"gt=0"`
+	// Authenticator apps list accounts under MFAIssuer. A login with the
+	// right password for an account with two-factor authentication must be
+	// completed with a code within MFAChallengeTTL.
+	MFAIssuer       string        `yaml:"mfa_issuer" toml:"mfa_issuer" env:"MFA_ISSUER" default:"Blog" validate:"required"`
+	MFAChallengeTTL time.Duration `yaml:"mfa_challenge_ttl" toml:"mfa_challenge_ttl" env:"MFA_CHALLENGE_TTL" default:"5m" validate:"gt=0"`
+	// Wallets sign in with EIP-4361 messages for SIWEDomain, the host of
+	// AppURL when empty, on one of SIWEChainIDs, separated by commas. The
+	// nonce a message must carry is valid for SIWENonceTTL.
+	SIWEDomain   string        `yaml:"siwe_domain" toml:"siwe_domain" env:"SIWE_DOMAIN"`
+	SIWEChainIDs string        `yaml:"siwe_chain_ids" toml:"siwe_chain_ids" env:"SIWE_CHAIN_IDS" default:"1" validate:"chainids"`
+	SIWENonceTTL time.Duration `yaml:"siwe_nonce_ttl" toml:"siwe_nonce_ttl" env:"SIWE_NONCE_TTL" default:"10m" validate:"gt=0"`
+	// Emails are sent from MailFrom over SMTP, written as .eml files to
+	// MailOutboxDir, or kept in memory, which only suits tests. Templates
+	// in MailTemplateDir replace the built-in ones of the same name.
+	MailTransport   string `yaml:"mail_transport" toml:"mail_transport" env:"MAIL_TRANSPORT" default:"file" validate:"oneof=smtp file memory"`
+	MailFrom        string `yaml:"mail_from" toml:"mail_from" env:"MAIL_FROM" default:"Blog <no-reply@localhost>" validate:"required"`
+	MailOutboxDir   string `yaml:"mail_outbox_dir" toml:"mail_outbox_dir" env:"MAIL_OUTBOX_DIR" default:"outbox" validate:"required_if=MailTransport file"`
+	MailTemplateDir string `yaml:"mail_template_dir" toml:"mail_template_dir" env:"MAIL_TEMPLATE_DIR"`
+	// SMTPTLS is starttls, tls for implicit TLS as on port 465, or none
+	SMTPHost     string `yaml:"smtp_host" toml:"smtp_host" env:"SMTP_HOST" validate:"required_if=MailTransport smtp"`
+	SMTPPort     string `yaml:"smtp_port" toml:"smtp_port" env:"SMTP_PORT" default:"587" validate:"numeric"`
+	SMTPUsername string `yaml:"smtp_username" toml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" toml:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+	SMTPTLS      string `yaml:"smtp_tls" toml:"smtp_tls" env:"SMTP_TLS" default:"starttls" validate:"oneof=starttls tls none"`
+	// Requests are rate limited per client IP, or per user when they carry
+	// a valid access token. RateLimitRoutes lists "<METHOD> <route>=<limit>"
+	// rules separated by commas, with limits like 10/1m; the other routes
+	// share RateLimitDefault, or are unlimited when it is empty. Buckets are
+	// kept in process, or in the Redis-compatible server at RedisURL so that
+	// instances share them.
+	RateLimitEnabled bool   `yaml:"rate_limit_enabled" toml:"rate_limit_enabled" env:"RATE_LIMIT_ENABLED" default:"true"`
+	RateLimitRoutes  string `yaml:"rate_limit_routes" toml:"rate_limit_routes" env:"RATE_LIMIT_ROUTES" default:"POST /user/register=5/1h,POST /user/login=10/1m,POST /user/login/mfa=10/1m,POST /user/refresh=30/1m,POST /user/password/forgot=5/1h,POST /user/verify/resend=5/1h,GET /user/siwe/nonce=30/1m,POST /user/siwe/verify=10/1m,POST /post/=30/1h,POST /comment/=10/1m" validate:"ratelimits"`
+	RateLimitDefault string `yaml:"rate_limit_default" toml:"rate_limit_default" env:"RATE_LIMIT_DEFAULT" default:"300/1m" validate:"omitempty,ratelimit"`
+	RateLimitStore   string `yaml:"rate_limit_store" toml:"rate_limit_store" env:"RATE_LIMIT_STORE" default:"memory" validate:"oneof=memory redis"`
+	RedisURL         string `yaml:"redis_url" toml:"redis_url" env:"REDIS_URL" secret:"true" validate:"required_if=RateLimitStore redis,omitempty,url"`
+	// TrustedProxies are the addresses or CIDR ranges of the reverse proxies
+	// whose X-Forwarded-For header gives the client IP, separated by commas.
+	// Without any, the client IP is the address of the connection.
+	TrustedProxies string `yaml:"trusted_proxies" toml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	// Logs are written to stdout at LogLevel and above, as JSON or text
+	LogLevel  string `yaml:"log_level" toml:"log_level" env:"LOG_LEVEL" default:"info" validate:"oneof=debug info warn error"`
+	LogFormat string `yaml:"log_format" toml:"log_format" env:"LOG_FORMAT" default:"json" validate:"oneof=json text"`
+	// CommentMaxDepth is capped so comment paths fit their column
+	CommentMaxDepth int `yaml:"comment_max_depth" toml:"comment_max_depth" env:"COMMENT_MAX_DEPTH" default:"5" validate:"min=0,max=20"`
 }
@@ -0,0 +1,357 @@
+package config
+
+import (
+	"blog_backend/app/ratelimit"
+	"blog_backend/app/siwe"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// field is one leaf setting of Config.
+type field struct {
+	key    string // dotted file key, e.g. database.db_host
+	env    string
+	flag   string
+	def    string
+	secret bool
+	value  reflect.Value
+}
+
+type flagValue struct {
+	field *field
+	raw   string
+}
+
+// Loader builds a Config from its layered sources. Its flags are registered
+// on a caller's FlagSet so commands can add flags of their own.
+type Loader struct {
+	configFile *string
+	flags      []flagValue
+	cfg        *Config
+	fields     []*field
+}
+
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{cfg: &Config{}}
+	l.fields = collectFields(reflect.ValueOf(l.cfg).Elem(), "")
+	l.configFile = fs.String("config", "", "path of a YAML or TOML config file (env CONFIG_FILE)")
+	for _, f := range l.fields {
+		if f.flag == "" {
+			continue
+		}
+		fs.Func(f.flag, "sets "+f.key+" (env "+f.env+")", func(raw string) error {
+			// Flags apply last, once the file and the environment are read,
+			// but a bad value is reported right away
+			if err := setValue(reflect.New(f.value.Type()).Elem(), raw); err != nil {
+				return err
+			}
+			l.flags = append(l.flags, flagValue{field: f, raw: raw})
+			return nil
+		})
+	}
+	return l
+}
+
+// Load parses args as flags and loads the configuration.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("blog_backend", flag.ContinueOnError)
+	loader := NewLoader(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return loader.Load()
+}
+
+// Load applies, in order, the defaults, the config file, a .env file if
+// there is one, the environment and the flags, then validates the result.
+// On a *ValidationError the returned Config is still filled in, so it can
+// be printed.
+func (l *Loader) Load() (*Config, error) {
+	for _, f := range l.fields {
+		if err := setValue(f.value, f.def); err != nil {
+			return nil, fmt.Errorf("invalid default for %s: %w", f.key, err)
+		}
+	}
+
+	file := *l.configFile
+	if file == "" {
+		file = os.Getenv("CONFIG_FILE")
+	}
+	if file != "" {
+		if err := l.loadFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	// .env is a convenience for local development; variables already set in
+	// the environment win over it
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error loading .env file: %w", err)
+	}
+	if err := l.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	for _, fv := range l.flags {
+		if err := setValue(fv.field.value, fv.raw); err != nil {
+			return nil, fmt.Errorf("invalid value for --%s: %w", fv.field.flag, err)
+		}
+	}
+
+	return l.cfg, validate(l.cfg)
+}
+
+func (l *Loader) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	values := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &values)
+	case ".toml":
+		err = toml.Unmarshal(content, &values)
+	default:
+		return fmt.Errorf("unsupported config file type %q: use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	flat := map[string]any{}
+	flatten(values, "", flat)
+	byKey := make(map[string]*field, len(l.fields))
+	for _, f := range l.fields {
+		byKey[f.key] = f
+	}
+	for key, value := range flat {
+		f, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("unknown setting %q in config file %s", key, path)
+		}
+		if err := setValue(f.value, fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("invalid value for %s in config file %s: %w", key, path, err)
+		}
+	}
+	return nil
+}
+
+func (l *Loader) loadEnv() error {
+	for _, f := range l.fields {
+		if f.env == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(f.env)
+		if f.secret {
+			if path := os.Getenv(f.env + "_FILE"); path != "" {
+				content, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("failed to read %s_FILE: %w", f.env, err)
+				}
+				raw, ok = strings.TrimRight(string(content), "\r\n"), true
+			}
+		}
+		if !ok {
+			continue
+		}
+		if err := setValue(f.value, raw); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", f.env, err)
+		}
+	}
+	return nil
+}
+
+func collectFields(v reflect.Value, prefix string) []*field {
+	var fields []*field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := prefix + sf.Tag.Get("yaml")
+		if sf.Type.Kind() == reflect.Struct && sf.Type != reflect.TypeOf(time.Duration(0)) {
+			fields = append(fields, collectFields(v.Field(i), key+".")...)
+			continue
+		}
+		f := &field{
+			key:    key,
+			env:    sf.Tag.Get("env"),
+			def:    sf.Tag.Get("default"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		}
+		if f.env != "" {
+			f.flag = strings.ReplaceAll(strings.ToLower(f.env), "_", "-")
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func flatten(values map[string]any, prefix string, out map[string]any) {
+	for key, value := range values {
+		if nested, ok := value.(map[string]any); ok {
+			flatten(nested, prefix+key+".", out)
+			continue
+		}
+		out[prefix+key] = value
+	}
+}
+
+func setValue(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		if raw == "" {
+			v.SetInt(0)
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Int:
+		if raw == "" {
+			v.SetInt(0)
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		if raw == "" {
+			v.SetFloat(0)
+			return nil
+		}
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Bool:
+		if raw == "" {
+			v.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// FieldError is one setting that failed validation.
+type FieldError struct {
+	Key     string
+	Env     string
+	Message string
+}
+
+// ValidationError lists every invalid setting, not just the first.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Key
+		if f.Env != "" {
+			parts[i] += " (" + f.Env + ")"
+		}
+		parts[i] += " " + f.Message
+	}
+	return "invalid configuration: " + strings.Join(parts, "; ")
+}
+
+func validate(cfg *Config) error {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate.RegisterTagNameFunc(func(sf reflect.StructField) string {
+		return sf.Tag.Get("yaml") + "|" + sf.Tag.Get("env")
+	})
+	validate.RegisterValidation("ratelimit", func(fl validator.FieldLevel) bool {
+		_, err := ratelimit.ParseLimit(fl.Field().String())
+		return err == nil
+	})
+	validate.RegisterValidation("ratelimits", func(fl validator.FieldLevel) bool {
+		_, err := ratelimit.ParseRules(fl.Field().String(), "")
+		return err == nil
+	})
+	validate.RegisterValidation("chainids", func(fl validator.FieldLevel) bool {
+		_, err := siwe.ParseChainIDs(fl.Field().String())
+		return err == nil
+	})
+	err := validate.Struct(cfg)
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+
+	result := &ValidationError{}
+	for _, fe := range fieldErrors {
+		// Namespace is Config.<yaml|env>.<yaml|env>...; keep the yaml keys
+		segments := strings.Split(fe.Namespace(), ".")[1:]
+		keys := make([]string, len(segments))
+		for i, segment := range segments {
+			keys[i], _, _ = strings.Cut(segment, "|")
+		}
+		_, env, _ := strings.Cut(segments[len(segments)-1], "|")
+		result.Fields = append(result.Fields, FieldError{
+			Key:     strings.Join(keys, "."),
+			Env:     env,
+			Message: describe(fe),
+		})
+	}
+	return result
+}
+
+func describe(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_if", "required_unless":
+		return "is required"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "numeric":
+		return "must be a number"
+	case "url":
+		return "must be a URL"
+	case "timezone":
+		return "must be an IANA time zone such as UTC or Europe/Berlin"
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "nefield":
+		return "must differ from " + fe.Param()
+	case "gtfield":
+		return "must be greater than " + fe.Param()
+	case "ratelimit":
+		return "must be a limit such as 10/1m"
+	case "ratelimits":
+		return `must be rules such as "POST /user/login=10/1m,POST /comment/=5/1m"`
+	case "chainids":
+		return "must be chain IDs separated by commas, such as 1,10"
+	}
+	return "fails " + fe.Tag()
+}
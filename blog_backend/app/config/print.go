@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "<redacted>"
+
+// Print writes cfg as a YAML config file. With redact set, secrets that are
+// set are replaced by a placeholder.
+func Print(w io.Writer, cfg *Config, redact bool) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range collectFields(reflect.ValueOf(cfg).Elem(), "") {
+		value := formatValue(f.value)
+		if redact && f.secret && value != "" {
+			value = redacted
+		}
+		insert(root, strings.Split(f.key, "."), value)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return fmt.Errorf("failed to print config: %w", err)
+	}
+	return enc.Close()
+}
+
+func formatValue(v reflect.Value) string {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// insert adds value under the nested keys of path, creating the mappings on
+// the way in field order.
+func insert(node *yaml.Node, path []string, value string) {
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == path[0] && len(path) > 1 {
+			insert(node.Content[i+1], path[1:], value)
+			return
+		}
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Value: path[0]}
+	if len(path) == 1 {
+		scalar := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+		if value == "" {
+			scalar.Style = yaml.DoubleQuotedStyle
+		}
+		node.Content = append(node.Content, key, scalar)
+		return
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	node.Content = append(node.Content, key, child)
+	insert(child, path[1:], value)
+}
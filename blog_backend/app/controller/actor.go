@@ -0,0 +1,18 @@
+package controller
+
+import (
+	"blog_backend/app/models"
+	"blog_backend/app/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentActor builds the policy actor from the claims set by the auth
+// middleware.
+func currentActor(ctx *gin.Context) policy.Actor {
+	return policy.Actor{
+		UserID:        ctx.GetInt("userId"),
+		Role:          models.Role(ctx.GetString("role")),
+		EmailVerified: ctx.GetBool("emailVerified"),
+	}
+}
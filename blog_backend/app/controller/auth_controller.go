@@ -2,8 +2,11 @@ package controller
 
 import (
 	"blog_backend/app/dto"
+	"blog_backend/app/models"
 	"blog_backend/app/services"
-	"fmt"
+	"errors"
+	"math"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,22 +20,22 @@ func (a AuthController) Register(ctx *gin.Context) {
 	request := &dto.UserRegisterRequest{}
 
 	if err := ctx.ShouldBind(request); err != nil {
-		ctx.JSON(400, gin.H{"error": err.Error()})
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	fmt.Printf("Received user registration request: %+v\n", request)
-	user, err := a.authService.Register(request.Username, request.Email, request.Password)
+	user, err := a.authService.Register(ctx.Request.Context(), request.Username, request.Email, request.Password)
 	if err != nil {
-		ctx.JSON(500, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
 	resp := dto.UserRegisterResponse{
-		Message:  "User registered successfully",
-		UserID:   user.ID,
-		Username: user.Username,
-		Email:    user.Email,
+		Message:       "User registered successfully, check your email to verify your address",
+		UserID:        user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified(),
 	}
 	ctx.JSON(200, resp)
 }
@@ -40,21 +43,347 @@ func (a AuthController) Register(ctx *gin.Context) {
 func (a AuthController) Login(ctx *gin.Context) {
 	request := &dto.LoginRequest{}
 	if err := ctx.ShouldBind(request); err != nil {
-		ctx.JSON(400, gin.H{"error": err.Error()})
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	_, token, err := a.authService.Login(request.Email, request.Password)
+	result, err := a.authService.Login(ctx.Request.Context(), request.Email, request.Password, ctx.ClientIP())
 	if err != nil {
-		ctx.JSON(500, gin.H{"error": err.Error()})
+		setRetryAfter(ctx, err)
+		ctx.Error(err)
 		return
 	}
-	fmt.Println(token)
 
-	resp := dto.LoginPesponse{Message: "Login Success", Token: token}
+	respondLogin(ctx, result)
+}
+
+// CompleteMFALogin finishes a login challenged for a code.
+func (a AuthController) CompleteMFALogin(ctx *gin.Context) {
+	request := &dto.MFALoginRequest{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	result, err := a.authService.CompleteMFALogin(ctx.Request.Context(), request.MFAToken, request.Code, ctx.ClientIP())
+	if err != nil {
+		setRetryAfter(ctx, err)
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(200, loginResponse(result.Tokens))
+}
+
+func (a AuthController) Refresh(ctx *gin.Context) {
+	request := &dto.RefreshTokenRequest{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	tokens, err := a.authService.Refresh(ctx.Request.Context(), request.RefreshToken)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	resp := dto.RefreshTokenResponse{
+		Message:      "Token refreshed successfully",
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int(tokens.ExpiresIn.Seconds()),
+	}
+	ctx.JSON(200, resp)
+}
+
+func (a AuthController) Logout(ctx *gin.Context) {
+	request := &dto.LogoutRequest{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := a.authService.Logout(ctx.Request.Context(), request.RefreshToken); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(200, dto.LogoutResponse{Message: "Logout successful"})
+}
+
+func (a AuthController) VerifyEmail(ctx *gin.Context) {
+	request := &dto.VerifyEmailRequest{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := a.authService.VerifyEmail(ctx.Request.Context(), request.Token); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(200, dto.MessageResponse{Message: "Email address verified, refresh your token to use it"})
+}
+
+func (a AuthController) ResendVerification(ctx *gin.Context) {
+	if err := a.authService.ResendVerification(ctx.Request.Context(), ctx.GetInt("userId")); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(200, dto.MessageResponse{Message: "Verification email sent"})
+}
+
+func (a AuthController) ForgotPassword(ctx *gin.Context) {
+	request := &dto.ForgotPasswordRequest{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := a.authService.ForgotPassword(ctx.Request.Context(), request.Email); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(200, dto.MessageResponse{Message: "If an account uses this address, a password reset email was sent"})
+}
+
+func (a AuthController) ResetPassword(ctx *gin.Context) {
+	request := &dto.ResetPasswordRequest{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := a.authService.ResetPassword(ctx.Request.Context(), request.Token, request.Password); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(200, dto.MessageResponse{Message: "Password reset, please log in again"})
+}
+
+func (a AuthController) EnrollMFA(ctx *gin.Context) {
+	enrollment, err := a.authService.EnrollMFA(ctx.Request.Context(), ctx.GetInt("userId"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(200, dto.MFAEnrollResponse{
+		Message:    "Add the account to your authenticator app, then confirm with its first code",
+		Secret:     enrollment.Secret,
+		OTPAuthURI: enrollment.URI,
+		QRCodePNG:  enrollment.QRCode,
+	})
+}
+
+func (a AuthController) ConfirmMFA(ctx *gin.Context) {
+	request := &dto.MFACodeRequest{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	recoveryCodes, err := a.authService.ConfirmMFA(ctx.Request.Context(), ctx.GetInt("userId"), request.Code)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(200, dto.MFARecoveryCodesResponse{
+		Message:       "Two-factor authentication enabled, store the recovery codes somewhere safe",
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (a AuthController) DisableMFA(ctx *gin.Context) {
+	request := &dto.MFACodeRequest{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := a.authService.DisableMFA(ctx.Request.Context(), ctx.GetInt("userId"), request.Code, ctx.ClientIP()); err != nil {
+		setRetryAfter(ctx, err)
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(200, dto.MessageResponse{Message: "Two-factor authentication disabled"})
+}
+
+// JWKS publishes the public keys access tokens are verified with, in the
+// standard JSON Web Key Set form. Keys are published well before they sign,
+// so verifiers may cache the set for a while.
+func (a AuthController) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(200, a.authService.JWKS())
+}
+
+// SIWENonce hands out the nonce of a Sign-In with Ethereum message.
+func (a AuthController) SIWENonce(ctx *gin.Context) {
+	challenge, err := a.authService.IssueSIWENonce(ctx.Request.Context())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(200, dto.SIWENonceResponse{
+		Nonce:     challenge.Nonce,
+		Domain:    challenge.Domain,
+		ChainIDs:  challenge.ChainIDs,
+		ExpiresIn: int(challenge.ExpiresIn.Seconds()),
+	})
+}
+
+// SIWEVerify logs in with a signed Sign-In with Ethereum message.
+func (a AuthController) SIWEVerify(ctx *gin.Context) {
+	request := &dto.SIWEVerifyRequest{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	result, err := a.authService.SignInWithEthereum(ctx.Request.Context(), request.Message, request.Signature)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	respondLogin(ctx, result)
+}
+
+func (a AuthController) LinkWallet(ctx *gin.Context) {
+	request := &dto.SIWEVerifyRequest{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	user, err := a.authService.LinkWallet(ctx.Request.Context(), ctx.GetInt("userId"), request.Message, request.Signature)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(200, dto.WalletLinkResponse{
+		Message:       "Wallet linked, you can now sign in with it",
+		WalletAddress: *user.WalletAddress,
+	})
+}
+
+func (a AuthController) CreateAccessToken(ctx *gin.Context) {
+	request := &dto.AccessTokenCreateRequest{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	scopes := make([]models.TokenScope, len(request.Scopes))
+	for i, scope := range request.Scopes {
+		scopes[i] = models.TokenScope(scope)
+	}
+	stored, token, err := a.authService.CreateAccessToken(ctx.Request.Context(), ctx.GetInt("userId"), request.Name,
+		scopes, request.ExpiresAt)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(200, dto.AccessTokenCreateResponse{
+		Message:         "Personal access token created, copy it now as it will not be shown again",
+		Token:           token,
+		AccessTokenItem: accessTokenItem(stored),
+	})
+}
+
+func (a AuthController) ListAccessTokens(ctx *gin.Context) {
+	tokens, err := a.authService.ListAccessTokens(ctx.Request.Context(), ctx.GetInt("userId"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	resp := dto.AccessTokenListResponse{
+		Message:      "Personal access tokens retrieved successfully",
+		AccessTokens: make([]dto.AccessTokenItem, len(tokens)),
+	}
+	for i := range tokens {
+		resp.AccessTokens[i] = accessTokenItem(&tokens[i])
+	}
 	ctx.JSON(200, resp)
 }
 
+func (a AuthController) RevokeAccessToken(ctx *gin.Context) {
+	var uriRequest dto.AccessTokenURIRequest
+	if err := ctx.ShouldBindUri(&uriRequest); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := a.authService.RevokeAccessToken(ctx.Request.Context(), ctx.GetInt("userId"), uriRequest.TokenID); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(200, dto.MessageResponse{Message: "Personal access token revoked"})
+}
+
+func accessTokenItem(token *models.PersonalAccessToken) dto.AccessTokenItem {
+	item := dto.AccessTokenItem{
+		TokenID:   token.ID,
+		Name:      token.Name,
+		Scopes:    []string{},
+		CreatedAt: token.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	for _, scope := range token.ScopeList() {
+		item.Scopes = append(item.Scopes, string(scope))
+	}
+	if token.ExpiresAt != nil {
+		item.ExpiresAt = token.ExpiresAt.Format("2006-01-02 15:04:05")
+	}
+	if token.LastUsedAt != nil {
+		item.LastUsedAt = token.LastUsedAt.Format("2006-01-02 15:04:05")
+	}
+	return item
+}
+
+// respondLogin answers a login with the tokens of the new session, or with
+// the challenge for a code when the account has two-factor authentication.
+func respondLogin(ctx *gin.Context, result *services.LoginResult) {
+	if result.MFA != nil {
+		ctx.JSON(200, dto.MFAChallengeResponse{
+			Message:     "Two-factor authentication required",
+			MFARequired: true,
+			MFAToken:    result.MFA.Token,
+			ExpiresIn:   int(result.MFA.ExpiresIn.Seconds()),
+		})
+		return
+	}
+	ctx.JSON(200, loginResponse(result.Tokens))
+}
+
+func loginResponse(tokens *services.TokenPair) dto.LoginPesponse {
+	return dto.LoginPesponse{
+		Message:      "Login Success",
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int(tokens.ExpiresIn.Seconds()),
+	}
+}
+
+// setRetryAfter tells clients locked out of logging in when to try again.
+func setRetryAfter(ctx *gin.Context, err error) {
+	var lockoutErr *services.LockoutError
+	if errors.As(err, &lockoutErr) {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(lockoutErr.RetryAfter.Seconds()))))
+	}
+}
+
 func NewAuthController(authservice services.AuthService) *AuthController {
 	return &AuthController{
 		authService: authservice,
@@ -2,8 +2,9 @@ package controller
 
 import (
 	"blog_backend/app/dto"
+	"blog_backend/app/models"
+	"blog_backend/app/repository"
 	"blog_backend/app/services"
-	"fmt"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,25 +16,19 @@ type CommentController struct {
 func (c CommentController) CreateComment(ctx *gin.Context) {
 	request := &dto.CommentCreateRequest{}
 	if err := ctx.ShouldBindJSON(request); err != nil {
-		ctx.JSON(400, gin.H{"error": err.Error()})
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	comment, err := c.commentService.CreateComment(request.PostID, ctx.GetInt("userId"), request.Content)
+	comment, err := c.commentService.CreateComment(ctx.Request.Context(), currentActor(ctx), request.PostID, request.ParentID, request.Content)
 	if err != nil {
-		ctx.JSON(500, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
 	resp := dto.CommentCreateResponse{
-		Message: "Comment created successfully",
-		CommentItem: dto.CommentItem{
-			ID:        comment.ID,
-			Content:   comment.Content,
-			UserID:    comment.UserID,
-			PostID:    comment.PostID,
-			CreatedAt: comment.CreatedAt.Format("2006-01-02 15:04:05"),
-		},
+		Message:     "Comment created successfully",
+		CommentItem: newCommentItem(comment),
 	}
 	ctx.JSON(200, resp)
 }
@@ -41,25 +36,19 @@ func (c CommentController) CreateComment(ctx *gin.Context) {
 func (c CommentController) RetrieveComment(ctx *gin.Context) {
 	request := &dto.CommentRetrieveRequest{}
 	if err := ctx.ShouldBindUri(request); err != nil {
-		ctx.JSON(400, gin.H{"error": err.Error()})
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	comment, err := c.commentService.RetrieveComment(request.CommentID)
+	comment, err := c.commentService.RetrieveComment(ctx.Request.Context(), currentActor(ctx), request.CommentID)
 	if err != nil {
-		ctx.JSON(500, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
 	resp := dto.CommentRetrieveResponse{
-		Message: "Comment retrieved successfully",
-		CommentItem: dto.CommentItem{
-			ID:        comment.ID,
-			Content:   comment.Content,
-			UserID:    comment.UserID,
-			PostID:    comment.PostID,
-			CreatedAt: comment.CreatedAt.Format("2006-01-02 15:04:05"),
-		},
+		Message:     "Comment retrieved successfully",
+		CommentItem: newCommentItem(comment),
 	}
 	ctx.JSON(200, resp)
 }
@@ -67,29 +56,23 @@ func (c CommentController) RetrieveComment(ctx *gin.Context) {
 func (c CommentController) UpdateComment(ctx *gin.Context) {
 	var uriRequest dto.CommentUpdateURIRequest
 	if err := ctx.ShouldBindUri(&uriRequest); err != nil {
-		ctx.JSON(400, gin.H{"error": fmt.Errorf("invalid URI: %w", err)})
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 	var jsonRequest dto.CommentUpdateBodyRequest
 	if err := ctx.ShouldBindJSON(&jsonRequest); err != nil {
-		ctx.JSON(400, gin.H{"error": fmt.Errorf("invalid JSON body: %w", err)})
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	updatedComment, err := c.commentService.UpdateComment(ctx.GetInt("userId"), uriRequest.CommentID, jsonRequest.Content)
+	updatedComment, err := c.commentService.UpdateComment(ctx.Request.Context(), currentActor(ctx), uriRequest.CommentID, jsonRequest.Content)
 	if err != nil {
-		ctx.JSON(500, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 	resp := dto.CommentUpdateResponse{
-		Message: "Comment updated successfully",
-		CommentItem: dto.CommentItem{
-			ID:        updatedComment.ID,
-			Content:   updatedComment.Content,
-			UserID:    updatedComment.UserID,
-			PostID:    updatedComment.PostID,
-			CreatedAt: updatedComment.CreatedAt.Format("2006-01-02 15:04:05"),
-		},
+		Message:     "Comment updated successfully",
+		CommentItem: newCommentItem(updatedComment),
 	}
 	ctx.JSON(200, resp)
 }
@@ -97,12 +80,12 @@ func (c CommentController) UpdateComment(ctx *gin.Context) {
 func (c CommentController) DeleteComment(ctx *gin.Context) {
 	request := &dto.CommentDeleteRequest{}
 	if err := ctx.ShouldBindUri(request); err != nil {
-		ctx.JSON(400, gin.H{"error": err.Error()})
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	if err := c.commentService.DeleteComment(ctx.GetInt("userId"), request.CommentID); err != nil {
-		ctx.JSON(500, gin.H{"error": err.Error()})
+	if err := c.commentService.DeleteComment(ctx.Request.Context(), currentActor(ctx), request.CommentID); err != nil {
+		ctx.Error(err)
 		return
 	}
 
@@ -112,32 +95,188 @@ func (c CommentController) DeleteComment(ctx *gin.Context) {
 func (c CommentController) ListComments(ctx *gin.Context) {
 	request := &dto.ListCommentsRequest{}
 	if err := ctx.ShouldBindUri(request); err != nil {
-		ctx.JSON(400, gin.H{"error": err.Error()})
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	query := &dto.ListCommentsQuery{}
+	if err := ctx.ShouldBindQuery(query); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	comments, err := c.commentService.ListComments(request.PostID)
+	page := repository.Page{Sort: repository.Sort(query.Sort), Cursor: query.Cursor, Limit: query.Limit}
+	if query.Mode == "tree" || query.Mode == "thread" {
+		c.listCommentThreads(ctx, request.PostID, query.Mode, page)
+		return
+	}
+	comments, err := c.commentService.ListComments(ctx.Request.Context(), currentActor(ctx), request.PostID, page)
 	if err != nil {
-		ctx.JSON(500, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
 	resp := dto.ListCommentsResponse{
-		Message:  "Comments retrieved successfully",
-		Comments: make([]dto.CommentItem, len(comments)),
-	}
-	for i, comment := range comments {
-		resp.Comments[i] = dto.CommentItem{
-			ID:        comment.ID,
-			Content:   comment.Content,
-			UserID:    comment.UserID,
-			PostID:    comment.PostID,
-			CreatedAt: comment.CreatedAt.Format("2006-01-02 15:04:05"),
+		Message:    "Comments retrieved successfully",
+		Comments:   make([]dto.CommentItem, len(comments.Items)),
+		Total:      comments.Total,
+		NextCursor: comments.NextCursor,
+	}
+	for i, comment := range comments.Items {
+		resp.Comments[i] = newCommentItem(comment)
+	}
+	ctx.JSON(200, resp)
+}
+
+func (c CommentController) ListRevisions(ctx *gin.Context) {
+	request := &dto.CommentRetrieveRequest{}
+	if err := ctx.ShouldBindUri(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	revisions, err := c.commentService.ListRevisions(ctx.Request.Context(), currentActor(ctx), request.CommentID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	resp := dto.RevisionListResponse{
+		Message:   "Revisions retrieved successfully",
+		Revisions: make([]dto.RevisionItem, len(revisions)),
+	}
+	for i, rev := range revisions {
+		resp.Revisions[i] = newCommentRevisionItem(rev)
+	}
+	ctx.JSON(200, resp)
+}
+
+func (c CommentController) RetrieveRevision(ctx *gin.Context) {
+	request := &dto.CommentRevisionRequest{}
+	if err := ctx.ShouldBindUri(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	rev, err := c.commentService.RetrieveRevision(ctx.Request.Context(), currentActor(ctx), request.CommentID, request.Revision)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	resp := dto.RevisionRetrieveResponse{
+		Message:  "Revision retrieved successfully",
+		Revision: newCommentRevisionItem(rev),
+	}
+	ctx.JSON(200, resp)
+}
+
+func (c CommentController) DiffRevisions(ctx *gin.Context) {
+	request := &dto.CommentRetrieveRequest{}
+	if err := ctx.ShouldBindUri(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	query := &dto.RevisionDiffQuery{}
+	if err := ctx.ShouldBindQuery(query); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	diff, err := c.commentService.DiffRevisions(ctx.Request.Context(), currentActor(ctx), request.CommentID, query.From, query.To)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(200, newRevisionDiffResponse(diff))
+}
+
+func (c CommentController) RestoreRevision(ctx *gin.Context) {
+	request := &dto.CommentRevisionRequest{}
+	if err := ctx.ShouldBindUri(request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	comment, err := c.commentService.RestoreRevision(ctx.Request.Context(), currentActor(ctx), request.CommentID, request.Revision)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	resp := dto.CommentUpdateResponse{
+		Message:     "Revision restored successfully",
+		CommentItem: newCommentItem(comment),
+	}
+	ctx.JSON(200, resp)
+}
+
+func (c CommentController) listCommentThreads(ctx *gin.Context, postID int, mode string, page repository.Page) {
+	threads, err := c.commentService.ListCommentThreads(ctx.Request.Context(), currentActor(ctx), postID, page)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if mode == "tree" {
+		resp := dto.CommentTreeResponse{
+			Message:    "Comments retrieved successfully",
+			Threads:    newCommentTreeItems(threads.Threads),
+			Total:      threads.Total,
+			NextCursor: threads.NextCursor,
 		}
+		ctx.JSON(200, resp)
+		return
+	}
+	resp := dto.ListCommentsResponse{
+		Message:    "Comments retrieved successfully",
+		Comments:   flattenCommentThreads(threads.Threads, []dto.CommentItem{}),
+		Total:      threads.Total,
+		NextCursor: threads.NextCursor,
 	}
 	ctx.JSON(200, resp)
 }
 
+// newCommentItem hides the author of deleted comments left as placeholders.
+func newCommentItem(comment *models.Comment) dto.CommentItem {
+	item := dto.CommentItem{
+		ID:        comment.ID,
+		Content:   comment.Content,
+		UserID:    comment.UserID,
+		PostID:    comment.PostID,
+		ParentID:  comment.ParentID,
+		Depth:     comment.Depth,
+		Path:      comment.Path,
+		CreatedAt: comment.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if comment.IsDeleted() {
+		item.Content = models.DeletedCommentContent
+		item.UserID = 0
+		item.Deleted = true
+	}
+	return item
+}
+
+func newCommentTreeItems(nodes []*services.CommentNode) []dto.CommentTreeItem {
+	items := make([]dto.CommentTreeItem, len(nodes))
+	for i, node := range nodes {
+		items[i] = dto.CommentTreeItem{
+			CommentItem: newCommentItem(node.Comment),
+			Replies:     newCommentTreeItems(node.Replies),
+		}
+	}
+	return items
+}
+
+// flattenCommentThreads appends the comments of nodes to items depth-first,
+// which is path order within each thread.
+func flattenCommentThreads(nodes []*services.CommentNode, items []dto.CommentItem) []dto.CommentItem {
+	for _, node := range nodes {
+		items = append(items, newCommentItem(node.Comment))
+		items = flattenCommentThreads(node.Replies, items)
+	}
+	return items
+}
+
 func NewCommentController(commentService services.CommentService) *CommentController {
 	return &CommentController{
 		commentService: commentService,
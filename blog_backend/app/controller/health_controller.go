@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"blog_backend/app/dto"
+	"blog_backend/app/health"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readyTimeout bounds each readiness check, so a hung database fails the
+// probe instead of stalling it.
+const readyTimeout = 2 * time.Second
+
+type HealthController struct {
+	checks []health.Check
+}
+
+// Live answers as long as the process serves requests at all.
+func (h HealthController) Live(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, dto.HealthResponse{Status: "ok"})
+}
+
+// Ready runs every check and answers 503 if any of them fails.
+func (h HealthController) Ready(ctx *gin.Context) {
+	resp := dto.HealthResponse{Status: "ok", Checks: map[string]string{}}
+	status := http.StatusOK
+	for _, check := range h.checks {
+		checkCtx, cancel := context.WithTimeout(ctx.Request.Context(), readyTimeout)
+		err := check.Check(checkCtx)
+		cancel()
+		if err != nil {
+			resp.Checks[check.Name] = err.Error()
+			resp.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[check.Name] = "ok"
+	}
+	ctx.JSON(status, resp)
+}
+
+func NewHealthController(checks []health.Check) *HealthController {
+	return &HealthController{checks: checks}
+}
@@ -2,6 +2,8 @@ package controller
 
 import (
 	"blog_backend/app/dto"
+	"blog_backend/app/models"
+	"blog_backend/app/repository"
 	"blog_backend/app/services"
 	"net/http"
 
@@ -15,26 +17,27 @@ type PostController struct {
 func (p PostController) CreatePost(ctx *gin.Context) {
 	var request dto.PostCreateRequest
 	if err := ctx.ShouldBindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	post, err := p.postService.CreatePost(request.Title, request.Content, ctx.GetInt("userId"))
+	input := services.PostInput{
+		Title:      request.Title,
+		Content:    request.Content,
+		Tags:       request.Tags,
+		CategoryID: request.CategoryID,
+		Status:     models.PostStatus(request.Status),
+		PublishAt:  request.PublishAt,
+	}
+	post, err := p.postService.CreatePost(ctx.Request.Context(), input, ctx.GetInt("userId"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
 	resp := dto.PostCreateResponse{
-		Message: "Post created successfully",
-		PostItem: dto.PostItem{
-			PostID:    post.ID,
-			Title:     post.Title,
-			Content:   post.Content,
-			UserID:    post.UserID,
-			CreatedAt: post.CreatedAt.Format("2006-01-02 15:04:05"),
-			UpdatedAt: post.UpdatedAt.Format("2006-01-02 15:04:05"),
-		},
+		Message:  "Post created successfully",
+		PostItem: newPostItem(post),
 	}
 	ctx.JSON(http.StatusOK, resp)
 }
@@ -42,25 +45,18 @@ func (p PostController) CreatePost(ctx *gin.Context) {
 func (p PostController) RetrievePost(ctx *gin.Context) {
 	var request dto.PostRetrieveRequest
 	if err := ctx.ShouldBindUri(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	post, err := p.postService.RetrievePost(request.PostID)
+	post, err := p.postService.RetrievePost(ctx.Request.Context(), currentActor(ctx), request.PostID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
 	resp := dto.PostRetrieveResponse{
-		PostItem: dto.PostItem{
-			PostID:    post.ID,
-			Title:     post.Title,
-			Content:   post.Content,
-			UserID:    post.UserID,
-			CreatedAt: post.CreatedAt.Format("2006-01-02 15:04:05"),
-			UpdatedAt: post.UpdatedAt.Format("2006-01-02 15:04:05"),
-		},
+		PostItem: newPostItem(post),
 	}
 	ctx.JSON(http.StatusOK, resp)
 }
@@ -68,26 +64,27 @@ func (p PostController) RetrievePost(ctx *gin.Context) {
 func (p PostController) UpdatePost(ctx *gin.Context) {
 	var request dto.PostUpdateRequest
 	if err := ctx.ShouldBindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	post, err := p.postService.UpdatePost(ctx.GetInt("userId"), request.PostID, request.Title, request.Content)
+	input := services.PostInput{
+		Title:      request.Title,
+		Content:    request.Content,
+		Tags:       request.Tags,
+		CategoryID: request.CategoryID,
+		Status:     models.PostStatus(request.Status),
+		PublishAt:  request.PublishAt,
+	}
+	post, err := p.postService.UpdatePost(ctx.Request.Context(), currentActor(ctx), request.PostID, input)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
 	resp := dto.PostUpdateResponse{
-		Message: "Post updated successfully",
-		PostItem: dto.PostItem{
-			PostID:    post.ID,
-			Title:     post.Title,
-			Content:   post.Content,
-			UserID:    post.UserID,
-			CreatedAt: post.CreatedAt.Format("2006-01-02 15:04:05"),
-			UpdatedAt: post.UpdatedAt.Format("2006-01-02 15:04:05"),
-		},
+		Message:  "Post updated successfully",
+		PostItem: newPostItem(post),
 	}
 	ctx.JSON(http.StatusOK, resp)
 }
@@ -95,18 +92,13 @@ func (p PostController) UpdatePost(ctx *gin.Context) {
 func (p PostController) DeletePost(ctx *gin.Context) {
 	var request dto.PostDeleteRequest
 	if err := ctx.ShouldBindUri(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	userId := ctx.GetInt("userId")
-	err := p.postService.DeletePost(userId, request.PostID)
+	err := p.postService.DeletePost(ctx.Request.Context(), currentActor(ctx), request.PostID)
 	if err != nil {
-		if err.Error() == "permission denied" {
-			ctx.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to delete this post"})
-		} else {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		ctx.Error(err)
 		return
 	}
 
@@ -116,6 +108,171 @@ func (p PostController) DeletePost(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, resp)
 }
 
+func (p PostController) ListPosts(ctx *gin.Context) {
+	var request dto.PostListRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	respondPostList(ctx, p.postService, request, publishedOnly())
+}
+
+// ListMyPosts lists the caller's posts, drafts and scheduled posts included.
+func (p PostController) ListMyPosts(ctx *gin.Context) {
+	var request dto.MyPostListRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	request.AuthorID = ctx.GetInt("userId")
+	filter := repository.PostListFilter{}
+	if request.Status != "" {
+		filter.Statuses = []models.PostStatus{models.PostStatus(request.Status)}
+	}
+	respondPostList(ctx, p.postService, request.PostListRequest, filter)
+}
+
+// publishedOnly is the base filter of every public post listing.
+func publishedOnly() repository.PostListFilter {
+	return repository.PostListFilter{Statuses: []models.PostStatus{models.PostStatusPublished}}
+}
+
+// respondPostList applies the query filters of request on top of filter and
+// writes one page of posts.
+func respondPostList(ctx *gin.Context, postService services.PostService, request dto.PostListRequest, filter repository.PostListFilter) {
+	filter.AuthorID = request.AuthorID
+	filter.CreatedAfter = request.CreatedAfter
+	filter.CreatedBefore = request.CreatedBefore
+	filter.TitleContains = request.Title
+	page := repository.Page{Sort: repository.Sort(request.Sort), Cursor: request.Cursor, Limit: request.Limit}
+	posts, err := postService.ListPosts(ctx.Request.Context(), filter, page)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	resp := dto.PostListResponse{
+		Message:    "Posts retrieved successfully",
+		Posts:      make([]dto.PostItem, len(posts.Items)),
+		Total:      posts.Total,
+		NextCursor: posts.NextCursor,
+	}
+	for i, post := range posts.Items {
+		resp.Posts[i] = newPostItem(post)
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func newPostItem(post *models.Post) dto.PostItem {
+	tags := make([]string, len(post.Tags))
+	for i, tag := range post.Tags {
+		tags[i] = tag.Name
+	}
+	item := dto.PostItem{
+		PostID:     post.ID,
+		Title:      post.Title,
+		Content:    post.Content,
+		UserID:     post.UserID,
+		CategoryID: post.CategoryID,
+		Tags:       tags,
+		Status:     string(post.Status),
+		CreatedAt:  post.CreatedAt.Format("2006-01-02 15:04:05"),
+		UpdatedAt:  post.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if post.PublishAt != nil && post.Status == models.PostStatusScheduled {
+		item.PublishAt = post.PublishAt.Format("2006-01-02 15:04:05")
+	}
+	if post.PublishedAt != nil {
+		item.PublishedAt = post.PublishedAt.Format("2006-01-02 15:04:05")
+	}
+	return item
+}
+
+func (p PostController) ListRevisions(ctx *gin.Context) {
+	var request dto.PostRetrieveRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	revisions, err := p.postService.ListRevisions(ctx.Request.Context(), currentActor(ctx), request.PostID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	resp := dto.RevisionListResponse{
+		Message:   "Revisions retrieved successfully",
+		Revisions: make([]dto.RevisionItem, len(revisions)),
+	}
+	for i, rev := range revisions {
+		resp.Revisions[i] = newPostRevisionItem(rev)
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (p PostController) RetrieveRevision(ctx *gin.Context) {
+	var request dto.PostRevisionRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	rev, err := p.postService.RetrieveRevision(ctx.Request.Context(), currentActor(ctx), request.PostID, request.Revision)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	resp := dto.RevisionRetrieveResponse{
+		Message:  "Revision retrieved successfully",
+		Revision: newPostRevisionItem(rev),
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (p PostController) DiffRevisions(ctx *gin.Context) {
+	var request dto.PostRetrieveRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	var query dto.RevisionDiffQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	diff, err := p.postService.DiffRevisions(ctx.Request.Context(), currentActor(ctx), request.PostID, query.From, query.To)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newRevisionDiffResponse(diff))
+}
+
+// RestoreRevision copies an old revision back into the post, recording the
+// result as the newest revision.
+func (p PostController) RestoreRevision(ctx *gin.Context) {
+	var request dto.PostRevisionRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	post, err := p.postService.RestoreRevision(ctx.Request.Context(), currentActor(ctx), request.PostID, request.Revision)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	resp := dto.PostUpdateResponse{
+		Message:  "Revision restored successfully",
+		PostItem: newPostItem(post),
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
 func NewPostController(postService services.PostService) *PostController {
 	return &PostController{
 		postService: postService,
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"blog_backend/app/dto"
+	"blog_backend/app/models"
+	"blog_backend/app/services"
+)
+
+func newPostRevisionItem(rev *models.PostRevision) dto.RevisionItem {
+	return dto.RevisionItem{
+		Revision:  rev.Revision,
+		Title:     rev.Title,
+		Content:   rev.Content,
+		EditorID:  rev.EditorID,
+		CreatedAt: rev.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func newCommentRevisionItem(rev *models.CommentRevision) dto.RevisionItem {
+	return dto.RevisionItem{
+		Revision:  rev.Revision,
+		Content:   rev.Content,
+		EditorID:  rev.EditorID,
+		CreatedAt: rev.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func newRevisionDiffResponse(diff *services.RevisionDiff) dto.RevisionDiffResponse {
+	resp := dto.RevisionDiffResponse{
+		Message:   "Diff computed successfully",
+		From:      diff.From,
+		To:        diff.To,
+		FromTitle: diff.FromTitle,
+		ToTitle:   diff.ToTitle,
+		Unified:   diff.Unified,
+		Lines:     make([]dto.DiffLine, len(diff.Lines)),
+	}
+	for i, line := range diff.Lines {
+		resp.Lines[i] = dto.DiffLine{Op: string(line.Op), Text: line.Text, NoNewline: line.NoNewline}
+	}
+	return resp
+}
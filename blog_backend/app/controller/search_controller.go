@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"blog_backend/app/dto"
+	"blog_backend/app/repository"
+	"blog_backend/app/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchController struct {
+	searchService services.SearchService
+}
+
+func (s SearchController) Search(ctx *gin.Context) {
+	var request dto.SearchRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	if request.Limit == 0 {
+		request.Limit = repository.DefaultPageLimit
+	}
+
+	hits, err := s.searchService.Search(ctx.Request.Context(), request.Query, request.Type, request.Limit, request.Offset)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	resp := dto.SearchResponse{
+		Message: "Search completed successfully",
+		Results: make([]dto.SearchItem, len(hits)),
+	}
+	for i, hit := range hits {
+		resp.Results[i] = dto.SearchItem{
+			Type:      hit.Kind,
+			ID:        hit.ID,
+			PostID:    hit.PostID,
+			Title:     hit.Title,
+			Snippet:   hit.Snippet,
+			Rank:      hit.Rank,
+			CreatedAt: hit.CreatedAt.Format("2006-01-02 15:04:05"),
+		}
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func NewSearchController(searchService services.SearchService) *SearchController {
+	return &SearchController{
+		searchService: searchService,
+	}
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"blog_backend/app/dto"
+	"blog_backend/app/repository"
+	"blog_backend/app/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TaxonomyController struct {
+	taxonomyService services.TaxonomyService
+	postService     services.PostService
+}
+
+func (t TaxonomyController) ListTags(ctx *gin.Context) {
+	var request dto.TagListRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	if request.Limit == 0 {
+		request.Limit = repository.MaxPageLimit
+	}
+	t.respondTags(ctx, request.Query, request.Limit)
+}
+
+func (t TaxonomyController) AutocompleteTags(ctx *gin.Context) {
+	var request dto.TagAutocompleteRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	if request.Limit == 0 {
+		request.Limit = 10
+	}
+	t.respondTags(ctx, request.Query, request.Limit)
+}
+
+func (t TaxonomyController) respondTags(ctx *gin.Context, prefix string, limit int) {
+	tags, err := t.taxonomyService.ListTags(ctx.Request.Context(), prefix, limit)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	resp := dto.TagListResponse{
+		Message: "Tags retrieved successfully",
+		Tags:    make([]dto.TagItem, len(tags)),
+	}
+	for i, tag := range tags {
+		resp.Tags[i] = dto.TagItem{
+			Name:      tag.Name,
+			Slug:      tag.Slug,
+			PostCount: tag.PostCount,
+		}
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (t TaxonomyController) ListTagPosts(ctx *gin.Context) {
+	var uriRequest dto.TagPostsRequest
+	if err := ctx.ShouldBindUri(&uriRequest); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	var request dto.PostListRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	tag, err := t.taxonomyService.RetrieveTag(ctx.Request.Context(), uriRequest.Slug)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	filter := publishedOnly()
+	filter.TagSlug = tag.Slug
+	respondPostList(ctx, t.postService, request, filter)
+}
+
+func (t TaxonomyController) CreateCategory(ctx *gin.Context) {
+	var request dto.CategoryCreateRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	category, err := t.taxonomyService.CreateCategory(ctx.Request.Context(), currentActor(ctx), request.Name, request.ParentID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	resp := dto.CategoryCreateResponse{
+		Message: "Category created successfully",
+		CategoryItem: dto.CategoryItem{
+			ID:       category.ID,
+			Name:     category.Name,
+			Slug:     category.Slug,
+			ParentID: category.ParentID,
+		},
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (t TaxonomyController) ListCategories(ctx *gin.Context) {
+	tree, err := t.taxonomyService.CategoryTree(ctx.Request.Context())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	resp := dto.CategoryListResponse{
+		Message:    "Categories retrieved successfully",
+		Categories: newCategoryItems(tree),
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (t TaxonomyController) ListCategoryPosts(ctx *gin.Context) {
+	var uriRequest dto.CategoryPostsRequest
+	if err := ctx.ShouldBindUri(&uriRequest); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	var request dto.PostListRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	ids, err := t.taxonomyService.CategorySubtreeIDs(ctx.Request.Context(), uriRequest.Slug)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	filter := publishedOnly()
+	filter.CategoryIDs = ids
+	respondPostList(ctx, t.postService, request, filter)
+}
+
+func newCategoryItems(nodes []*services.CategoryNode) []dto.CategoryItem {
+	items := make([]dto.CategoryItem, len(nodes))
+	for i, node := range nodes {
+		items[i] = dto.CategoryItem{
+			ID:       node.ID,
+			Name:     node.Name,
+			Slug:     node.Slug,
+			ParentID: node.ParentID,
+			Children: newCategoryItems(node.Children),
+		}
+	}
+	return items
+}
+
+func NewTaxonomyController(taxonomyService services.TaxonomyService, postService services.PostService) *TaxonomyController {
+	return &TaxonomyController{
+		taxonomyService: taxonomyService,
+		postService:     postService,
+	}
+}
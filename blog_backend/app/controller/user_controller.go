@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"blog_backend/app/dto"
+	"blog_backend/app/models"
+	"blog_backend/app/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserController struct {
+	userService services.UserService
+}
+
+func (u UserController) UpdateRole(ctx *gin.Context) {
+	var uriRequest dto.UserRoleUpdateURIRequest
+	if err := ctx.ShouldBindUri(&uriRequest); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	var request dto.UserRoleUpdateBodyRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	user, err := u.userService.UpdateRole(ctx.Request.Context(), currentActor(ctx), uriRequest.UserID, models.Role(request.Role))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	resp := dto.UserRoleUpdateResponse{
+		Message: "User role updated successfully",
+		UserID:  user.ID,
+		Role:    string(user.Role),
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (u UserController) ResetMFA(ctx *gin.Context) {
+	var uriRequest dto.UserURIRequest
+	if err := ctx.ShouldBindUri(&uriRequest); err != nil {
+		ctx.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := u.userService.ResetMFA(ctx.Request.Context(), currentActor(ctx), uriRequest.UserID); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.MessageResponse{Message: "Two-factor authentication reset"})
+}
+
+func NewUserController(userService services.UserService) *UserController {
+	return &UserController{
+		userService: userService,
+	}
+}
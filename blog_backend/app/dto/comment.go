@@ -1,9 +1,10 @@
 package dto
 
 type CommentCreateRequest struct {
-	PostID  int    `json:"post_id" binding:"required"`
-	UserID  int    `json:"user_id" binding:"required"`
-	Content string `json:"content" binding:"required"`
+	PostID   int    `json:"post_id" binding:"required"`
+	UserID   int    `json:"user_id" binding:"required"`
+	ParentID *int   `json:"parent_id" binding:"omitempty,min=1"`
+	Content  string `json:"content" binding:"required"`
 }
 
 type CommentCreateResponse struct {
@@ -45,9 +46,28 @@ type ListCommentsRequest struct {
 	PostID int `uri:"post_id" binding:"required"`
 }
 
+// ListCommentsQuery selects how comments are listed: flat in creation order,
+// as a tree of threads, or as threads flattened in path order. Threaded modes
+// page by top-level comment.
+type ListCommentsQuery struct {
+	Mode   string `form:"mode" binding:"omitempty,oneof=flat tree thread"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Sort   string `form:"sort" binding:"omitempty,oneof=created_at -created_at"`
+}
+
 type ListCommentsResponse struct {
-	Message  string        `json:"message"`
-	Comments []CommentItem `json:"comments"`
+	Message    string        `json:"message"`
+	Comments   []CommentItem `json:"comments"`
+	Total      int64         `json:"total"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type CommentTreeResponse struct {
+	Message    string            `json:"message"`
+	Threads    []CommentTreeItem `json:"threads"`
+	Total      int64             `json:"total"`
+	NextCursor string            `json:"next_cursor,omitempty"`
 }
 
 type CommentItem struct {
@@ -55,5 +75,14 @@ type CommentItem struct {
 	Content   string `json:"content"`
 	UserID    int    `json:"user_id"`
 	PostID    int    `json:"post_id"`
+	ParentID  *int   `json:"parent_id"`
+	Depth     int    `json:"depth"`
+	Path      string `json:"path"`
+	Deleted   bool   `json:"deleted,omitempty"`
 	CreatedAt string `json:"created_at"`
 }
+
+type CommentTreeItem struct {
+	CommentItem
+	Replies []CommentTreeItem `json:"replies"`
+}
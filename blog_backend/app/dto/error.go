@@ -0,0 +1,17 @@
+package dto
+
+// ErrorResponse is the body of every error response. Code is a stable,
+// machine-readable name for the kind of failure; Details lists the invalid
+// fields of a rejected request.
+type ErrorResponse struct {
+	Code      string        `json:"code"`
+	Message   string        `json:"message"`
+	Details   []ErrorDetail `json:"details"`
+	RequestID string        `json:"request_id"`
+}
+
+type ErrorDetail struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,8 @@
+package dto
+
+// HealthResponse answers the probes. Checks maps each readiness check to "ok"
+// or to the reason it failed.
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
@@ -1,8 +1,14 @@
 package dto
 
+import "time"
+
 type PostCreateRequest struct {
-	Title   string `json:"title" binding:"required,min=3,max=100"`
-	Content string `json:"content" binding:"required,min=10"`
+	Title      string     `json:"title" binding:"required,min=3,max=100"`
+	Content    string     `json:"content" binding:"required,min=10"`
+	Tags       []string   `json:"tags" binding:"omitempty,max=10,dive,min=1,max=50"`
+	CategoryID *int       `json:"category_id" binding:"omitempty,min=1"`
+	Status     string     `json:"status" binding:"omitempty,oneof=draft scheduled published"`
+	PublishAt  *time.Time `json:"publish_at" binding:"required_if=Status scheduled"`
 }
 
 type PostCreateResponse struct {
@@ -20,9 +26,13 @@ type PostRetrieveResponse struct {
 }
 
 type PostUpdateRequest struct {
-	PostID  int    `json:"post_id" binding:"required"`
-	Title   string `json:"title" binding:"required,min=3,max=100"`
-	Content string `json:"content" binding:"required,min=10"`
+	PostID     int        `json:"post_id" binding:"required"`
+	Title      string     `json:"title" binding:"required,min=3,max=100"`
+	Content    string     `json:"content" binding:"required,min=10"`
+	Tags       []string   `json:"tags" binding:"omitempty,max=10,dive,min=1,max=50"`
+	CategoryID *int       `json:"category_id" binding:"omitempty,min=1"`
+	Status     string     `json:"status" binding:"omitempty,oneof=draft scheduled published archived"`
+	PublishAt  *time.Time `json:"publish_at" binding:"required_if=Status scheduled"`
 }
 
 type PostUpdateResponse struct {
@@ -38,11 +48,43 @@ type PostDeleteResponse struct {
 	Message string `json:"message"`
 }
 
+type PostListRequest struct {
+	AuthorID      int       `form:"author_id" binding:"omitempty,min=1"`
+	CreatedAfter  time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	Title         string    `form:"title" binding:"omitempty,max=100"`
+	Sort          string    `form:"sort" binding:"omitempty,oneof=created_at -created_at updated_at -updated_at"`
+	Cursor        string    `form:"cursor"`
+	Limit         int       `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// MyPostListRequest lists the caller's own posts in any status.
+type MyPostListRequest struct {
+	PostListRequest
+	Status string `form:"status" binding:"omitempty,oneof=draft scheduled published archived"`
+}
+
+type PostListResponse struct {
+	Message    string     `json:"message"`
+	Posts      []PostItem `json:"posts"`
+	Total      int64      `json:"total"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+type TagPostsRequest struct {
+	Slug string `uri:"slug" binding:"required"`
+}
+
 type PostItem struct {
-	PostID    int    `json:"post_id"`
-	Title     string `json:"title"`
-	Content   string `json:"content"`
-	UserID    int    `json:"user_id"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	PostID      int      `json:"post_id"`
+	Title       string   `json:"title"`
+	Content     string   `json:"content"`
+	UserID      int      `json:"user_id"`
+	CategoryID  *int     `json:"category_id"`
+	Tags        []string `json:"tags"`
+	Status      string   `json:"status"`
+	PublishAt   string   `json:"publish_at,omitempty"`
+	PublishedAt string   `json:"published_at,omitempty"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
 }
@@ -0,0 +1,50 @@
+package dto
+
+type PostRevisionRequest struct {
+	PostID   int `uri:"post_id" binding:"required"`
+	Revision int `uri:"revision" binding:"required,min=1"`
+}
+
+type CommentRevisionRequest struct {
+	CommentID int `uri:"comment_id" binding:"required"`
+	Revision  int `uri:"revision" binding:"required,min=1"`
+}
+
+type RevisionDiffQuery struct {
+	From int `form:"from" binding:"required,min=1"`
+	To   int `form:"to" binding:"required,min=1"`
+}
+
+type RevisionItem struct {
+	Revision  int    `json:"revision"`
+	Title     string `json:"title,omitempty"`
+	Content   string `json:"content"`
+	EditorID  int    `json:"editor_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+type RevisionListResponse struct {
+	Message   string         `json:"message"`
+	Revisions []RevisionItem `json:"revisions"`
+}
+
+type RevisionRetrieveResponse struct {
+	Message  string       `json:"message"`
+	Revision RevisionItem `json:"revision"`
+}
+
+type DiffLine struct {
+	Op        string `json:"op"`
+	Text      string `json:"text"`
+	NoNewline bool   `json:"no_newline,omitempty"`
+}
+
+type RevisionDiffResponse struct {
+	Message   string     `json:"message"`
+	From      int        `json:"from"`
+	To        int        `json:"to"`
+	FromTitle string     `json:"from_title,omitempty"`
+	ToTitle   string     `json:"to_title,omitempty"`
+	Unified   string     `json:"unified"`
+	Lines     []DiffLine `json:"lines"`
+}
@@ -0,0 +1,23 @@
+package dto
+
+type SearchRequest struct {
+	Query  string `form:"q" binding:"required,max=200"`
+	Type   string `form:"type" binding:"omitempty,oneof=post comment"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int    `form:"offset" binding:"omitempty,min=0,max=1000"`
+}
+
+type SearchResponse struct {
+	Message string       `json:"message"`
+	Results []SearchItem `json:"results"`
+}
+
+type SearchItem struct {
+	Type      string  `json:"type"`
+	ID        int     `json:"id"`
+	PostID    int     `json:"post_id"`
+	Title     string  `json:"title"`
+	Snippet   string  `json:"snippet"`
+	Rank      float64 `json:"rank"`
+	CreatedAt string  `json:"created_at"`
+}
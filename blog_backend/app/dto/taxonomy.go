@@ -0,0 +1,49 @@
+package dto
+
+type TagListRequest struct {
+	Query string `form:"q" binding:"omitempty,max=50"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type TagAutocompleteRequest struct {
+	Query string `form:"q" binding:"required,max=50"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=20"`
+}
+
+type TagListResponse struct {
+	Message string    `json:"message"`
+	Tags    []TagItem `json:"tags"`
+}
+
+type TagItem struct {
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	PostCount int64  `json:"post_count"`
+}
+
+type CategoryCreateRequest struct {
+	Name     string `json:"name" binding:"required,min=2,max=100"`
+	ParentID *int   `json:"parent_id" binding:"omitempty,min=1"`
+}
+
+type CategoryCreateResponse struct {
+	Message      string       `json:"message"`
+	CategoryItem CategoryItem `json:"category_item"`
+}
+
+type CategoryListResponse struct {
+	Message    string         `json:"message"`
+	Categories []CategoryItem `json:"categories"`
+}
+
+type CategoryPostsRequest struct {
+	Slug string `uri:"slug" binding:"required"`
+}
+
+type CategoryItem struct {
+	ID       int            `json:"id"`
+	Name     string         `json:"name"`
+	Slug     string         `json:"slug"`
+	ParentID *int           `json:"parent_id"`
+	Children []CategoryItem `json:"children,omitempty"`
+}
@@ -1,5 +1,7 @@
 package dto
 
+import "time"
+
 type UserRegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=100"`
 	Password string `json:"password" binding:"required,min=6,max=100"`
@@ -7,10 +9,11 @@ type UserRegisterRequest struct {
 }
 
 type UserRegisterResponse struct {
-	Message  string `json:"message"`
-	UserID   int    `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	Message       string `json:"message"`
+	UserID        int    `json:"user_id"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
 }
 
 type LoginRequest struct {
@@ -19,6 +22,145 @@ type LoginRequest struct {
 }
 
 type LoginPesponse struct {
+	Message      string `json:"message"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// MFAChallengeResponse answers a login with the right password for an
+// account with two-factor authentication, which MFAToken completes.
+type MFAChallengeResponse struct {
+	Message     string `json:"message"`
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required,max=100"`
+	Code     string `json:"code" binding:"required,max=32"`
+}
+
+type MFAEnrollResponse struct {
+	Message    string `json:"message"`
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	// QRCodePNG is encoded in base64, like every []byte
+	QRCodePNG []byte `json:"qr_code_png"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+type MFARecoveryCodesResponse struct {
+	Message       string   `json:"message"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// SIWENonceResponse gives what a wallet needs to write the message it
+// signs to sign in.
+type SIWENonceResponse struct {
+	Nonce     string  `json:"nonce"`
+	Domain    string  `json:"domain"`
+	ChainIDs  []int64 `json:"chain_ids"`
+	ExpiresIn int     `json:"expires_in"`
+}
+
+type SIWEVerifyRequest struct {
+	Message   string `json:"message" binding:"required,max=4000"`
+	Signature string `json:"signature" binding:"required,max=200"`
+}
+
+type WalletLinkResponse struct {
+	Message       string `json:"message"`
+	WalletAddress string `json:"wallet_address"`
+}
+
+type AccessTokenCreateRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=read posts:write comments:write"`
+	// ExpiresAt is left out for a token that does not expire
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type AccessTokenURIRequest struct {
+	TokenID int `uri:"token_id" binding:"required"`
+}
+
+type AccessTokenItem struct {
+	TokenID    int      `json:"token_id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+// AccessTokenCreateResponse is the only time Token is shown.
+type AccessTokenCreateResponse struct {
 	Message string `json:"message"`
 	Token   string `json:"token"`
+	AccessTokenItem
+}
+
+type AccessTokenListResponse struct {
+	Message      string            `json:"message"`
+	AccessTokens []AccessTokenItem `json:"access_tokens"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type RefreshTokenResponse struct {
+	Message      string `json:"message"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutResponse struct {
+	Message string `json:"message"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required,max=100"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email,max=100"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required,max=100"`
+	Password string `json:"password" binding:"required,min=6,max=100"`
+}
+
+// MessageResponse acknowledges a request that returns nothing else.
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+type UserURIRequest struct {
+	UserID int `uri:"user_id" binding:"required"`
+}
+
+type UserRoleUpdateURIRequest struct {
+	UserID int `uri:"user_id" binding:"required"`
+}
+
+type UserRoleUpdateBodyRequest struct {
+	Role string `json:"role" binding:"required,oneof=user moderator admin"`
+}
+
+type UserRoleUpdateResponse struct {
+	Message string `json:"message"`
+	UserID  int    `json:"user_id"`
+	Role    string `json:"role"`
 }
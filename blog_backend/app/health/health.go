@@ -0,0 +1,37 @@
+// Package health holds the checks behind the readiness probe.
+package health
+
+import (
+	"blog_backend/migrations"
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Check is one condition the server needs to take traffic. Check returns nil
+// when the condition holds.
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Database checks that the database answers a ping.
+func Database(db *gorm.DB) Check {
+	return Check{Name: "database", Check: func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}}
+}
+
+// Migrations checks that the schema has every migration this build expects.
+func Migrations(db *gorm.DB) (Check, error) {
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		return Check{}, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return Check{Name: "migrations", Check: migrator.CheckCurrent}, nil
+}
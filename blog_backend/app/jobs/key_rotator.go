@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"blog_backend/app/services"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// KeyRotator periodically rotates the keys access tokens are signed with,
+// and reloads those other instances created.
+type KeyRotator struct {
+	signingKeyService services.SigningKeyService
+	interval          time.Duration
+	logger            *slog.Logger
+}
+
+// Run rotates the keys every interval until ctx is cancelled. App rotates
+// them once before serving, so the first pass waits for the ticker.
+func (r *KeyRotator) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		r.rotate(ctx)
+	}
+}
+
+func (r *KeyRotator) rotate(ctx context.Context) {
+	rotation, err := r.signingKeyService.RotateKeys(ctx, time.Now())
+	if err != nil {
+		r.logger.ErrorContext(ctx, "failed to rotate signing keys", "error", err)
+		return
+	}
+	if rotation.Created != nil {
+		r.logger.InfoContext(ctx, "published signing key", "kid", rotation.Created.ID,
+			"activates_at", rotation.Created.ActivatesAt)
+	}
+	for _, kid := range rotation.Retired {
+		r.logger.InfoContext(ctx, "retired signing key", "kid", kid)
+	}
+}
+
+func NewKeyRotator(signingKeyService services.SigningKeyService, interval time.Duration, logger *slog.Logger) *KeyRotator {
+	return &KeyRotator{
+		signingKeyService: signingKeyService,
+		interval:          interval,
+		logger:            logger,
+	}
+}
@@ -0,0 +1,52 @@
+// Package jobs holds the background workers started by App.
+package jobs
+
+import (
+	"blog_backend/app/services"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// PostPublisher periodically publishes scheduled posts that have come due.
+// All state lives in the database, so nothing is lost across restarts and
+// several instances can run it side by side.
+type PostPublisher struct {
+	postService services.PostService
+	interval    time.Duration
+	logger      *slog.Logger
+}
+
+// Run publishes due posts every interval until ctx is cancelled. The first
+// pass runs immediately so posts that came due while the server was down go
+// out at startup.
+func (p *PostPublisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.publish(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *PostPublisher) publish(ctx context.Context) {
+	count, err := p.postService.PublishDuePosts(ctx, time.Now())
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to publish scheduled posts", "error", err)
+	}
+	if count > 0 {
+		p.logger.InfoContext(ctx, "published scheduled posts", "count", count)
+	}
+}
+
+func NewPostPublisher(postService services.PostService, interval time.Duration, logger *slog.Logger) *PostPublisher {
+	return &PostPublisher{
+		postService: postService,
+		interval:    interval,
+		logger:      logger,
+	}
+}
@@ -0,0 +1,155 @@
+// Package keyring holds the keys access tokens are signed with: the key
+// signing now, the keys about to replace it and the keys it replaced, which
+// still verify the tokens they signed. It publishes their public halves as
+// a JSON Web Key Set (RFC 7517), so that other services verify tokens
+// without sharing a secret.
+package keyring
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms, named as in the alg header of a JWT
+const (
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// rsaKeyBits is the size of generated RSA keys
+const rsaKeyBits = 2048
+
+var ErrNoSigningKey = errors.New("no signing key is active")
+
+// Key is a key pair that signs tokens from ActivatesAt on.
+type Key struct {
+	// ID is the kid header of the tokens it signs, the RFC 7638 thumbprint
+	// of its public key
+	ID          string
+	Algorithm   string
+	Private     crypto.Signer
+	ActivatesAt time.Time
+}
+
+// NewKey wraps a private key of the algorithm.
+func NewKey(algorithm string, private crypto.Signer, activatesAt time.Time) (*Key, error) {
+	switch private.Public().(type) {
+	case *rsa.PublicKey:
+		if algorithm != RS256 {
+			return nil, fmt.Errorf("an RSA key cannot sign %s", algorithm)
+		}
+	case ed25519.PublicKey:
+		if algorithm != EdDSA {
+			return nil, fmt.Errorf("an Ed25519 key cannot sign %s", algorithm)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", private.Public())
+	}
+	key := &Key{Algorithm: algorithm, Private: private, ActivatesAt: activatesAt}
+	key.ID = key.JWK().Thumbprint()
+	return key, nil
+}
+
+// Generate creates a key pair for the algorithm.
+func Generate(algorithm string, activatesAt time.Time) (*Key, error) {
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case RS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", algorithm, err)
+	}
+	return NewKey(algorithm, private, activatesAt)
+}
+
+// SigningMethod is the method the key signs tokens with.
+func (k *Key) SigningMethod() jwt.SigningMethod {
+	if k.Algorithm == EdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// Keyring is the set of published keys. It is safe for concurrent use.
+type Keyring struct {
+	mu sync.RWMutex
+	// keys are sorted by ActivatesAt
+	keys []*Key
+}
+
+func New() *Keyring {
+	return &Keyring{}
+}
+
+// Set replaces the keys.
+func (r *Keyring) Set(keys []*Key) {
+	keys = slices.Clone(keys)
+	slices.SortStableFunc(keys, func(a, b *Key) int { return a.ActivatesAt.Compare(b.ActivatesAt) })
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = keys
+}
+
+// Keys returns the keys, sorted by ActivatesAt.
+func (r *Keyring) Keys() []*Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.keys)
+}
+
+// Signing returns the key that signs at now: the last one activated.
+func (r *Keyring) Signing(now time.Time) (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return signing(r.keys, now)
+}
+
+// Lookup returns the key with a kid, to verify a token with.
+func (r *Keyring) Lookup(kid string) (*Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if key.ID == kid {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// JWKS returns the public keys, including those not signing yet so that
+// verifiers know them in advance.
+func (r *Keyring) JWKS() JWKSet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	set := JWKSet{Keys: make([]JWK, len(r.keys))}
+	for i, key := range r.keys {
+		set.Keys[i] = key.JWK()
+	}
+	return set
+}
+
+// signing returns the last key of keys, sorted by ActivatesAt, activated
+// at now.
+func signing(keys []*Key, now time.Time) (*Key, error) {
+	for i := len(keys) - 1; i >= 0; i-- {
+		if !keys[i].ActivatesAt.After(now) {
+			return keys[i], nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
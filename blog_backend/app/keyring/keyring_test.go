@@ -0,0 +1,113 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestThumbprint(t *testing.T) {
+	// The Ed25519 example of RFC 8037, appendix A.3
+	x, _ := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	seed, _ := base64.RawURLEncoding.DecodeString("nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A")
+	private := ed25519.NewKeyFromSeed(seed)
+	if !private.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(x)) {
+		t.Fatal("seed does not match the public key")
+	}
+	key, err := NewKey(EdDSA, private, time.Time{})
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+	if key.ID != "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k" {
+		t.Fatalf("got kid %s", key.ID)
+	}
+	if _, err := NewKey(RS256, private, time.Time{}); err == nil {
+		t.Fatal("accepted an Ed25519 key for RS256")
+	}
+}
+
+func TestSealPrivateKey(t *testing.T) {
+	for _, algorithm := range []string{EdDSA, RS256} {
+		key, err := Generate(algorithm, time.Now())
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		sealed, err := SealPrivateKey(key, "secret")
+		if err != nil {
+			t.Fatalf("SealPrivateKey: %v", err)
+		}
+		private, err := OpenPrivateKey(sealed, key.ID, "secret")
+		if err != nil {
+			t.Fatalf("OpenPrivateKey: %v", err)
+		}
+		if opened, _ := NewKey(algorithm, private, key.ActivatesAt); opened.ID != key.ID {
+			t.Fatalf("%s: opened another key", algorithm)
+		}
+		if _, err := OpenPrivateKey(sealed, key.ID, "other secret"); err == nil {
+			t.Fatalf("%s: opened with another secret", algorithm)
+		}
+		if _, err := OpenPrivateKey(sealed, "other kid", "secret"); err == nil {
+			t.Fatalf("%s: opened for another key", algorithm)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	schedule := Schedule{Rotation: 30 * 24 * time.Hour, Prepublish: 24 * time.Hour, TokenTTL: 15 * time.Minute}
+	key := func(activatesAt time.Time) *Key {
+		k, err := Generate(EdDSA, activatesAt)
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		return k
+	}
+
+	// The first key signs at once
+	if next, _ := schedule.Plan(nil, start); next == nil || !next.Equal(start) {
+		t.Fatalf("no key: next = %v", next)
+	}
+	first := key(start)
+	keys := []*Key{first}
+	if next, _ := schedule.Plan(keys, start.Add(28*24*time.Hour)); next != nil {
+		t.Fatalf("next = %v before the rotation is due", next)
+	}
+	// The next key is published a day ahead
+	due := start.Add(schedule.Rotation)
+	next, _ := schedule.Plan(keys, due.Add(-schedule.Prepublish))
+	if next == nil || !next.Equal(due) {
+		t.Fatalf("next = %v, want %v", next, due)
+	}
+	second := key(*next)
+	keys = append(keys, second)
+	if next, _ := schedule.Plan(keys, due.Add(-time.Hour)); next != nil {
+		t.Fatalf("next = %v while a key is pending", next)
+	}
+
+	ring := New()
+	ring.Set(keys)
+	if signing, _ := ring.Signing(due.Add(-time.Second)); signing != first {
+		t.Fatal("the pending key signs early")
+	}
+	if signing, _ := ring.Signing(due); signing != second {
+		t.Fatal("the pending key does not sign when due")
+	}
+	if len(ring.JWKS().Keys) != 2 {
+		t.Fatal("the pending key is not published")
+	}
+
+	// The replaced key is retired once its tokens expired
+	if _, retired := schedule.Plan(keys, due.Add(schedule.TokenTTL-time.Second)); len(retired) != 0 {
+		t.Fatal("retired a key whose tokens are valid")
+	}
+	if _, retired := schedule.Plan(keys, due.Add(schedule.TokenTTL)); len(retired) != 1 || retired[0] != first {
+		t.Fatalf("retired %v", retired)
+	}
+
+	// An overdue rotation still publishes the key ahead
+	late := due.Add(schedule.Rotation + time.Hour)
+	if next, _ := schedule.Plan([]*Key{second}, late); next == nil || !next.Equal(late.Add(schedule.Prepublish)) {
+		t.Fatalf("overdue: next = %v", next)
+	}
+}
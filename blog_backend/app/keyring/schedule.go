@@ -0,0 +1,53 @@
+package keyring
+
+import (
+	"slices"
+	"time"
+)
+
+// Schedule says when keys are replaced. Each key signs for Rotation, and is
+// published Prepublish before that, so that verifiers caching the key set
+// know it before it signs. A replaced key stays published for TokenTTL, as
+// long as the tokens it signed are valid.
+type Schedule struct {
+	Rotation   time.Duration
+	Prepublish time.Duration
+	TokenTTL   time.Duration
+}
+
+// Plan works out what is due at now for keys. next is when the key to
+// create now should activate, or nil when none is due; retired are the
+// keys to drop.
+func (s Schedule) Plan(keys []*Key, now time.Time) (next *time.Time, retired []*Key) {
+	keys = slices.Clone(keys)
+	slices.SortStableFunc(keys, func(a, b *Key) int { return a.ActivatesAt.Compare(b.ActivatesAt) })
+
+	current, err := signing(keys, now)
+	if err != nil {
+		// Nothing can sign: a key is needed at once
+		return &now, nil
+	}
+	for i, key := range keys {
+		if key == current {
+			break
+		}
+		// Replaced by the next key when it activated
+		if !now.Before(keys[i+1].ActivatesAt.Add(s.TokenTTL)) {
+			retired = append(retired, key)
+		}
+	}
+	if keys[len(keys)-1] != current {
+		// The next key is already published
+		return nil, retired
+	}
+	due := current.ActivatesAt.Add(s.Rotation)
+	if now.Before(due.Add(-s.Prepublish)) {
+		return nil, retired
+	}
+	// A key due while nobody rotated is still published for Prepublish
+	// before it signs
+	if earliest := now.Add(s.Prepublish); due.Before(earliest) {
+		due = earliest
+	}
+	return &due, retired
+}
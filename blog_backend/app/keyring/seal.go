@@ -0,0 +1,80 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// sealInfo binds the encryption key derived from the secret to this use of it
+const sealInfo = "blog_backend signing key"
+
+var errUnsealed = errors.New("cannot decrypt the private key, the secret may have changed")
+
+// SealPrivateKey encrypts the private key of k with a key derived from
+// secret, for storage. The result is the base64 of an AES-256-GCM nonce and
+// ciphertext of the PKCS #8 form of the key.
+func SealPrivateKey(k *Key, secret string) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode private key: %w", err)
+	}
+	aead, err := sealCipher(secret)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, der, []byte(k.ID))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenPrivateKey decrypts a private key sealed by SealPrivateKey for the key
+// with the kid.
+func OpenPrivateKey(sealed, kid, secret string) (crypto.Signer, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sealed private key: %w", err)
+	}
+	aead, err := sealCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errUnsealed
+	}
+	der, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(kid))
+	if err != nil {
+		return nil, errUnsealed
+	}
+	private, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", private)
+	}
+	return signer, nil
+}
+
+func sealCipher(secret string) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, []byte(secret), nil, sealInfo, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"encoding"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the value of attributes that may hold a secret.
+const Redacted = "[REDACTED]"
+
+// sensitiveWords are the key fragments naming a secret, compared after
+// lowercasing the key and dropping separators, so "refresh_token" and
+// "X-Api-Key" match.
+var sensitiveWords = []string{"password", "passwd", "secret", "token", "authorization", "cookie", "apikey", "otp", "recoverycode"}
+
+// jwtPattern matches strings shaped like a JWT, which are redacted whatever
+// their key.
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+// IsSensitive reports whether a field or attribute named key may hold a
+// secret.
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	key = strings.NewReplacer("_", "", "-", "", ".", "", " ", "").Replace(key)
+	for _, word := range sensitiveWords {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactQuery returns the raw query string with the values of sensitive
+// parameters replaced, for logging request URLs.
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Redacted
+	}
+	for key, vals := range values {
+		for i := range vals {
+			if IsSensitive(key) || jwtPattern.MatchString(vals[i]) {
+				vals[i] = Redacted
+			}
+		}
+	}
+	return values.Encode()
+}
+
+// redactAttr is the ReplaceAttr function of the handlers. Strings and
+// arbitrary values under a sensitive key are replaced; numbers, durations
+// and the like cannot hold a secret and are kept. Structs and maps are
+// turned into groups, which the handler passes back here field by field.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if IsSensitive(a.Key) {
+			return slog.String(a.Key, Redacted)
+		}
+		if s := a.Value.String(); strings.Contains(s, "eyJ") {
+			return slog.String(a.Key, jwtPattern.ReplaceAllString(s, Redacted))
+		}
+	case slog.KindAny:
+		if IsSensitive(a.Key) {
+			return slog.String(a.Key, Redacted)
+		}
+		if group, ok := groupOf(a.Value.Any()); ok {
+			return slog.Attr{Key: a.Key, Value: group}
+		}
+	}
+	return a
+}
+
+// groupOf turns a struct, or a map with string keys, into a group value with
+// one attribute per exported field or entry. Fields are named by their json
+// tag. Types with their own text form are left alone.
+func groupOf(v any) (slog.Value, bool) {
+	switch v.(type) {
+	case nil, error, fmt.Stringer, encoding.TextMarshaler:
+		return slog.Value{}, false
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return slog.Value{}, false
+		}
+		rv = rv.Elem()
+	}
+
+	var attrs []slog.Attr
+	switch rv.Kind() {
+	case reflect.Struct:
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			field := rt.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			attrs = append(attrs, slog.Any(name, rv.Field(i).Interface()))
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return slog.Value{}, false
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			attrs = append(attrs, slog.Any(iter.Key().String(), iter.Value().Interface()))
+		}
+	default:
+		return slog.Value{}, false
+	}
+	return slog.GroupValue(attrs...), true
+}
@@ -0,0 +1,113 @@
+// Package mail sends the emails of the server. Messages are rendered from
+// templates and handed to a Mailer, which delivers them over SMTP, writes
+// them to an outbox directory or keeps them in memory.
+package mail
+
+import (
+	"blog_backend/app/config"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+var errHeaderInjection = errors.New("line break in mail header")
+
+// New returns the mailer configured by MailTransport.
+func New(cfg *config.Config) (Mailer, error) {
+	switch cfg.MailTransport {
+	case "smtp":
+		return NewSMTPMailer(SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			TLS:      cfg.SMTPTLS,
+		}), nil
+	case "file":
+		return NewFileMailer(cfg.MailOutboxDir), nil
+	case "memory":
+		return NewMemoryMailer(), nil
+	}
+	return nil, fmt.Errorf("unknown mail transport %q", cfg.MailTransport)
+}
+
+// Bytes formats msg as an RFC 5322 message, its body quoted-printable.
+func (msg *Message) Bytes() ([]byte, error) {
+	for _, value := range []string{msg.From, msg.To, msg.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, errHeaderInjection
+		}
+	}
+	domain := "localhost"
+	if from, err := mail.ParseAddress(msg.From); err == nil {
+		if _, host, ok := strings.Cut(from.Address, "@"); ok {
+			domain = host
+		}
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", msg.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	text := strings.ReplaceAll(msg.Text, "\n", "\r\n")
+	if !fitsSMTPLines(text) {
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		body := quotedprintable.NewWriter(&buf)
+		body.Write([]byte(text))
+		if err := body.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	// Links stay intact for whoever reads the outbox
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(text)
+	return buf.Bytes(), nil
+}
+
+// fitsSMTPLines reports whether text can be sent as is: SMTP limits lines
+// to 998 octets and has no use for bare carriage returns.
+func fitsSMTPLines(text string) bool {
+	for _, line := range strings.Split(text, "\r\n") {
+		if len(line) > 998 || strings.Contains(line, "\r") {
+			return false
+		}
+	}
+	return true
+}
+
+// address returns the bare address of an address header, for the SMTP
+// envelope.
+func address(header string) (string, error) {
+	addr, err := mail.ParseAddress(header)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %w", header, err)
+	}
+	return addr.Address, nil
+}
@@ -0,0 +1,151 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplates(t *testing.T) {
+	templates, err := NewTemplates("", "Blog <no-reply@example.com>")
+	if err != nil {
+		t.Fatalf("NewTemplates: %v", err)
+	}
+	msg, err := templates.Render(TemplateResetPassword, "alice@example.com", Data{
+		Username:  "alice",
+		Link:      "https://blog.example.com/reset-password?token=abc",
+		ExpiresIn: 90 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if msg.Subject != "Reset your password" || msg.From != "Blog <no-reply@example.com>" || msg.To != "alice@example.com" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+	for _, want := range []string{"Hi alice,", "https://blog.example.com/reset-password?token=abc", "expires in 90 minutes"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Fatalf("body lacks %q:\n%s", want, msg.Text)
+		}
+	}
+
+	// Templates in the directory replace the built-in ones
+	dir := t.TempDir()
+	custom := "Subject: Bienvenue {{.Username}}\n\nCliquez sur {{.Link}} avant {{duration .ExpiresIn}}.\n"
+	if err := os.WriteFile(filepath.Join(dir, TemplateVerifyEmail+".txt"), []byte(custom), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	templates, err = NewTemplates(dir, "no-reply@example.com")
+	if err != nil {
+		t.Fatalf("NewTemplates: %v", err)
+	}
+	msg, err = templates.Render(TemplateVerifyEmail, "alice@example.com", Data{Username: "alice", Link: "L", ExpiresIn: 48 * time.Hour})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if msg.Subject != "Bienvenue alice" || msg.Text != "Cliquez sur L avant 2 days.\n" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	if _, err := NewTemplates("", "not an address"); err == nil {
+		t.Fatal("invalid sender accepted")
+	}
+}
+
+func TestHeaderInjection(t *testing.T) {
+	msg := &Message{From: "a@example.com", To: "b@example.com\r\nBcc: c@example.com", Subject: "Hi"}
+	if _, err := msg.Bytes(); err == nil {
+		t.Fatal("line break in a header accepted")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	mailer := NewFileMailer(dir)
+	err := mailer.Send(context.Background(), &Message{From: "a@example.com", To: "b@example.com", Subject: "Héllo", Text: "Body\n"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("outbox holds %v, want one message", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	for _, want := range []string{"To: b@example.com\r\n", "Subject: =?utf-8?q?H=C3=A9llo?=\r\n", "\r\n\r\nBody\r\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("message lacks %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan []string, 1)
+	go serveSMTP(listener, received)
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	mailer := NewSMTPMailer(SMTPConfig{Host: host, Port: port, TLS: "none"})
+	err = mailer.Send(context.Background(), &Message{From: "Blog <a@example.com>", To: "b@example.com", Subject: "Hi", Text: "Body\n"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	commands := <-received
+	if len(commands) < 5 || !strings.HasPrefix(commands[1], "MAIL FROM:<a@example.com>") ||
+		commands[2] != "RCPT TO:<b@example.com>" || commands[3] != "DATA" {
+		t.Fatalf("unexpected SMTP session %q", commands)
+	}
+	if body := commands[4]; !strings.Contains(body, "Subject: Hi") || !strings.Contains(body, "Body") {
+		t.Fatalf("unexpected message %q", body)
+	}
+
+	// STARTTLS is required unless disabled
+	go serveSMTP(listener, received)
+	mailer = NewSMTPMailer(SMTPConfig{Host: host, Port: port, TLS: "starttls"})
+	if err := mailer.Send(context.Background(), &Message{From: "a@example.com", To: "b@example.com"}); err == nil {
+		t.Fatal("sent without STARTTLS")
+	}
+}
+
+// serveSMTP answers one SMTP session and sends its commands, followed by
+// the message, to received.
+func serveSMTP(listener net.Listener, received chan<- []string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ready")
+	var commands []string
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			received <- commands
+			return
+		}
+		commands = append(commands, line)
+		switch verb := strings.ToUpper(strings.Fields(line + " x")[0]); verb {
+		case "EHLO":
+			text.PrintfLine("250 localhost")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			body, _ := text.ReadDotBytes()
+			commands = append(commands, string(body))
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			received <- commands
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
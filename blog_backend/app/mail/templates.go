@@ -0,0 +1,109 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Templates of the emails the server sends. Each is a text template named
+// <name>.txt whose first line is the subject, as in "Subject: Welcome",
+// followed by a blank line and the body.
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplateResetPassword = "reset_password"
+)
+
+var templateNames = []string{TemplateVerifyEmail, TemplateResetPassword}
+
+//go:embed templates
+var builtinTemplates embed.FS
+
+// Data is what templates are rendered with.
+type Data struct {
+	Username string
+	// Link carries the token the email is about
+	Link      string
+	ExpiresIn time.Duration
+}
+
+// Templates renders messages from an address.
+type Templates struct {
+	from      string
+	templates map[string]*template.Template
+}
+
+// NewTemplates parses the templates, taking those found in dir over the
+// built-in ones when dir is not empty.
+func NewTemplates(dir, from string) (*Templates, error) {
+	if _, err := address(from); err != nil {
+		return nil, fmt.Errorf("invalid sender: %w", err)
+	}
+	t := &Templates{from: from, templates: map[string]*template.Template{}}
+	for _, name := range templateNames {
+		file := name + ".txt"
+		text, err := fs.ReadFile(builtinTemplates, "templates/"+file)
+		if err != nil {
+			return nil, err
+		}
+		if dir != "" {
+			custom, err := os.ReadFile(filepath.Join(dir, file))
+			switch {
+			case err == nil:
+				text = custom
+			case !errors.Is(err, fs.ErrNotExist):
+				return nil, fmt.Errorf("failed to read mail template: %w", err)
+			}
+		}
+		tmpl, err := template.New(file).Option("missingkey=error").
+			Funcs(template.FuncMap{"duration": formatDuration}).
+			Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("invalid mail template: %w", err)
+		}
+		t.templates[name] = tmpl
+	}
+	return t, nil
+}
+
+// Render renders the named template into a message to to.
+func (t *Templates) Render(name, to string, data Data) (*Message, error) {
+	tmpl, ok := t.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown mail template %q", name)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("failed to render mail template %s: %w", name, err)
+	}
+	header, body, ok := strings.Cut(out.String(), "\n\n")
+	subject, hasSubject := strings.CutPrefix(header, "Subject: ")
+	if !ok || !hasSubject || strings.Contains(subject, "\n") {
+		return nil, fmt.Errorf("mail template %s must start with a Subject line and a blank line", name)
+	}
+	return &Message{From: t.from, To: to, Subject: strings.TrimSpace(subject), Text: body}, nil
+}
+
+// formatDuration writes d in days or hours when it is a whole number of
+// them, and in minutes otherwise.
+func formatDuration(d time.Duration) string {
+	const day = 24 * time.Hour
+	n, unit := int64(d/time.Minute), "minute"
+	switch {
+	case d >= day && d%day == 0:
+		n, unit = int64(d/day), "day"
+	case d >= time.Hour && d%time.Hour == 0:
+		n, unit = int64(d/time.Hour), "hour"
+	}
+	if n <= 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,143 @@
+// Package metrics collects the Prometheus metrics of the server: HTTP
+// requests, database queries and connection pool, and business events.
+package metrics
+
+import (
+	"blog_backend/app/services"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+const namespace = "blog"
+
+// Metrics holds the collectors of one App in a registry of its own, so
+// several apps, as in tests, do not clash.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpDuration  *prometheus.HistogramVec
+	queryDuration *prometheus.HistogramVec
+	queryErrors   *prometheus.CounterVec
+
+	registrations   prometheus.Counter
+	logins          prometheus.Counter
+	loginFailures   *prometheus.CounterVec
+	postsCreated    prometheus.Counter
+	commentsCreated prometheus.Counter
+}
+
+// Metrics counts the business events the services report.
+var _ services.Events = (*Metrics)(nil)
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by method, route template and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Latency of database queries by operation and table.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "table"}),
+		queryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "db_query_errors_total",
+			Help:      "Failed database queries by operation and table. Missing rows are not failures.",
+		}, []string{"operation", "table"}),
+		registrations: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "user_registrations_total",
+			Help:      "Users registered.",
+		}),
+		logins: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Successful logins.",
+		}),
+		loginFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "login_failures_total",
+			Help:      "Rejected logins by reason.",
+		}, []string{"reason"}),
+		postsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "posts_created_total",
+			Help:      "Posts created, drafts and scheduled posts included.",
+		}),
+		commentsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "comments_created_total",
+			Help:      "Comments created.",
+		}),
+	}
+	// Export the failure reasons before the first failure, so rates work
+	for _, reason := range []string{services.LoginFailureInvalidCredentials, services.LoginFailureLockedOut, services.LoginFailureInvalidMFACode} {
+		m.loginFailures.WithLabelValues(reason)
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpDuration, m.queryDuration, m.queryErrors,
+		m.registrations, m.logins, m.loginFailures, m.postsCreated, m.commentsCreated,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHTTPRequest records one answered request. route is the route
+// template, such as /post/:post_id, so the number of series stays bounded.
+func (m *Metrics) ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	m.httpDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// InstrumentDB times the queries of db and exports the stats of its
+// connection pool under the name dbName.
+func (m *Metrics) InstrumentDB(db *gorm.DB, dbName string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := m.registry.Register(collectors.NewDBStatsCollector(sqlDB, dbName)); err != nil {
+		return fmt.Errorf("failed to register pool stats: %w", err)
+	}
+	if err := db.Use(&gormPlugin{metrics: m}); err != nil {
+		return fmt.Errorf("failed to register query metrics: %w", err)
+	}
+	return nil
+}
+
+func (m *Metrics) UserRegistered() {
+	m.registrations.Inc()
+}
+
+func (m *Metrics) LoginSucceeded() {
+	m.logins.Inc()
+}
+
+func (m *Metrics) LoginFailed(reason string) {
+	m.loginFailures.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) PostCreated() {
+	m.postsCreated.Inc()
+}
+
+func (m *Metrics) CommentCreated() {
+	m.commentsCreated.Inc()
+}
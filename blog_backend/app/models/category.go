@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// Category is a node in the category tree. Top-level categories have no
+// parent.
+type Category struct {
+	ID        int        `gorm:"primaryKey"`
+	Name      string     `gorm:"size:100;not null"`
+	Slug      string     `gorm:"size:100;not null;uniqueIndex"`
+	ParentID  *int       `gorm:"index"`
+	Parent    *Category  `gorm:"foreignKey:ParentID"`
+	Children  []Category `gorm:"foreignKey:ParentID"`
+	CreatedAt time.Time  `gorm:"autoCreateTime;not null"`
+}
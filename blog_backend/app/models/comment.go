@@ -1,15 +1,38 @@
 package models
 
 import (
+	"fmt"
 	"time"
 )
 
+// DeletedCommentContent replaces the content of a deleted comment that is
+// kept in place because it still has replies.
+const DeletedCommentContent = "[deleted]"
+
+// Comment is a reply to a post or, when ParentID is set, to another comment.
+// Path is the chain of zero-padded ids from the root comment down to this
+// one, so ordering by it yields each thread depth-first in reply order.
 type Comment struct {
-	ID        int       `gorm:"primaryKey"`
-	Content   string    `gorm:"type:text;not null"`
-	UserID    int       `gorm:"not null"`
-	User      User      `gorm:"foreignKey:UserID"`
-	PostID    int       `gorm:"not null"`
-	Post      Post      `gorm:"foreignKey:PostID"`
-	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+	ID        int        `gorm:"primaryKey"`
+	Content   string     `gorm:"type:text;not null"`
+	UserID    int        `gorm:"not null"`
+	User      User       `gorm:"foreignKey:UserID"`
+	PostID    int        `gorm:"not null"`
+	Post      Post       `gorm:"foreignKey:PostID"`
+	ParentID  *int       `gorm:"index"`
+	Parent    *Comment   `gorm:"foreignKey:ParentID"`
+	Depth     int        `gorm:"not null;default:0"`
+	Path      string     `gorm:"size:255;not null;default:'';index"`
+	DeletedAt *time.Time `gorm:"index"`
+	CreatedAt time.Time  `gorm:"autoCreateTime;not null"`
+}
+
+func (c *Comment) IsDeleted() bool {
+	return c.DeletedAt != nil
+}
+
+// CommentPathSegment is the part of a comment path contributed by the comment
+// with the given id.
+func CommentPathSegment(id int) string {
+	return fmt.Sprintf("%010d", id)
 }
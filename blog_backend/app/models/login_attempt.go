@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	LoginAttemptScopeAccount = "account"
+	LoginAttemptScopeIP      = "ip"
+)
+
+// LoginAttempt counts consecutive failed logins for one account or client IP.
+type LoginAttempt struct {
+	ID           int       `gorm:"primaryKey"`
+	Scope        string    `gorm:"size:20;not null;uniqueIndex:idx_login_attempt_scope_identifier"`
+	Identifier   string    `gorm:"size:255;not null;uniqueIndex:idx_login_attempt_scope_identifier"`
+	FailedCount  int       `gorm:"not null;default:0"`
+	LastFailedAt time.Time `gorm:"not null"`
+	LockedUntil  *time.Time
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// TokenScope is a part of the API a personal access token may use.
+type TokenScope string
+
+const (
+	// ScopeRead allows the read requests of the routes that need a login
+	ScopeRead TokenScope = "read"
+	// ScopePostsWrite allows creating, editing and deleting posts
+	ScopePostsWrite TokenScope = "posts:write"
+	// ScopeCommentsWrite allows creating, editing and deleting comments
+	ScopeCommentsWrite TokenScope = "comments:write"
+)
+
+// TokenScopes lists every scope, in the order they are shown in.
+var TokenScopes = []TokenScope{ScopeRead, ScopePostsWrite, ScopeCommentsWrite}
+
+// PersonalAccessTokenPrefix starts every personal access token, which tells
+// them apart from login tokens and makes leaked ones easy to search for.
+const PersonalAccessTokenPrefix = "blog_pat_"
+
+// PersonalAccessToken lets scripts use the API as a user, within its
+// scopes, without their password. Only its hash is stored. Scopes holds the
+// scopes separated by commas.
+type PersonalAccessToken struct {
+	ID         int    `gorm:"primaryKey"`
+	UserID     int    `gorm:"not null;index"`
+	User       User   `gorm:"foreignKey:UserID"`
+	Name       string `gorm:"size:100;not null"`
+	TokenHash  string `gorm:"size:64;not null;uniqueIndex"`
+	Scopes     string `gorm:"size:100;not null"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time `gorm:"autoCreateTime;not null"`
+}
+
+// ScopeList splits Scopes.
+func (t *PersonalAccessToken) ScopeList() []TokenScope {
+	var scopes []TokenScope
+	for _, scope := range strings.Split(t.Scopes, ",") {
+		if scope != "" {
+			scopes = append(scopes, TokenScope(scope))
+		}
+	}
+	return scopes
+}
+
+// Expired reports whether the token can no longer be used at now.
+func (t *PersonalAccessToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
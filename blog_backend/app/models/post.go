@@ -7,15 +7,39 @@ import (
 	"gorm.io/gorm"
 )
 
+type PostStatus string
+
+const (
+	PostStatusDraft     PostStatus = "draft"
+	PostStatusScheduled PostStatus = "scheduled"
+	PostStatusPublished PostStatus = "published"
+	PostStatusArchived  PostStatus = "archived"
+)
+
+func (s PostStatus) Valid() bool {
+	switch s {
+	case PostStatusDraft, PostStatusScheduled, PostStatusPublished, PostStatusArchived:
+		return true
+	}
+	return false
+}
+
 type Post struct {
-	ID        int       `gorm:"primaryKey"`
-	Title     string    `gorm:"size:200;not null"`
-	Content   string    `gorm:"type:text;not null"`
-	UserID    int       `gorm:"not null"`
-	User      User      `gorm:"foreignKey:UserID"`
-	Comments  []Comment `gorm:"foreignKey:PostID"`
-	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime;not null"`
+	ID         int       `gorm:"primaryKey"`
+	Title      string    `gorm:"size:200;not null"`
+	Content    string    `gorm:"type:text;not null"`
+	UserID     int       `gorm:"not null"`
+	User       User      `gorm:"foreignKey:UserID"`
+	CategoryID *int      `gorm:"index"`
+	Category   *Category `gorm:"foreignKey:CategoryID"`
+	Tags       []Tag     `gorm:"many2many:post_tags"`
+	Comments   []Comment `gorm:"foreignKey:PostID"`
+	// PublishAt is when a scheduled post goes live, PublishedAt when it did.
+	Status      PostStatus `gorm:"size:20;not null;default:published;index"`
+	PublishAt   *time.Time `gorm:"index"`
+	PublishedAt *time.Time
+	CreatedAt   time.Time `gorm:"autoCreateTime;not null"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime;not null"`
 }
 
 func (p *Post) AfterCreate(tx *gorm.DB) (err error) {
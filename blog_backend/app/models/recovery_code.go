@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// RecoveryCode stands in for a code of the authenticator app once, for users
+// who lost it. Only its hash is stored.
+type RecoveryCode struct {
+	ID        int    `gorm:"primaryKey"`
+	UserID    int    `gorm:"not null;index"`
+	User      User   `gorm:"foreignKey:UserID"`
+	CodeHash  string `gorm:"size:64;not null;uniqueIndex"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is one link in a rotating refresh token chain. Every token
+// issued from the same login shares a FamilyID, so revoking the family ends
+// the whole session.
+type RefreshToken struct {
+	ID        int        `gorm:"primaryKey"`
+	UserID    int        `gorm:"not null;index"`
+	User      User       `gorm:"foreignKey:UserID"`
+	FamilyID  string     `gorm:"size:64;not null;index"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	RevokedAt *time.Time `gorm:"index"`
+	CreatedAt time.Time  `gorm:"autoCreateTime;not null"`
+}
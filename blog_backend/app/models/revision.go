@@ -0,0 +1,40 @@
+package models
+
+import (
+	"blog_backend/app/apperror"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrRevisionImmutable = apperror.Conflict("revisions are immutable")
+
+// PostRevision is a snapshot of a post taken every time it is saved.
+// Revision numbers start at 1 and increase per post.
+type PostRevision struct {
+	ID        int       `gorm:"primaryKey"`
+	PostID    int       `gorm:"not null;uniqueIndex:idx_post_revision"`
+	Revision  int       `gorm:"not null;uniqueIndex:idx_post_revision"`
+	Title     string    `gorm:"size:200;not null"`
+	Content   string    `gorm:"type:text;not null"`
+	EditorID  int       `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+}
+
+func (r *PostRevision) BeforeUpdate(tx *gorm.DB) error {
+	return ErrRevisionImmutable
+}
+
+// CommentRevision is a snapshot of a comment taken every time it is saved.
+type CommentRevision struct {
+	ID        int       `gorm:"primaryKey"`
+	CommentID int       `gorm:"not null;uniqueIndex:idx_comment_revision"`
+	Revision  int       `gorm:"not null;uniqueIndex:idx_comment_revision"`
+	Content   string    `gorm:"type:text;not null"`
+	EditorID  int       `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+}
+
+func (r *CommentRevision) BeforeUpdate(tx *gorm.DB) error {
+	return ErrRevisionImmutable
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// SigningKey is a key pair access tokens are signed with from ActivatesAt
+// on, identified in their kid header by KID. PrivateKey is encrypted.
+type SigningKey struct {
+	ID          int       `gorm:"primaryKey"`
+	KID         string    `gorm:"column:kid;size:64;not null;uniqueIndex"`
+	Algorithm   string    `gorm:"size:10;not null"`
+	PrivateKey  string    `gorm:"type:text;not null"`
+	ActivatesAt time.Time `gorm:"not null;index"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;not null"`
+}
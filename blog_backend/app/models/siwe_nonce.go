@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// SIWENonce is handed to a wallet to put in the Sign-In with Ethereum
+// message it signs, so that a signed message is only accepted once. Only
+// its hash is stored.
+type SIWENonce struct {
+	ID        int       `gorm:"primaryKey"`
+	NonceHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+type Tag struct {
+	ID        int       `gorm:"primaryKey"`
+	Name      string    `gorm:"size:50;not null"`
+	Slug      string    `gorm:"size:50;not null;uniqueIndex"`
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+}
+
+// PostTag is the join table between posts and tags.
+type PostTag struct {
+	PostID    int       `gorm:"primaryKey"`
+	TagID     int       `gorm:"primaryKey;index"`
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+}
@@ -4,14 +4,53 @@ import (
 	"time"
 )
 
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+func (r Role) Valid() bool {
+	switch r {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
 type User struct {
-	ID            int       `gorm:"primaryKey"`
-	Username      string    `gorm:"size:100;not null"`
-	Password      string    `gorm:"size:100;not null"`
-	Email         string    `gorm:"size:100;not null;unique"`
-	NumberOfPosts int       `gorm:"default:0"`
+	ID            int    `gorm:"primaryKey"`
+	Username      string `gorm:"size:100;not null"`
+	Password      string `gorm:"size:100;not null"`
+	Email         string `gorm:"size:100;not null;unique"`
+	Role          Role   `gorm:"size:20;not null;default:user"`
+	NumberOfPosts int    `gorm:"default:0"`
+	// EmailVerifiedAt is when the user proved they own Email, nil until then
+	EmailVerifiedAt *time.Time
+	// TOTPSecret is the base32 secret shared with the user's authenticator
+	// app. It is set on enrollment, and codes are only asked for at login
+	// once the first one confirmed it, at TOTPEnabledAt.
+	TOTPSecret    string `gorm:"size:64"`
+	TOTPEnabledAt *time.Time
+	// TOTPLastStep is the time step of the last accepted code, so that a
+	// code cannot be used twice
+	TOTPLastStep int64 `gorm:"not null;default:0"`
+	// WalletAddress is the EIP-55 checksummed Ethereum address the user
+	// signs in with, nil until they link one
+	WalletAddress *string   `gorm:"size:42;uniqueIndex"`
 	CreatedAt     time.Time `gorm:"autoCreateTime;not null"`
 	UpdatedAt     time.Time `gorm:"autoUpdateTime;not null"`
 	Posts         []Post    `gorm:"foreignKey:UserID"`
 	Comments      []Comment `gorm:"foreignKey:UserID"`
 }
+
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// MFAEnabled reports whether logins ask for a code on top of the password.
+func (u *User) MFAEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// UserTokenPurpose says what a UserToken lets its bearer do.
+type UserTokenPurpose string
+
+const (
+	UserTokenVerifyEmail   UserTokenPurpose = "verify_email"
+	UserTokenResetPassword UserTokenPurpose = "reset_password"
+	// UserTokenMFAPending is handed out by a login with the right password
+	// for an account with two-factor authentication, and completes it
+	// together with a code
+	UserTokenMFAPending UserTokenPurpose = "mfa_pending"
+)
+
+// UserToken is a single-use token emailed or handed to a user. Only its hash is
+// stored. UsedAt is set once it is used, or when a newer token for the same
+// purpose replaces it.
+type UserToken struct {
+	ID        int              `gorm:"primaryKey"`
+	UserID    int              `gorm:"not null;index"`
+	User      User             `gorm:"foreignKey:UserID"`
+	Purpose   UserTokenPurpose `gorm:"size:20;not null"`
+	TokenHash string           `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time        `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+}
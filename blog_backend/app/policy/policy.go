@@ -0,0 +1,87 @@
+// Package policy decides what an authenticated user may do. Services consult
+// it instead of comparing owner IDs themselves.
+package policy
+
+import (
+	"blog_backend/app/models"
+)
+
+type Permission string
+
+const (
+	PostCreate       Permission = "post:create"
+	PostUpdateAny    Permission = "post:update:any"
+	PostDeleteAny    Permission = "post:delete:any"
+	CommentCreate    Permission = "comment:create"
+	CommentUpdateAny Permission = "comment:update:any"
+	CommentDeleteAny Permission = "comment:delete:any"
+	UserManageRoles  Permission = "user:manage_roles"
+	UserResetMFA     Permission = "user:reset_mfa"
+	CategoryManage   Permission = "category:manage"
+)
+
+var rolePermissions = map[models.Role][]Permission{
+	models.RoleUser: {
+		PostCreate,
+		CommentCreate,
+	},
+	models.RoleModerator: {
+		PostCreate,
+		CommentCreate,
+		CommentDeleteAny,
+	},
+	models.RoleAdmin: {
+		PostCreate,
+		PostUpdateAny,
+		PostDeleteAny,
+		CommentCreate,
+		CommentUpdateAny,
+		CommentDeleteAny,
+		UserManageRoles,
+		UserResetMFA,
+		CategoryManage,
+	},
+}
+
+// Actor is the user on whose behalf a service call is made. Until they
+// verify their email address, users may only manage what they already own.
+type Actor struct {
+	UserID        int
+	Role          models.Role
+	EmailVerified bool
+}
+
+func HasPermission(role models.Role, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+func (a Actor) Can(perm Permission) bool {
+	return a.EmailVerified && HasPermission(a.Role, perm)
+}
+
+// CanViewPost lets everyone see published posts. Drafts, scheduled and
+// archived posts are only visible to those who may edit them.
+func CanViewPost(actor Actor, post *models.Post) bool {
+	return post.Status == models.PostStatusPublished || CanUpdatePost(actor, post)
+}
+
+func CanUpdatePost(actor Actor, post *models.Post) bool {
+	return post.UserID == actor.UserID || actor.Can(PostUpdateAny)
+}
+
+func CanDeletePost(actor Actor, post *models.Post) bool {
+	return post.UserID == actor.UserID || actor.Can(PostDeleteAny)
+}
+
+func CanUpdateComment(actor Actor, comment *models.Comment) bool {
+	return comment.UserID == actor.UserID || actor.Can(CommentUpdateAny)
+}
+
+func CanDeleteComment(actor Actor, comment *models.Comment) bool {
+	return comment.UserID == actor.UserID || actor.Can(CommentDeleteAny)
+}
@@ -0,0 +1,81 @@
+// Package ratelimit implements token bucket rate limiting. Each bucket holds
+// up to Limit.Tokens tokens and is refilled at Tokens per Period; a request
+// takes one token and is rejected when the bucket is empty. Buckets live in
+// a Store, in process or in a Redis-compatible server shared by instances.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Tokens requests per Period, all of them in a burst.
+type Limit struct {
+	Tokens int
+	Period time.Duration
+}
+
+// ParseLimit parses a limit written as <tokens>/<period>, such as 10/1m or
+// 5/h. The period is a Go duration, whose leading 1 may be left out.
+func ParseLimit(s string) (Limit, error) {
+	tokens, period, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: want <tokens>/<period> such as 10/1m", s)
+	}
+	n, err := strconv.Atoi(tokens)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: tokens must be a positive integer", s)
+	}
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", s)
+	}
+	return Limit{Tokens: n, Period: d}, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Tokens, l.Period)
+}
+
+// rate is the refill rate in tokens per nanosecond.
+func (l Limit) rate() float64 {
+	return float64(l.Tokens) / float64(l.Period)
+}
+
+// Result is the state of a bucket after a request took, or failed to take,
+// a token from it.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until the next token, zero while some are left
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Store keeps the buckets. Take takes a token from the bucket key, which is
+// created full the first time.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// bucketResult computes the result of a bucket holding tokens after a take.
+func bucketResult(allowed bool, tokens float64, limit Limit) Result {
+	rate := limit.rate()
+	result := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration(math.Ceil((float64(limit.Tokens) - tokens) / rate)),
+	}
+	if tokens < 1 {
+		result.RetryAfter = time.Duration(math.Ceil((1 - tokens) / rate))
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestParseLimit(t *testing.T) {
+	for spec, want := range map[string]Limit{
+		"10/1m":  {Tokens: 10, Period: time.Minute},
+		"5/h":    {Tokens: 5, Period: time.Hour},
+		" 3/30s": {Tokens: 3, Period: 30 * time.Second},
+	} {
+		if got, err := ParseLimit(spec); err != nil || got != want {
+			t.Errorf("ParseLimit(%q) = %v, %v; want %v", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"", "10", "0/1m", "-1/1m", "x/1m", "10/", "10/0s", "10/fortnight"} {
+		if _, err := ParseLimit(spec); err == nil {
+			t.Errorf("ParseLimit(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	rules, err := ParseRules("post /comment/=1/1m, POST /user/login=10/1m", "100/1m")
+	if err != nil {
+		t.Fatalf("ParseRules: %v", err)
+	}
+	if name, limit, ok := rules.For("POST", "/comment/"); !ok || name != "POST /comment/" || limit.Tokens != 1 {
+		t.Fatalf("For(POST /comment/) = %q, %v, %v", name, limit, ok)
+	}
+	if name, limit, ok := rules.For("GET", "/comment/"); !ok || name != DefaultRule || limit.Tokens != 100 {
+		t.Fatalf("For(GET /comment/) = %q, %v, %v", name, limit, ok)
+	}
+
+	rules, err = ParseRules("", "")
+	if err != nil {
+		t.Fatalf("ParseRules: %v", err)
+	}
+	if _, _, ok := rules.For("GET", "/post"); ok {
+		t.Fatal("routes should be unlimited without rules or default")
+	}
+	for _, spec := range []string{"/comment/=1/1m", "POST /comment/", "POST /comment/=1"} {
+		if _, err := ParseRules(spec, ""); err == nil {
+			t.Errorf("ParseRules(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	clock := time.Now()
+	store.now = func() time.Time { return clock }
+	testStore(t, store, func(d time.Duration) { clock = clock.Add(d) })
+
+	// Full buckets are forgotten
+	clock = clock.Add(sweepInterval)
+	store.Take(context.Background(), "other", Limit{Tokens: 1, Period: time.Second})
+	if len(store.buckets) != 1 {
+		t.Fatalf("%d buckets left after sweep, want 1", len(store.buckets))
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	store := NewRedisStore(client)
+	clock := time.Now()
+	store.now = func() time.Time { return clock }
+	testStore(t, store, func(d time.Duration) {
+		clock = clock.Add(d)
+		server.FastForward(d)
+	})
+
+	server.FastForward(time.Minute)
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Fatalf("buckets %v did not expire once full", keys)
+	}
+}
+
+// testStore drains a bucket of 3 tokens per minute and lets it refill,
+// calling advance to move the clock.
+func testStore(t *testing.T, store Store, advance func(time.Duration)) {
+	t.Helper()
+	ctx := context.Background()
+	limit := Limit{Tokens: 3, Period: time.Minute}
+	take := func(key string) Result {
+		t.Helper()
+		result, err := store.Take(ctx, key, limit)
+		if err != nil {
+			t.Fatalf("Take: %v", err)
+		}
+		return result
+	}
+
+	for want := 2; want >= 0; want-- {
+		result := take("alice")
+		if !result.Allowed || result.Remaining != want || result.RetryAfter != 0 && want > 0 {
+			t.Fatalf("take with %d tokens left = %+v", want+1, result)
+		}
+	}
+	result := take("alice")
+	if result.Allowed || result.RetryAfter != 20*time.Second || result.Reset != time.Minute {
+		t.Fatalf("take from an empty bucket = %+v", result)
+	}
+	if result := take("bob"); !result.Allowed || result.Remaining != 2 {
+		t.Fatalf("buckets are not separate: %+v", result)
+	}
+
+	advance(20 * time.Second)
+	if result := take("alice"); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("take after one token was refilled = %+v", result)
+	}
+	advance(time.Hour)
+	if result := take("alice"); !result.Allowed || result.Remaining != 2 || result.Reset != 20*time.Second {
+		t.Fatalf("take from a refilled bucket = %+v", result)
+	}
+}
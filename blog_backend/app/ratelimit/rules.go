@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultRule names the rule of routes without a rule of their own.
+const DefaultRule = "default"
+
+// Rules map routes to their limits. Routes are named by method and route
+// template, as in "POST /comment/".
+type Rules struct {
+	routes map[string]Limit
+	def    *Limit
+}
+
+// ParseRules parses comma-separated "<METHOD> <route>=<limit>" rules, and
+// the limit of all other routes, which is unlimited when def is empty.
+func ParseRules(routes, def string) (*Rules, error) {
+	rules := &Rules{routes: map[string]Limit{}}
+	for _, rule := range strings.Split(routes, ",") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		route, spec, ok := strings.Cut(rule, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPath {
+			return nil, fmt.Errorf("invalid rate limit rule %q: want <METHOD> <route>=<limit>", rule)
+		}
+		limit, err := ParseLimit(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules.routes[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = limit
+	}
+	if strings.TrimSpace(def) != "" {
+		limit, err := ParseLimit(def)
+		if err != nil {
+			return nil, err
+		}
+		rules.def = &limit
+	}
+	return rules, nil
+}
+
+// For returns the rule applying to a request, and false when the request is
+// not limited.
+func (r *Rules) For(method, route string) (name string, limit Limit, ok bool) {
+	name = method + " " + route
+	if limit, ok := r.routes[name]; ok {
+		return name, limit, true
+	}
+	if r.def != nil {
+		return DefaultRule, *r.def, true
+	}
+	return "", Limit{}, false
+}
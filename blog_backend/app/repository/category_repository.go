@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type CategoryRepository interface {
+	CreateCategory(ctx context.Context, category *models.Category) (*models.Category, error)
+	RetrieveCategory(ctx context.Context, id int) (*models.Category, error)
+	RetrieveCategoryBySlug(ctx context.Context, slug string) (*models.Category, error)
+	ListCategories(ctx context.Context) ([]*models.Category, error)
+}
+
+type categoryRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func (r *categoryRepositoryGorm) CreateCategory(ctx context.Context, category *models.Category) (*models.Category, error) {
+	if err := conn(ctx, r.db).Create(category).Error; err != nil {
+		return nil, fmt.Errorf("failed to create category: %w", dbError(err))
+	}
+	return category, nil
+}
+
+func (r *categoryRepositoryGorm) RetrieveCategory(ctx context.Context, id int) (*models.Category, error) {
+	category := &models.Category{}
+	if err := conn(ctx, r.db).First(category, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve category with id %d: %w", id, dbError(err))
+	}
+	return category, nil
+}
+
+func (r *categoryRepositoryGorm) RetrieveCategoryBySlug(ctx context.Context, slug string) (*models.Category, error) {
+	category := &models.Category{}
+	if err := conn(ctx, r.db).Where("slug = ?", slug).First(category).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve category %s: %w", slug, dbError(err))
+	}
+	return category, nil
+}
+
+// ListCategories returns every category as a flat list ordered by name.
+// The tree is small enough to assemble in memory.
+func (r *categoryRepositoryGorm) ListCategories(ctx context.Context) ([]*models.Category, error) {
+	var categories []*models.Category
+	if err := conn(ctx, r.db).Order("name ASC").Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", dbError(err))
+	}
+	return categories, nil
+}
+
+func NewCategoryRepository(db *gorm.DB) CategoryRepository {
+	return &categoryRepositoryGorm{db: db}
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type categoryRepositoryMemory struct {
+	store *MemoryStore
+}
+
+func (r *categoryRepositoryMemory) CreateCategory(ctx context.Context, category *models.Category) (*models.Category, error) {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		for _, c := range t.categories {
+			if c.Slug == category.Slug {
+				return errDuplicateRow
+			}
+		}
+		if category.ParentID != nil {
+			if _, ok := t.categories[*category.ParentID]; !ok {
+				return errRowReferenced
+			}
+		}
+		category.ID = t.nextID("categories")
+		if category.CreatedAt.IsZero() {
+			category.CreatedAt = time.Now()
+		}
+		stored := *category
+		stored.Parent = nil
+		stored.Children = nil
+		t.categories[category.ID] = stored
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create category: %w", err)
+	}
+	return category, nil
+}
+
+func (r *categoryRepositoryMemory) RetrieveCategory(ctx context.Context, id int) (*models.Category, error) {
+	category := &models.Category{}
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.categories[id]
+		if !ok {
+			return errNoRow
+		}
+		*category = stored
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve category with id %d: %w", id, err)
+	}
+	return category, nil
+}
+
+func (r *categoryRepositoryMemory) RetrieveCategoryBySlug(ctx context.Context, slug string) (*models.Category, error) {
+	category := &models.Category{}
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		for _, c := range t.categories {
+			if c.Slug == slug {
+				*category = c
+				return nil
+			}
+		}
+		return errNoRow
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve category %s: %w", slug, err)
+	}
+	return category, nil
+}
+
+func (r *categoryRepositoryMemory) ListCategories(ctx context.Context) ([]*models.Category, error) {
+	categories := []*models.Category{}
+	r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.categories {
+			category := stored
+			categories = append(categories, &category)
+		}
+		return nil
+	})
+	slices.SortFunc(categories, func(a, b *models.Category) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+	return categories, nil
+}
+
+func NewMemoryCategoryRepository(store *MemoryStore) CategoryRepository {
+	return &categoryRepositoryMemory{store: store}
+}
@@ -2,58 +2,148 @@ package repository
 
 import (
 	"blog_backend/app/models"
+	"context"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 type CommentRepository interface {
-	CreateComment(comment *models.Comment) (*models.Comment, error)
-	RetrieveComment(id int) (*models.Comment, error)
-	UpdateComment(comment *models.Comment) (*models.Comment, error)
-	DeleteComment(id int) error
-	ListComments(postID int) ([]*models.Comment, error)
+	CreateComment(ctx context.Context, comment *models.Comment) (*models.Comment, error)
+	RetrieveComment(ctx context.Context, id int) (*models.Comment, error)
+	UpdateComment(ctx context.Context, comment *models.Comment) (*models.Comment, error)
+	DeleteComment(ctx context.Context, id int) error
+	ListComments(ctx context.Context, postID int, page Page) (*PageResult[*models.Comment], error)
+	ListRootComments(ctx context.Context, postID int, page Page) (*PageResult[*models.Comment], error)
+	ListThreadReplies(ctx context.Context, roots []*models.Comment) ([]*models.Comment, error)
+	CountReplies(ctx context.Context, id int) (int64, error)
+	MarkCommentDeleted(ctx context.Context, id int, at time.Time) error
 }
 
+var CommentSorts = []Sort{"created_at", "-created_at"}
+
 type commentRepositoryGorm struct {
 	db *gorm.DB
 }
 
-func (r *commentRepositoryGorm) CreateComment(comment *models.Comment) (*models.Comment, error) {
-	if err := r.db.Create(comment).Error; err != nil {
-		return nil, fmt.Errorf("failed to create comment: %w", err)
+// CreateComment stores comment and derives its path from its parent's, which
+// needs the id assigned by the insert.
+func (r *commentRepositoryGorm) CreateComment(ctx context.Context, comment *models.Comment) (*models.Comment, error) {
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		prefix := ""
+		if comment.ParentID != nil {
+			parent := &models.Comment{}
+			if err := tx.Select("path").First(parent, *comment.ParentID).Error; err != nil {
+				return err
+			}
+			prefix = parent.Path + "/"
+		}
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		comment.Path = prefix + models.CommentPathSegment(comment.ID)
+		return tx.Model(comment).UpdateColumn("path", comment.Path).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", dbError(err))
 	}
 	return comment, nil
 }
 
-func (r *commentRepositoryGorm) RetrieveComment(id int) (*models.Comment, error) {
+func (r *commentRepositoryGorm) RetrieveComment(ctx context.Context, id int) (*models.Comment, error) {
 	comment := &models.Comment{}
-	if err := r.db.First(comment, id).Error; err != nil {
-		return nil, fmt.Errorf("failed to retrieve comment with id %d: %w", id, err)
+	if err := conn(ctx, r.db).Scopes(lockForUpdate(ctx)).First(comment, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve comment with id %d: %w", id, dbError(err))
 	}
 	return comment, nil
 }
 
-func (r *commentRepositoryGorm) UpdateComment(comment *models.Comment) (*models.Comment, error) {
-	if err := r.db.Model(&models.Comment{}).Where("id = ?", comment.ID).Updates(comment).Error; err != nil {
-		return nil, fmt.Errorf("failed to update comment with id %d: %w", comment.ID, err)
+func (r *commentRepositoryGorm) UpdateComment(ctx context.Context, comment *models.Comment) (*models.Comment, error) {
+	if err := conn(ctx, r.db).Model(&models.Comment{}).Where("id = ?", comment.ID).Updates(comment).Error; err != nil {
+		return nil, fmt.Errorf("failed to update comment with id %d: %w", comment.ID, dbError(err))
 	}
 	return comment, nil
 }
 
-func (r *commentRepositoryGorm) DeleteComment(id int) error {
-	if err := r.db.Delete(&models.Comment{}, id).Error; err != nil {
-		return fmt.Errorf("failed to delete comment with id %d: %w", id, err)
+// DeleteComment deletes the comment together with its revisions.
+func (r *commentRepositoryGorm) DeleteComment(ctx context.Context, id int) error {
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("comment_id = ?", id).Delete(&models.CommentRevision{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Comment{}, id).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete comment with id %d: %w", id, dbError(err))
 	}
 	return nil
 }
 
-func (r *commentRepositoryGorm) ListComments(postID int) ([]*models.Comment, error) {
-	var comments []*models.Comment
-	if err := r.db.Where("post_id = ?", postID).Find(&comments).Error; err != nil {
-		return nil, fmt.Errorf("failed to list comments for post with id %d: %w", postID, err)
+func (r *commentRepositoryGorm) ListComments(ctx context.Context, postID int, page Page) (*PageResult[*models.Comment], error) {
+	query := conn(ctx, r.db).Model(&models.Comment{}).Where("post_id = ?", postID)
+	result, err := paginate(query, page, CommentSorts,
+		func(c *models.Comment, _ string) time.Time { return c.CreatedAt },
+		func(c *models.Comment) int { return c.ID })
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments for post with id %d: %w", postID, dbError(err))
+	}
+	return result, nil
+}
+
+// ListRootComments pages through the top-level comments of a post, one per
+// thread.
+func (r *commentRepositoryGorm) ListRootComments(ctx context.Context, postID int, page Page) (*PageResult[*models.Comment], error) {
+	query := conn(ctx, r.db).Model(&models.Comment{}).Where("post_id = ? AND parent_id IS NULL", postID)
+	result, err := paginate(query, page, CommentSorts,
+		func(c *models.Comment, _ string) time.Time { return c.CreatedAt },
+		func(c *models.Comment) int { return c.ID })
+	if err != nil {
+		return nil, fmt.Errorf("failed to list root comments for post with id %d: %w", postID, dbError(err))
+	}
+	return result, nil
+}
+
+// ListThreadReplies returns every reply below roots, ordered by path.
+func (r *commentRepositoryGorm) ListThreadReplies(ctx context.Context, roots []*models.Comment) ([]*models.Comment, error) {
+	var replies []*models.Comment
+	if len(roots) == 0 {
+		return replies, nil
+	}
+	query := conn(ctx, r.db).Model(&models.Comment{})
+	for _, root := range roots {
+		query = query.Or("path LIKE ?", root.Path+"/%")
+	}
+	if err := query.Order("path").Find(&replies).Error; err != nil {
+		return nil, fmt.Errorf("failed to list comment replies: %w", dbError(err))
+	}
+	return replies, nil
+}
+
+func (r *commentRepositoryGorm) CountReplies(ctx context.Context, id int) (int64, error) {
+	var count int64
+	if err := conn(ctx, r.db).Model(&models.Comment{}).Where("parent_id = ?", id).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count replies to comment with id %d: %w", id, dbError(err))
 	}
-	return comments, nil
+	return count, nil
+}
+
+// MarkCommentDeleted blanks out a comment that has to stay in its thread as a
+// placeholder, and drops its revisions so that the content is really gone.
+func (r *commentRepositoryGorm) MarkCommentDeleted(ctx context.Context, id int, at time.Time) error {
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("comment_id = ?", id).Delete(&models.CommentRevision{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Comment{}).Where("id = ?", id).Updates(map[string]any{
+			"content":    models.DeletedCommentContent,
+			"deleted_at": at,
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark comment with id %d deleted: %w", id, dbError(err))
+	}
+	return nil
 }
 
 func NewCommentRepository(db *gorm.DB) CommentRepository {
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type commentRepositoryMemory struct {
+	store *MemoryStore
+}
+
+func (r *commentRepositoryMemory) CreateComment(ctx context.Context, comment *models.Comment) (*models.Comment, error) {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		prefix := ""
+		if comment.ParentID != nil {
+			parent, ok := t.comments[*comment.ParentID]
+			if !ok {
+				return errNoRow
+			}
+			prefix = parent.Path + "/"
+		}
+		if _, ok := t.posts[comment.PostID]; !ok {
+			return errRowReferenced
+		}
+		comment.ID = t.nextID("comments")
+		comment.Path = prefix + models.CommentPathSegment(comment.ID)
+		if comment.CreatedAt.IsZero() {
+			comment.CreatedAt = time.Now()
+		}
+		t.comments[comment.ID] = storedComment(*comment)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+	return comment, nil
+}
+
+func (r *commentRepositoryMemory) RetrieveComment(ctx context.Context, id int) (*models.Comment, error) {
+	comment := &models.Comment{}
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.comments[id]
+		if !ok {
+			return errNoRow
+		}
+		*comment = stored
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve comment with id %d: %w", id, err)
+	}
+	return comment, nil
+}
+
+// UpdateComment writes the non-zero fields of comment, like GORM's Updates
+// with a struct.
+func (r *commentRepositoryMemory) UpdateComment(ctx context.Context, comment *models.Comment) (*models.Comment, error) {
+	r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.comments[comment.ID]
+		if !ok {
+			return nil
+		}
+		if comment.Content != "" {
+			stored.Content = comment.Content
+		}
+		if comment.UserID != 0 {
+			stored.UserID = comment.UserID
+		}
+		if comment.DeletedAt != nil {
+			stored.DeletedAt = comment.DeletedAt
+		}
+		t.comments[comment.ID] = stored
+		return nil
+	})
+	return comment, nil
+}
+
+func (r *commentRepositoryMemory) DeleteComment(ctx context.Context, id int) error {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		for _, c := range t.comments {
+			if c.ParentID != nil && *c.ParentID == id {
+				return errRowReferenced
+			}
+		}
+		deleteCommentRevisions(t, id)
+		delete(t.comments, id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete comment with id %d: %w", id, err)
+	}
+	return nil
+}
+
+func (r *commentRepositoryMemory) ListComments(ctx context.Context, postID int, page Page) (*PageResult[*models.Comment], error) {
+	result, err := r.page(ctx, page, func(c models.Comment) bool { return c.PostID == postID })
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments for post with id %d: %w", postID, err)
+	}
+	return result, nil
+}
+
+func (r *commentRepositoryMemory) ListRootComments(ctx context.Context, postID int, page Page) (*PageResult[*models.Comment], error) {
+	result, err := r.page(ctx, page, func(c models.Comment) bool { return c.PostID == postID && c.ParentID == nil })
+	if err != nil {
+		return nil, fmt.Errorf("failed to list root comments for post with id %d: %w", postID, err)
+	}
+	return result, nil
+}
+
+func (r *commentRepositoryMemory) ListThreadReplies(ctx context.Context, roots []*models.Comment) ([]*models.Comment, error) {
+	replies := []*models.Comment{}
+	r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.comments {
+			isReply := slices.ContainsFunc(roots, func(root *models.Comment) bool {
+				return strings.HasPrefix(stored.Path, root.Path+"/")
+			})
+			if isReply {
+				reply := stored
+				replies = append(replies, &reply)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(replies, func(a, b *models.Comment) int { return strings.Compare(a.Path, b.Path) })
+	return replies, nil
+}
+
+func (r *commentRepositoryMemory) CountReplies(ctx context.Context, id int) (int64, error) {
+	var count int64
+	r.store.run(ctx, func(t *memoryTables) error {
+		for _, c := range t.comments {
+			if c.ParentID != nil && *c.ParentID == id {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, nil
+}
+
+func (r *commentRepositoryMemory) MarkCommentDeleted(ctx context.Context, id int, at time.Time) error {
+	r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.comments[id]
+		if !ok {
+			return nil
+		}
+		stored.Content = models.DeletedCommentContent
+		stored.DeletedAt = &at
+		t.comments[id] = stored
+		deleteCommentRevisions(t, id)
+		return nil
+	})
+	return nil
+}
+
+// deleteCommentRevisions is what deleting a comment's revisions does for the
+// GORM repository.
+func deleteCommentRevisions(t *memoryTables, commentID int) {
+	for _, rev := range t.commentRevisions {
+		if rev.CommentID == commentID {
+			delete(t.commentRevisions, rev.ID)
+		}
+	}
+}
+
+func (r *commentRepositoryMemory) page(ctx context.Context, page Page, match func(models.Comment) bool) (*PageResult[*models.Comment], error) {
+	var result *PageResult[*models.Comment]
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		comments := []*models.Comment{}
+		for _, stored := range t.comments {
+			if match(stored) {
+				comment := stored
+				comments = append(comments, &comment)
+			}
+		}
+		var err error
+		result, err = paginateSlice(comments, page, CommentSorts,
+			func(c *models.Comment, _ string) time.Time { return c.CreatedAt },
+			func(c *models.Comment) int { return c.ID })
+		return err
+	})
+	return result, err
+}
+
+// storedComment drops the associations, which live in their own tables.
+func storedComment(c models.Comment) models.Comment {
+	c.User = models.User{}
+	c.Post = models.Post{}
+	c.Parent = nil
+	return c
+}
+
+func NewMemoryCommentRepository(store *MemoryStore) CommentRepository {
+	return &commentRepositoryMemory{store: store}
+}
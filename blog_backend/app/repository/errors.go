@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"blog_backend/app/apperror"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// dbError turns the GORM errors callers act on into apperror kinds, so that
+// services never have to look for gorm.ErrRecordNotFound themselves. Unique
+// key violations are only recognised because the connection is opened with
+// TranslateError.
+func dbError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return apperror.ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return fmt.Errorf("%w: %w", apperror.ErrConflict, err)
+	}
+	return err
+}
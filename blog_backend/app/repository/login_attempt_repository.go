@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type LoginAttemptRepository interface {
+	RetrieveLoginAttempt(ctx context.Context, scope, identifier string) (*models.LoginAttempt, error)
+	SaveLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) (*models.LoginAttempt, error)
+	ResetLoginAttempts(ctx context.Context, scope, identifier string) error
+}
+
+type loginAttemptRepositoryGorm struct {
+	db *gorm.DB
+}
+
+// RetrieveLoginAttempt returns the counter for scope and identifier, or a fresh
+// unsaved counter when nothing has been recorded yet.
+func (r *loginAttemptRepositoryGorm) RetrieveLoginAttempt(ctx context.Context, scope, identifier string) (*models.LoginAttempt, error) {
+	attempt := &models.LoginAttempt{}
+	err := conn(ctx, r.db).Scopes(lockForUpdate(ctx)).Where("scope = ? AND identifier = ?", scope, identifier).First(attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.LoginAttempt{Scope: scope, Identifier: identifier}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve login attempts for %s %s: %w", scope, identifier, dbError(err))
+	}
+	return attempt, nil
+}
+
+func (r *loginAttemptRepositoryGorm) SaveLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) (*models.LoginAttempt, error) {
+	if err := conn(ctx, r.db).Save(attempt).Error; err != nil {
+		return nil, fmt.Errorf("failed to save login attempts for %s %s: %w", attempt.Scope, attempt.Identifier, dbError(err))
+	}
+	return attempt, nil
+}
+
+func (r *loginAttemptRepositoryGorm) ResetLoginAttempts(ctx context.Context, scope, identifier string) error {
+	err := conn(ctx, r.db).Where("scope = ? AND identifier = ?", scope, identifier).Delete(&models.LoginAttempt{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to reset login attempts for %s %s: %w", scope, identifier, dbError(err))
+	}
+	return nil
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &loginAttemptRepositoryGorm{db: db}
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+)
+
+type loginAttemptRepositoryMemory struct {
+	store *MemoryStore
+}
+
+func (r *loginAttemptRepositoryMemory) RetrieveLoginAttempt(ctx context.Context, scope, identifier string) (*models.LoginAttempt, error) {
+	attempt := &models.LoginAttempt{Scope: scope, Identifier: identifier}
+	r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.loginAttempts {
+			if stored.Scope == scope && stored.Identifier == identifier {
+				*attempt = stored
+				break
+			}
+		}
+		return nil
+	})
+	return attempt, nil
+}
+
+func (r *loginAttemptRepositoryMemory) SaveLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) (*models.LoginAttempt, error) {
+	r.store.run(ctx, func(t *memoryTables) error {
+		if attempt.ID == 0 {
+			attempt.ID = t.nextID("login_attempts")
+		}
+		t.loginAttempts[attempt.ID] = *attempt
+		return nil
+	})
+	return attempt, nil
+}
+
+func (r *loginAttemptRepositoryMemory) ResetLoginAttempts(ctx context.Context, scope, identifier string) error {
+	r.store.run(ctx, func(t *memoryTables) error {
+		for id, stored := range t.loginAttempts {
+			if stored.Scope == scope && stored.Identifier == identifier {
+				delete(t.loginAttempts, id)
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+func NewMemoryLoginAttemptRepository(store *MemoryStore) LoginAttemptRepository {
+	return &loginAttemptRepositoryMemory{store: store}
+}
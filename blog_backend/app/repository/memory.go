@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The in-memory repositories report missing rows and unique key violations
+// with the same errors as the GORM ones, so services cannot tell them apart.
+var (
+	errNoRow         = dbError(gorm.ErrRecordNotFound)
+	errDuplicateRow  = dbError(gorm.ErrDuplicatedKey)
+	errRowReferenced = dbError(gorm.ErrForeignKeyViolated)
+)
+
+// MemoryStore holds the tables behind the in-memory repositories. It is meant
+// for tests and for running the server without a database; nothing survives
+// the process.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data memoryTables
+}
+
+type memoryTables struct {
+	lastID               map[string]int
+	users                map[int]models.User
+	posts                map[int]models.Post
+	postTags             map[int][]int
+	tags                 map[int]models.Tag
+	categories           map[int]models.Category
+	comments             map[int]models.Comment
+	postRevisions        map[int]models.PostRevision
+	commentRevisions     map[int]models.CommentRevision
+	refreshTokens        map[int]models.RefreshToken
+	loginAttempts        map[int]models.LoginAttempt
+	userTokens           map[int]models.UserToken
+	recoveryCodes        map[int]models.RecoveryCode
+	siweNonces           map[int]models.SIWENonce
+	personalAccessTokens map[int]models.PersonalAccessToken
+	signingKeys          map[int]models.SigningKey
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: memoryTables{
+		lastID:               map[string]int{},
+		users:                map[int]models.User{},
+		posts:                map[int]models.Post{},
+		postTags:             map[int][]int{},
+		tags:                 map[int]models.Tag{},
+		categories:           map[int]models.Category{},
+		comments:             map[int]models.Comment{},
+		postRevisions:        map[int]models.PostRevision{},
+		commentRevisions:     map[int]models.CommentRevision{},
+		refreshTokens:        map[int]models.RefreshToken{},
+		loginAttempts:        map[int]models.LoginAttempt{},
+		userTokens:           map[int]models.UserToken{},
+		recoveryCodes:        map[int]models.RecoveryCode{},
+		siweNonces:           map[int]models.SIWENonce{},
+		personalAccessTokens: map[int]models.PersonalAccessToken{},
+		signingKeys:          map[int]models.SigningKey{},
+	}}
+}
+
+// clone copies every table. Rows are stored by value and replaced rather
+// than modified in place, so copying the maps is enough for a snapshot.
+func (t memoryTables) clone() memoryTables {
+	return memoryTables{
+		lastID:               maps.Clone(t.lastID),
+		users:                maps.Clone(t.users),
+		posts:                maps.Clone(t.posts),
+		postTags:             maps.Clone(t.postTags),
+		tags:                 maps.Clone(t.tags),
+		categories:           maps.Clone(t.categories),
+		comments:             maps.Clone(t.comments),
+		postRevisions:        maps.Clone(t.postRevisions),
+		commentRevisions:     maps.Clone(t.commentRevisions),
+		refreshTokens:        maps.Clone(t.refreshTokens),
+		loginAttempts:        maps.Clone(t.loginAttempts),
+		userTokens:           maps.Clone(t.userTokens),
+		recoveryCodes:        maps.Clone(t.recoveryCodes),
+		siweNonces:           maps.Clone(t.siweNonces),
+		personalAccessTokens: maps.Clone(t.personalAccessTokens),
+		signingKeys:          maps.Clone(t.signingKeys),
+	}
+}
+
+// nextID hands out auto-increment ids per table.
+func (t *memoryTables) nextID(table string) int {
+	t.lastID[table]++
+	return t.lastID[table]
+}
+
+type memoryTxKey struct{}
+
+// run calls fn with the tables locked. Inside a transaction of s the lock is
+// already held by the transaction.
+func (s *MemoryStore) run(ctx context.Context, fn func(t *memoryTables) error) error {
+	if tx, ok := ctx.Value(memoryTxKey{}).(*MemoryStore); ok && tx == s {
+		return fn(&s.data)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(&s.data)
+}
+
+// WithTx runs fn holding the store lock, so units of work are serialised,
+// and restores a snapshot of the tables when fn fails.
+func (s *MemoryStore) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if tx, ok := ctx.Value(memoryTxKey{}).(*MemoryStore); ok && tx == s {
+		return fn(ctx)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := s.data.clone()
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, s)); err != nil {
+		s.data = snapshot
+		return err
+	}
+	return nil
+}
+
+func NewMemoryTxManager(store *MemoryStore) TxManager {
+	return store
+}
+
+// paginateSlice is paginate for rows already in memory. It orders rows the
+// way the SQL query would, by the sort column and then by id.
+func paginateSlice[T any](rows []T, page Page, allowed []Sort, sortValue func(T, string) time.Time, id func(T) int) (*PageResult[T], error) {
+	if page.Sort == "" {
+		page.Sort = allowed[0]
+	}
+	if !sortAllowed(page.Sort, allowed) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSort, page.Sort)
+	}
+	column := page.Sort.column()
+	compare := func(a, b T) int {
+		return cmp.Or(sortValue(a, column).Compare(sortValue(b, column)), cmp.Compare(id(a), id(b)))
+	}
+	if page.Sort.Descending() {
+		slices.SortFunc(rows, func(a, b T) int { return compare(b, a) })
+	} else {
+		slices.SortFunc(rows, compare)
+	}
+
+	result := &PageResult[T]{Items: rows, Total: int64(len(rows))}
+	if page.Cursor != "" {
+		cursor, err := DecodeCursor(page.Cursor)
+		if err != nil || cursor.Sort != string(page.Sort) {
+			return nil, ErrInvalidCursor
+		}
+		start := slices.IndexFunc(rows, func(row T) bool {
+			c := cmp.Or(sortValue(row, column).Compare(cursor.Time), cmp.Compare(id(row), cursor.ID))
+			if page.Sort.Descending() {
+				return c < 0
+			}
+			return c > 0
+		})
+		if start < 0 {
+			start = len(rows)
+		}
+		result.Items = rows[start:]
+	}
+	limit := page.limit()
+	if len(result.Items) > limit {
+		result.Items = result.Items[:limit]
+		last := result.Items[limit-1]
+		result.NextCursor = EncodeCursor(Cursor{Sort: string(page.Sort), Time: sortValue(last, column), ID: id(last)})
+	}
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryTxRollsBack(t *testing.T) {
+	store := NewMemoryStore()
+	tx := NewMemoryTxManager(store)
+	users := NewMemoryUserRepository(store)
+	ctx := context.Background()
+
+	failure := errors.New("abort")
+	err := tx.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := users.CreateUser(ctx, &models.User{Username: "alice", Email: "alice@example.com"}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("got %v, want %v", err, failure)
+	}
+	if _, err := users.RetrieveUserByEmail(ctx, "alice@example.com"); err == nil {
+		t.Fatal("user created in a rolled back transaction was kept")
+	}
+
+	err = tx.WithTx(ctx, func(ctx context.Context) error {
+		_, err := users.CreateUser(ctx, &models.User{Username: "bob", Email: "bob@example.com"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if _, err := users.RetrieveUserByEmail(ctx, "bob@example.com"); err != nil {
+		t.Fatalf("committed user missing: %v", err)
+	}
+}
+
+func TestMemoryListPostsPages(t *testing.T) {
+	store := NewMemoryStore()
+	users := NewMemoryUserRepository(store)
+	posts := NewMemoryPostRepository(store)
+	ctx := context.Background()
+
+	author, err := users.CreateUser(ctx, &models.User{Username: "alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	// Equal timestamps make the id the tie breaker
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		_, err := posts.CreatePost(ctx, &models.Post{Title: "post", UserID: author.ID, CreatedAt: created.Add(time.Duration(i/2) * time.Hour)})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+	}
+
+	var ids []int
+	page := Page{Limit: 2}
+	for {
+		result, err := posts.ListPosts(ctx, PostListFilter{}, page)
+		if err != nil {
+			t.Fatalf("ListPosts: %v", err)
+		}
+		if result.Total != 5 {
+			t.Fatalf("got total %d, want 5", result.Total)
+		}
+		for _, p := range result.Items {
+			ids = append(ids, p.ID)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		page.Cursor = result.NextCursor
+	}
+	want := []int{5, 4, 3, 2, 1}
+	if len(ids) != len(want) {
+		t.Fatalf("got ids %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("got ids %v, want %v", ids, want)
+		}
+	}
+
+	if _, err := posts.ListPosts(ctx, PostListFilter{}, Page{Sort: "title"}); !errors.Is(err, ErrInvalidSort) {
+		t.Fatalf("got %v, want ErrInvalidSort", err)
+	}
+}
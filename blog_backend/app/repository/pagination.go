@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"blog_backend/app/apperror"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = apperror.InvalidField("cursor", "invalid cursor")
+	ErrInvalidSort   = apperror.InvalidField("sort", "unsupported sort")
+)
+
+// Cursor marks the last row of a page in a keyset pagination over
+// (sort column, id). It remembers the sort it was issued for so that a
+// cursor cannot be replayed against a different ordering.
+type Cursor struct {
+	Sort string    `json:"s"`
+	Time time.Time `json:"t"`
+	ID   int       `json:"id"`
+}
+
+func EncodeCursor(c Cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &Cursor{}
+	if err := json.Unmarshal(raw, c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Sort names a time column and a direction, written "created_at" for
+// ascending or "-created_at" for descending.
+type Sort string
+
+func (s Sort) column() string {
+	if s.Descending() {
+		return string(s[1:])
+	}
+	return string(s)
+}
+
+func (s Sort) Descending() bool {
+	return len(s) > 0 && s[0] == '-'
+}
+
+// Page selects one page of a keyset-paginated listing.
+type Page struct {
+	Sort   Sort
+	Cursor string
+	Limit  int
+}
+
+func (p Page) limit() int {
+	if p.Limit <= 0 {
+		return DefaultPageLimit
+	}
+	return min(p.Limit, MaxPageLimit)
+}
+
+// PageResult holds one page of rows, the total number of rows matching the
+// filters and the cursor for the following page, empty on the last page.
+type PageResult[T any] struct {
+	Items      []T
+	Total      int64
+	NextCursor string
+}
+
+// paginate runs query as a keyset page. The first allowed sort is the
+// default. sortValue extracts the sort column of a row so the next cursor can
+// be built from the last row returned. scopes only apply when fetching rows,
+// not when counting, which makes them the place for Preload.
+func paginate[T any](query *gorm.DB, page Page, allowed []Sort, sortValue func(T, string) time.Time, id func(T) int,
+	scopes ...func(*gorm.DB) *gorm.DB) (*PageResult[T], error) {
+	if page.Sort == "" {
+		page.Sort = allowed[0]
+	}
+	if !sortAllowed(page.Sort, allowed) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSort, page.Sort)
+	}
+	column := page.Sort.column()
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	op, dir := ">", "ASC"
+	if page.Sort.Descending() {
+		op, dir = "<", "DESC"
+	}
+	q := query.Session(&gorm.Session{}).Scopes(scopes...)
+	if page.Cursor != "" {
+		cursor, err := DecodeCursor(page.Cursor)
+		if err != nil || cursor.Sort != string(page.Sort) {
+			return nil, ErrInvalidCursor
+		}
+		q = q.Where(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, op),
+			cursor.Time, cursor.Time, cursor.ID)
+	}
+	limit := page.limit()
+	var items []T
+	err := q.Order(fmt.Sprintf("%s %s, id %s", column, dir, dir)).Limit(limit + 1).Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PageResult[T]{Items: items, Total: total}
+	if len(items) > limit {
+		result.Items = items[:limit]
+		last := result.Items[limit-1]
+		result.NextCursor = EncodeCursor(Cursor{Sort: string(page.Sort), Time: sortValue(last, column), ID: id(last)})
+	}
+	return result, nil
+}
+
+func sortAllowed(s Sort, allowed []Sort) bool {
+	for _, a := range allowed {
+		if s == a {
+			return true
+		}
+	}
+	return false
+}
+
+// likePattern turns user input into a LIKE substring pattern, escaping the
+// wildcard characters with '!'.
+func likePattern(s string) string {
+	escaped := make([]rune, 0, len(s)+2)
+	escaped = append(escaped, '%')
+	for _, r := range s {
+		if r == '%' || r == '_' || r == '!' {
+			escaped = append(escaped, '!')
+		}
+		escaped = append(escaped, r)
+	}
+	return string(append(escaped, '%'))
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PersonalAccessTokenRepository interface {
+	CreatePersonalAccessToken(ctx context.Context, token *models.PersonalAccessToken) (*models.PersonalAccessToken, error)
+	RetrievePersonalAccessTokenByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error)
+	ListPersonalAccessTokens(ctx context.Context, userID int) ([]models.PersonalAccessToken, error)
+	DeletePersonalAccessToken(ctx context.Context, userID, id int) error
+	DeleteUserPersonalAccessTokens(ctx context.Context, userID int) error
+	TouchPersonalAccessToken(ctx context.Context, id int, usedAt time.Time) error
+}
+
+type personalAccessTokenRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func (r *personalAccessTokenRepositoryGorm) CreatePersonalAccessToken(ctx context.Context, token *models.PersonalAccessToken) (*models.PersonalAccessToken, error) {
+	if err := conn(ctx, r.db).Create(token).Error; err != nil {
+		return nil, fmt.Errorf("failed to create personal access token: %w", dbError(err))
+	}
+	return token, nil
+}
+
+func (r *personalAccessTokenRepositoryGorm) RetrievePersonalAccessTokenByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	token := &models.PersonalAccessToken{}
+	if err := conn(ctx, r.db).Where("token_hash = ?", tokenHash).First(token).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve personal access token: %w", dbError(err))
+	}
+	return token, nil
+}
+
+// ListPersonalAccessTokens returns the tokens of a user, newest first.
+func (r *personalAccessTokenRepositoryGorm) ListPersonalAccessTokens(ctx context.Context, userID int) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	if err := conn(ctx, r.db).Where("user_id = ?", userID).Order("id DESC").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to list personal access tokens of user with id %d: %w", userID, dbError(err))
+	}
+	return tokens, nil
+}
+
+// DeletePersonalAccessToken deletes a token of a user. Tokens of other users
+// are not found.
+func (r *personalAccessTokenRepositoryGorm) DeletePersonalAccessToken(ctx context.Context, userID, id int) error {
+	result := conn(ctx, r.db).Where("id = ? AND user_id = ?", id, userID).Delete(&models.PersonalAccessToken{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete personal access token with id %d: %w", id, dbError(result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to delete personal access token with id %d: %w", id, errNoRow)
+	}
+	return nil
+}
+
+func (r *personalAccessTokenRepositoryGorm) DeleteUserPersonalAccessTokens(ctx context.Context, userID int) error {
+	if err := conn(ctx, r.db).Where("user_id = ?", userID).Delete(&models.PersonalAccessToken{}).Error; err != nil {
+		return fmt.Errorf("failed to delete personal access tokens of user with id %d: %w", userID, dbError(err))
+	}
+	return nil
+}
+
+func (r *personalAccessTokenRepositoryGorm) TouchPersonalAccessToken(ctx context.Context, id int, usedAt time.Time) error {
+	err := conn(ctx, r.db).Model(&models.PersonalAccessToken{ID: id}).Update("last_used_at", usedAt).Error
+	if err != nil {
+		return fmt.Errorf("failed to touch personal access token with id %d: %w", id, dbError(err))
+	}
+	return nil
+}
+
+func NewPersonalAccessTokenRepository(db *gorm.DB) PersonalAccessTokenRepository {
+	return &personalAccessTokenRepositoryGorm{db: db}
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type personalAccessTokenRepositoryMemory struct {
+	store *MemoryStore
+}
+
+func (r *personalAccessTokenRepositoryMemory) CreatePersonalAccessToken(ctx context.Context, token *models.PersonalAccessToken) (*models.PersonalAccessToken, error) {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		if _, ok := t.users[token.UserID]; !ok {
+			return errNoRow
+		}
+		for _, stored := range t.personalAccessTokens {
+			if stored.TokenHash == token.TokenHash {
+				return errDuplicateRow
+			}
+		}
+		token.ID = t.nextID("personal_access_tokens")
+		if token.CreatedAt.IsZero() {
+			token.CreatedAt = time.Now()
+		}
+		stored := *token
+		stored.User = models.User{}
+		t.personalAccessTokens[token.ID] = stored
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create personal access token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *personalAccessTokenRepositoryMemory) RetrievePersonalAccessTokenByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	token := &models.PersonalAccessToken{}
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.personalAccessTokens {
+			if stored.TokenHash == tokenHash {
+				*token = stored
+				return nil
+			}
+		}
+		return errNoRow
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve personal access token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *personalAccessTokenRepositoryMemory) ListPersonalAccessTokens(ctx context.Context, userID int) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.personalAccessTokens {
+			if stored.UserID == userID {
+				tokens = append(tokens, stored)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(tokens, func(a, b models.PersonalAccessToken) int { return cmp.Compare(b.ID, a.ID) })
+	return tokens, nil
+}
+
+func (r *personalAccessTokenRepositoryMemory) DeletePersonalAccessToken(ctx context.Context, userID, id int) error {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.personalAccessTokens[id]
+		if !ok || stored.UserID != userID {
+			return errNoRow
+		}
+		delete(t.personalAccessTokens, id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete personal access token with id %d: %w", id, err)
+	}
+	return nil
+}
+
+func (r *personalAccessTokenRepositoryMemory) DeleteUserPersonalAccessTokens(ctx context.Context, userID int) error {
+	r.store.run(ctx, func(t *memoryTables) error {
+		for id, stored := range t.personalAccessTokens {
+			if stored.UserID == userID {
+				delete(t.personalAccessTokens, id)
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+func (r *personalAccessTokenRepositoryMemory) TouchPersonalAccessToken(ctx context.Context, id int, usedAt time.Time) error {
+	r.store.run(ctx, func(t *memoryTables) error {
+		if stored, ok := t.personalAccessTokens[id]; ok {
+			stored.LastUsedAt = &usedAt
+			t.personalAccessTokens[id] = stored
+		}
+		return nil
+	})
+	return nil
+}
+
+func NewMemoryPersonalAccessTokenRepository(store *MemoryStore) PersonalAccessTokenRepository {
+	return &personalAccessTokenRepositoryMemory{store: store}
+}
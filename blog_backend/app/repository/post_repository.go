@@ -2,52 +2,185 @@ package repository
 
 import (
 	"blog_backend/app/models"
+	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PostRepository interface {
-	CreatePost(post *models.Post) (*models.Post, error)
-	RetrievePost(id int) (*models.Post, error)
-	UpdatePost(post *models.Post) (*models.Post, error)
-	DeletePost(id int) error
+	CreatePost(ctx context.Context, post *models.Post) (*models.Post, error)
+	RetrievePost(ctx context.Context, id int) (*models.Post, error)
+	UpdatePost(ctx context.Context, post *models.Post) (*models.Post, error)
+	DeletePost(ctx context.Context, id int) error
+	ListPosts(ctx context.Context, filter PostListFilter, page Page) (*PageResult[*models.Post], error)
+	ReplacePostTags(ctx context.Context, post *models.Post, tags []models.Tag) error
+	ListDueScheduledPosts(ctx context.Context, now time.Time, limit int) ([]*models.Post, error)
+	PublishScheduledPost(ctx context.Context, id int, now time.Time) (bool, error)
 }
 
+// PostListFilter narrows ListPosts. Zero values leave a filter unset.
+type PostListFilter struct {
+	AuthorID      int
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	TitleContains string
+	TagSlug       string
+	CategoryIDs   []int
+	Statuses      []models.PostStatus
+}
+
+var PostSorts = []Sort{"-created_at", "created_at", "-updated_at", "updated_at"}
+
 type postRepositoryGorm struct {
 	db *gorm.DB
 }
 
-func (r *postRepositoryGorm) CreatePost(post *models.Post) (*models.Post, error) {
-	if err := r.db.Create(post).Error; err != nil {
-		return nil, fmt.Errorf("failed to create post: %w", err)
+func (r *postRepositoryGorm) CreatePost(ctx context.Context, post *models.Post) (*models.Post, error) {
+	if err := conn(ctx, r.db).Create(post).Error; err != nil {
+		return nil, fmt.Errorf("failed to create post: %w", dbError(err))
 	}
 	return post, nil
 }
 
-func (r *postRepositoryGorm) RetrievePost(id int) (*models.Post, error) {
+func (r *postRepositoryGorm) RetrievePost(ctx context.Context, id int) (*models.Post, error) {
 	post := &models.Post{}
-	if err := r.db.First(post, id).Error; err != nil {
-		return nil, fmt.Errorf("failed to retrieve post with id %d: %w", id, err)
+	if err := conn(ctx, r.db).Scopes(lockForUpdate(ctx)).Preload("Tags").First(post, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve post with id %d: %w", id, dbError(err))
 	}
 	return post, nil
 }
 
-func (r *postRepositoryGorm) UpdatePost(post *models.Post) (*models.Post, error) {
-	err := r.db.Model(&models.Post{}).Where("id = ?", post.ID).Updates(post).Error
+func (r *postRepositoryGorm) UpdatePost(ctx context.Context, post *models.Post) (*models.Post, error) {
+	err := conn(ctx, r.db).Model(&models.Post{}).Omit(clause.Associations).Where("id = ?", post.ID).
+		Select("title", "content", "category_id", "status", "publish_at", "published_at", "updated_at").
+		Updates(post).Error
 	if err != nil {
-		return nil, fmt.Errorf("failed to update post with id %d: %w", post.ID, err)
+		return nil, fmt.Errorf("failed to update post with id %d: %w", post.ID, dbError(err))
 	}
 	return post, nil
 }
 
-func (r *postRepositoryGorm) DeletePost(id int) error {
-	if err := r.db.Delete(&models.Post{}, id).Error; err != nil {
-		return fmt.Errorf("failed to delete post with id %d: %w", id, err)
+// DeletePost deletes the post together with its tag links, comments and the
+// revisions of both. The
+// post is loaded first because its AfterDelete hook needs the author to keep
+// the post count right.
+func (r *postRepositoryGorm) DeletePost(ctx context.Context, id int) error {
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		post := &models.Post{}
+		if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(post, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id = ?", id).Delete(&models.PostTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id = ?", id).Delete(&models.PostRevision{}).Error; err != nil {
+			return err
+		}
+		err := tx.Where("comment_id IN (?)", tx.Model(&models.Comment{}).Select("id").Where("post_id = ?", id)).
+			Delete(&models.CommentRevision{}).Error
+		if err != nil {
+			return err
+		}
+		// Replies reference their parents, so remove the deepest level first
+		var maxDepth int
+		err = tx.Model(&models.Comment{}).Where("post_id = ?", id).Select("COALESCE(MAX(depth), 0)").Scan(&maxDepth).Error
+		if err != nil {
+			return err
+		}
+		for depth := maxDepth; depth >= 0; depth-- {
+			if err := tx.Where("post_id = ? AND depth = ?", id, depth).Delete(&models.Comment{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(post).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete post with id %d: %w", id, dbError(err))
 	}
 	return nil
 }
 
+func (r *postRepositoryGorm) ListPosts(ctx context.Context, filter PostListFilter, page Page) (*PageResult[*models.Post], error) {
+	db := conn(ctx, r.db)
+	query := db.Model(&models.Post{})
+	if filter.AuthorID != 0 {
+		query = query.Where("user_id = ?", filter.AuthorID)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query = query.Where("created_at >= ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("created_at < ?", filter.CreatedBefore)
+	}
+	if filter.TitleContains != "" {
+		query = query.Where("LOWER(title) LIKE ? ESCAPE '!'", likePattern(strings.ToLower(filter.TitleContains)))
+	}
+	if filter.TagSlug != "" {
+		query = query.Where("id IN (?)", db.Table("post_tags").
+			Select("post_tags.post_id").
+			Joins("JOIN tags ON tags.id = post_tags.tag_id").
+			Where("tags.slug = ?", filter.TagSlug))
+	}
+	if len(filter.CategoryIDs) > 0 {
+		query = query.Where("category_id IN ?", filter.CategoryIDs)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	result, err := paginate(query, page, PostSorts, postSortValue, func(p *models.Post) int { return p.ID },
+		func(db *gorm.DB) *gorm.DB { return db.Preload("Tags") })
+	if err != nil {
+		return nil, fmt.Errorf("failed to list posts: %w", dbError(err))
+	}
+	return result, nil
+}
+
+func (r *postRepositoryGorm) ReplacePostTags(ctx context.Context, post *models.Post, tags []models.Tag) error {
+	if err := conn(ctx, r.db).Model(post).Association("Tags").Replace(tags); err != nil {
+		return fmt.Errorf("failed to replace tags of post with id %d: %w", post.ID, dbError(err))
+	}
+	post.Tags = tags
+	return nil
+}
+
+func (r *postRepositoryGorm) ListDueScheduledPosts(ctx context.Context, now time.Time, limit int) ([]*models.Post, error) {
+	var posts []*models.Post
+	err := conn(ctx, r.db).Where("status = ? AND publish_at <= ?", models.PostStatusScheduled, now).
+		Order("publish_at ASC").Limit(limit).Find(&posts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled posts: %w", dbError(err))
+	}
+	return posts, nil
+}
+
+// PublishScheduledPost flips a due scheduled post to published. The checks
+// in the WHERE clause make it safe to race: it reports true only to the one
+// caller whose update actually published the post, and a post rescheduled in
+// the meantime is left alone.
+func (r *postRepositoryGorm) PublishScheduledPost(ctx context.Context, id int, now time.Time) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.Post{}).
+		Where("id = ? AND status = ? AND publish_at <= ?", id, models.PostStatusScheduled, now).
+		Updates(map[string]any{
+			"status":       models.PostStatusPublished,
+			"published_at": now,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to publish post with id %d: %w", id, dbError(result.Error))
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func postSortValue(p *models.Post, column string) time.Time {
+	if column == "updated_at" {
+		return p.UpdatedAt
+	}
+	return p.CreatedAt
+}
+
 func NewPostRepository(db *gorm.DB) PostRepository {
 	return &postRepositoryGorm{db: db}
 }
@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type postRepositoryMemory struct {
+	store *MemoryStore
+}
+
+func (r *postRepositoryMemory) CreatePost(ctx context.Context, post *models.Post) (*models.Post, error) {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		author, ok := t.users[post.UserID]
+		if !ok {
+			return errRowReferenced
+		}
+		now := time.Now()
+		post.ID = t.nextID("posts")
+		if post.Status == "" {
+			post.Status = models.PostStatusPublished
+		}
+		if post.CreatedAt.IsZero() {
+			post.CreatedAt = now
+		}
+		post.UpdatedAt = now
+		t.posts[post.ID] = storedPost(*post)
+		t.postTags[post.ID] = t.saveTags(post.Tags)
+
+		// What the AfterCreate hook does for the GORM repository
+		author.NumberOfPosts++
+		t.users[author.ID] = author
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create post: %w", err)
+	}
+	return post, nil
+}
+
+func (r *postRepositoryMemory) RetrievePost(ctx context.Context, id int) (*models.Post, error) {
+	post := &models.Post{}
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.posts[id]
+		if !ok {
+			return errNoRow
+		}
+		*post = t.postWithTags(stored)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve post with id %d: %w", id, err)
+	}
+	return post, nil
+}
+
+func (r *postRepositoryMemory) UpdatePost(ctx context.Context, post *models.Post) (*models.Post, error) {
+	r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.posts[post.ID]
+		if !ok {
+			return nil
+		}
+		post.UpdatedAt = time.Now()
+		stored.Title = post.Title
+		stored.Content = post.Content
+		stored.CategoryID = post.CategoryID
+		stored.Status = post.Status
+		stored.PublishAt = post.PublishAt
+		stored.PublishedAt = post.PublishedAt
+		stored.UpdatedAt = post.UpdatedAt
+		t.posts[post.ID] = stored
+		return nil
+	})
+	return post, nil
+}
+
+func (r *postRepositoryMemory) DeletePost(ctx context.Context, id int) error {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		post, ok := t.posts[id]
+		if !ok {
+			return errNoRow
+		}
+		for _, c := range t.comments {
+			if c.PostID == id {
+				deleteCommentRevisions(t, c.ID)
+				delete(t.comments, c.ID)
+			}
+		}
+		for _, rev := range t.postRevisions {
+			if rev.PostID == id {
+				delete(t.postRevisions, rev.ID)
+			}
+		}
+		delete(t.posts, id)
+		delete(t.postTags, id)
+
+		// What the AfterDelete hook does for the GORM repository
+		if author, ok := t.users[post.UserID]; ok {
+			author.NumberOfPosts--
+			t.users[author.ID] = author
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete post with id %d: %w", id, err)
+	}
+	return nil
+}
+
+func (r *postRepositoryMemory) ListPosts(ctx context.Context, filter PostListFilter, page Page) (*PageResult[*models.Post], error) {
+	var result *PageResult[*models.Post]
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		posts := []*models.Post{}
+		for _, stored := range t.posts {
+			if t.postMatches(stored, filter) {
+				post := t.postWithTags(stored)
+				posts = append(posts, &post)
+			}
+		}
+		var err error
+		result, err = paginateSlice(posts, page, PostSorts, postSortValue, func(p *models.Post) int { return p.ID })
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list posts: %w", err)
+	}
+	return result, nil
+}
+
+func (r *postRepositoryMemory) ReplacePostTags(ctx context.Context, post *models.Post, tags []models.Tag) error {
+	r.store.run(ctx, func(t *memoryTables) error {
+		t.postTags[post.ID] = t.saveTags(tags)
+		return nil
+	})
+	post.Tags = tags
+	return nil
+}
+
+func (r *postRepositoryMemory) ListDueScheduledPosts(ctx context.Context, now time.Time, limit int) ([]*models.Post, error) {
+	posts := []*models.Post{}
+	r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.posts {
+			if stored.Status == models.PostStatusScheduled && stored.PublishAt != nil && !stored.PublishAt.After(now) {
+				post := stored
+				posts = append(posts, &post)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(posts, func(a, b *models.Post) int {
+		return cmp.Or(a.PublishAt.Compare(*b.PublishAt), cmp.Compare(a.ID, b.ID))
+	})
+	return posts[:min(len(posts), limit)], nil
+}
+
+func (r *postRepositoryMemory) PublishScheduledPost(ctx context.Context, id int, now time.Time) (bool, error) {
+	published := false
+	r.store.run(ctx, func(t *memoryTables) error {
+		post, ok := t.posts[id]
+		if !ok || post.Status != models.PostStatusScheduled || post.PublishAt == nil || post.PublishAt.After(now) {
+			return nil
+		}
+		post.Status = models.PostStatusPublished
+		post.PublishedAt = &now
+		post.UpdatedAt = time.Now()
+		t.posts[id] = post
+		published = true
+		return nil
+	})
+	return published, nil
+}
+
+func (t *memoryTables) postMatches(post models.Post, filter PostListFilter) bool {
+	switch {
+	case filter.AuthorID != 0 && post.UserID != filter.AuthorID,
+		!filter.CreatedAfter.IsZero() && post.CreatedAt.Before(filter.CreatedAfter),
+		!filter.CreatedBefore.IsZero() && !post.CreatedAt.Before(filter.CreatedBefore),
+		filter.TitleContains != "" && !strings.Contains(strings.ToLower(post.Title), strings.ToLower(filter.TitleContains)),
+		len(filter.CategoryIDs) > 0 && (post.CategoryID == nil || !slices.Contains(filter.CategoryIDs, *post.CategoryID)),
+		len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, post.Status):
+		return false
+	}
+	if filter.TagSlug != "" {
+		return slices.ContainsFunc(t.postTags[post.ID], func(id int) bool { return t.tags[id].Slug == filter.TagSlug })
+	}
+	return true
+}
+
+// postWithTags is the stored post with its tags loaded, ordered by id like
+// a GORM Preload returns them.
+func (t *memoryTables) postWithTags(post models.Post) models.Post {
+	post.Tags = []models.Tag{}
+	for _, id := range t.postTags[post.ID] {
+		post.Tags = append(post.Tags, t.tags[id])
+	}
+	slices.SortFunc(post.Tags, func(a, b models.Tag) int { return cmp.Compare(a.ID, b.ID) })
+	return post
+}
+
+// saveTags stores the tags that are not stored yet, as GORM does for the
+// associations of a record it creates, and returns the ids of all of them.
+func (t *memoryTables) saveTags(tags []models.Tag) []int {
+	ids := make([]int, 0, len(tags))
+	for i := range tags {
+		if tags[i].ID == 0 {
+			tags[i].ID = t.nextID("tags")
+			tags[i].CreatedAt = time.Now()
+			t.tags[tags[i].ID] = tags[i]
+		}
+		ids = append(ids, tags[i].ID)
+	}
+	return ids
+}
+
+// storedPost drops the associations, which live in their own tables.
+func storedPost(p models.Post) models.Post {
+	p.User = models.User{}
+	p.Category = nil
+	p.Tags = nil
+	p.Comments = nil
+	return p
+}
+
+func NewMemoryPostRepository(store *MemoryStore) PostRepository {
+	return &postRepositoryMemory{store: store}
+}
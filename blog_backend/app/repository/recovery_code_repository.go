@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepository interface {
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+	DeleteRecoveryCodes(ctx context.Context, userID int) error
+}
+
+type recoveryCodeRepositoryGorm struct {
+	db *gorm.DB
+}
+
+// ReplaceRecoveryCodes drops the recovery codes of a user, used or not, and
+// stores new ones.
+func (r *recoveryCodeRepositoryGorm) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	if err := r.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+	codes := make([]models.RecoveryCode, len(codeHashes))
+	for i, codeHash := range codeHashes {
+		codes[i] = models.RecoveryCode{UserID: userID, CodeHash: codeHash}
+	}
+	if err := conn(ctx, r.db).Create(&codes).Error; err != nil {
+		return fmt.Errorf("failed to create recovery codes of user with id %d: %w", userID, dbError(err))
+	}
+	return nil
+}
+
+// UseRecoveryCode marks the unused code of a user with codeHash as used, and
+// reports false when there is none.
+func (r *recoveryCodeRepositoryGorm) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to use recovery code of user with id %d: %w", userID, dbError(result.Error))
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *recoveryCodeRepositoryGorm) DeleteRecoveryCodes(ctx context.Context, userID int) error {
+	if err := conn(ctx, r.db).Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return fmt.Errorf("failed to delete recovery codes of user with id %d: %w", userID, dbError(err))
+	}
+	return nil
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepositoryGorm{db: db}
+}
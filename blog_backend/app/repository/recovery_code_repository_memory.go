@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+type recoveryCodeRepositoryMemory struct {
+	store *MemoryStore
+}
+
+func (r *recoveryCodeRepositoryMemory) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		if _, ok := t.users[userID]; !ok {
+			return errNoRow
+		}
+		deleteRecoveryCodes(t, userID)
+		for _, codeHash := range codeHashes {
+			for _, stored := range t.recoveryCodes {
+				if stored.CodeHash == codeHash {
+					return errDuplicateRow
+				}
+			}
+			id := t.nextID("recovery_codes")
+			t.recoveryCodes[id] = models.RecoveryCode{ID: id, UserID: userID, CodeHash: codeHash, CreatedAt: time.Now()}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create recovery codes of user with id %d: %w", userID, err)
+	}
+	return nil
+}
+
+func (r *recoveryCodeRepositoryMemory) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	used := false
+	r.store.run(ctx, func(t *memoryTables) error {
+		for id, stored := range t.recoveryCodes {
+			if stored.UserID == userID && stored.CodeHash == codeHash && stored.UsedAt == nil {
+				now := time.Now()
+				stored.UsedAt = &now
+				t.recoveryCodes[id] = stored
+				used = true
+				return nil
+			}
+		}
+		return nil
+	})
+	return used, nil
+}
+
+func (r *recoveryCodeRepositoryMemory) DeleteRecoveryCodes(ctx context.Context, userID int) error {
+	r.store.run(ctx, func(t *memoryTables) error {
+		deleteRecoveryCodes(t, userID)
+		return nil
+	})
+	return nil
+}
+
+func deleteRecoveryCodes(t *memoryTables, userID int) {
+	for id, stored := range t.recoveryCodes {
+		if stored.UserID == userID {
+			delete(t.recoveryCodes, id)
+		}
+	}
+}
+
+func NewMemoryRecoveryCodeRepository(store *MemoryStore) RecoveryCodeRepository {
+	return &recoveryCodeRepositoryMemory{store: store}
+}
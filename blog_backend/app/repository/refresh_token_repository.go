@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) (*models.RefreshToken, error)
+	RetrieveRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, id int) (bool, error)
+	RevokeTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserTokens(ctx context.Context, userID int) error
+	IsFamilyActive(ctx context.Context, familyID string) (bool, error)
+}
+
+type refreshTokenRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func (r *refreshTokenRepositoryGorm) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) (*models.RefreshToken, error) {
+	if err := conn(ctx, r.db).Create(token).Error; err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", dbError(err))
+	}
+	return token, nil
+}
+
+func (r *refreshTokenRepositoryGorm) RetrieveRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+	if err := conn(ctx, r.db).Scopes(lockForUpdate(ctx)).Where("token_hash = ?", tokenHash).First(token).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve refresh token: %w", dbError(err))
+	}
+	return token, nil
+}
+
+// RevokeRefreshToken marks a single token as revoked. It reports false when
+// the token had already been revoked, which lets callers detect a concurrent
+// rotation of the same token.
+func (r *refreshTokenRepositoryGorm) RevokeRefreshToken(ctx context.Context, id int) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to revoke refresh token with id %d: %w", id, dbError(result.Error))
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *refreshTokenRepositoryGorm) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	err := conn(ctx, r.db).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token family %s: %w", familyID, dbError(err))
+	}
+	return nil
+}
+
+// RevokeUserTokens ends every session of a user.
+func (r *refreshTokenRepositoryGorm) RevokeUserTokens(ctx context.Context, userID int) error {
+	err := conn(ctx, r.db).Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens of user with id %d: %w", userID, dbError(err))
+	}
+	return nil
+}
+
+// IsFamilyActive reports whether the session still holds a usable refresh
+// token. Rotation always leaves exactly one live token in the family, so an
+// empty result means the session was logged out, compromised or has expired.
+func (r *refreshTokenRepositoryGorm) IsFamilyActive(ctx context.Context, familyID string) (bool, error) {
+	var count int64
+	err := conn(ctx, r.db).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL AND expires_at > ?", familyID, time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check refresh token family %s: %w", familyID, dbError(err))
+	}
+	return count > 0, nil
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepositoryGorm{db: db}
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+type refreshTokenRepositoryMemory struct {
+	store *MemoryStore
+}
+
+func (r *refreshTokenRepositoryMemory) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) (*models.RefreshToken, error) {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.refreshTokens {
+			if stored.TokenHash == token.TokenHash {
+				return errDuplicateRow
+			}
+		}
+		token.ID = t.nextID("refresh_tokens")
+		if token.CreatedAt.IsZero() {
+			token.CreatedAt = time.Now()
+		}
+		stored := *token
+		stored.User = models.User{}
+		t.refreshTokens[token.ID] = stored
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *refreshTokenRepositoryMemory) RetrieveRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.refreshTokens {
+			if stored.TokenHash == tokenHash {
+				*token = stored
+				return nil
+			}
+		}
+		return errNoRow
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve refresh token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *refreshTokenRepositoryMemory) RevokeRefreshToken(ctx context.Context, id int) (bool, error) {
+	revoked := false
+	r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.refreshTokens[id]
+		if !ok || stored.RevokedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		stored.RevokedAt = &now
+		t.refreshTokens[id] = stored
+		revoked = true
+		return nil
+	})
+	return revoked, nil
+}
+
+func (r *refreshTokenRepositoryMemory) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	r.store.run(ctx, func(t *memoryTables) error {
+		now := time.Now()
+		for id, stored := range t.refreshTokens {
+			if stored.FamilyID == familyID && stored.RevokedAt == nil {
+				stored.RevokedAt = &now
+				t.refreshTokens[id] = stored
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+func (r *refreshTokenRepositoryMemory) RevokeUserTokens(ctx context.Context, userID int) error {
+	r.store.run(ctx, func(t *memoryTables) error {
+		now := time.Now()
+		for id, stored := range t.refreshTokens {
+			if stored.UserID == userID && stored.RevokedAt == nil {
+				stored.RevokedAt = &now
+				t.refreshTokens[id] = stored
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+func (r *refreshTokenRepositoryMemory) IsFamilyActive(ctx context.Context, familyID string) (bool, error) {
+	active := false
+	r.store.run(ctx, func(t *memoryTables) error {
+		now := time.Now()
+		for _, stored := range t.refreshTokens {
+			if stored.FamilyID == familyID && stored.RevokedAt == nil && stored.ExpiresAt.After(now) {
+				active = true
+			}
+		}
+		return nil
+	})
+	return active, nil
+}
+
+func NewMemoryRefreshTokenRepository(store *MemoryStore) RefreshTokenRepository {
+	return &refreshTokenRepositoryMemory{store: store}
+}
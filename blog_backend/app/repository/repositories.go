@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+)
+
+// Repositories bundles every repository the services use, so the app can be
+// wired to the database or to a MemoryStore alike.
+type Repositories struct {
+	Tx                   TxManager
+	Users                UserRepository
+	Posts                PostRepository
+	Comments             CommentRepository
+	Search               SearchRepository
+	Tags                 TagRepository
+	Categories           CategoryRepository
+	RefreshTokens        RefreshTokenRepository
+	LoginAttempts        LoginAttemptRepository
+	Revisions            RevisionRepository
+	UserTokens           UserTokenRepository
+	RecoveryCodes        RecoveryCodeRepository
+	SIWENonces           SIWENonceRepository
+	PersonalAccessTokens PersonalAccessTokenRepository
+	SigningKeys          SigningKeyRepository
+}
+
+func NewGormRepositories(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Tx:                   NewTxManager(db),
+		Users:                NewUserRepository(db),
+		Posts:                NewPostRepository(db),
+		Comments:             NewCommentRepository(db),
+		Search:               NewSearchRepository(db),
+		Tags:                 NewTagRepository(db),
+		Categories:           NewCategoryRepository(db),
+		RefreshTokens:        NewRefreshTokenRepository(db),
+		LoginAttempts:        NewLoginAttemptRepository(db),
+		Revisions:            NewRevisionRepository(db),
+		UserTokens:           NewUserTokenRepository(db),
+		RecoveryCodes:        NewRecoveryCodeRepository(db),
+		SIWENonces:           NewSIWENonceRepository(db),
+		PersonalAccessTokens: NewPersonalAccessTokenRepository(db),
+		SigningKeys:          NewSigningKeyRepository(db),
+	}
+}
+
+func NewMemoryRepositories(store *MemoryStore) *Repositories {
+	return &Repositories{
+		Tx:                   NewMemoryTxManager(store),
+		Users:                NewMemoryUserRepository(store),
+		Posts:                NewMemoryPostRepository(store),
+		Comments:             NewMemoryCommentRepository(store),
+		Search:               NewMemorySearchRepository(store),
+		Tags:                 NewMemoryTagRepository(store),
+		Categories:           NewMemoryCategoryRepository(store),
+		RefreshTokens:        NewMemoryRefreshTokenRepository(store),
+		LoginAttempts:        NewMemoryLoginAttemptRepository(store),
+		Revisions:            NewMemoryRevisionRepository(store),
+		UserTokens:           NewMemoryUserTokenRepository(store),
+		RecoveryCodes:        NewMemoryRecoveryCodeRepository(store),
+		SIWENonces:           NewMemorySIWENonceRepository(store),
+		PersonalAccessTokens: NewMemoryPersonalAccessTokenRepository(store),
+		SigningKeys:          NewMemorySigningKeyRepository(store),
+	}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type RevisionRepository interface {
+	CreatePostRevision(ctx context.Context, revision *models.PostRevision) (*models.PostRevision, error)
+	ListPostRevisions(ctx context.Context, postID int) ([]*models.PostRevision, error)
+	RetrievePostRevision(ctx context.Context, postID, revision int) (*models.PostRevision, error)
+	CreateCommentRevision(ctx context.Context, revision *models.CommentRevision) (*models.CommentRevision, error)
+	ListCommentRevisions(ctx context.Context, commentID int) ([]*models.CommentRevision, error)
+	RetrieveCommentRevision(ctx context.Context, commentID, revision int) (*models.CommentRevision, error)
+}
+
+type revisionRepositoryGorm struct {
+	db *gorm.DB
+}
+
+// CreatePostRevision stores revision as the next revision of its post and
+// fills in the assigned revision number.
+func (r *revisionRepositoryGorm) CreatePostRevision(ctx context.Context, revision *models.PostRevision) (*models.PostRevision, error) {
+	var latest int
+	db := conn(ctx, r.db)
+	err := db.Model(&models.PostRevision{}).Where("post_id = ?", revision.PostID).
+		Select("COALESCE(MAX(revision), 0)").Scan(&latest).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to number revision of post with id %d: %w", revision.PostID, dbError(err))
+	}
+	revision.Revision = latest + 1
+	if err := db.Create(revision).Error; err != nil {
+		return nil, fmt.Errorf("failed to create revision of post with id %d: %w", revision.PostID, dbError(err))
+	}
+	return revision, nil
+}
+
+func (r *revisionRepositoryGorm) ListPostRevisions(ctx context.Context, postID int) ([]*models.PostRevision, error) {
+	var revisions []*models.PostRevision
+	if err := conn(ctx, r.db).Where("post_id = ?", postID).Order("revision DESC").Find(&revisions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list revisions of post with id %d: %w", postID, dbError(err))
+	}
+	return revisions, nil
+}
+
+func (r *revisionRepositoryGorm) RetrievePostRevision(ctx context.Context, postID, revision int) (*models.PostRevision, error) {
+	rev := &models.PostRevision{}
+	if err := conn(ctx, r.db).Where("post_id = ? AND revision = ?", postID, revision).First(rev).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve revision %d of post with id %d: %w", revision, postID, dbError(err))
+	}
+	return rev, nil
+}
+
+func (r *revisionRepositoryGorm) CreateCommentRevision(ctx context.Context, revision *models.CommentRevision) (*models.CommentRevision, error) {
+	var latest int
+	db := conn(ctx, r.db)
+	err := db.Model(&models.CommentRevision{}).Where("comment_id = ?", revision.CommentID).
+		Select("COALESCE(MAX(revision), 0)").Scan(&latest).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to number revision of comment with id %d: %w", revision.CommentID, dbError(err))
+	}
+	revision.Revision = latest + 1
+	if err := db.Create(revision).Error; err != nil {
+		return nil, fmt.Errorf("failed to create revision of comment with id %d: %w", revision.CommentID, dbError(err))
+	}
+	return revision, nil
+}
+
+func (r *revisionRepositoryGorm) ListCommentRevisions(ctx context.Context, commentID int) ([]*models.CommentRevision, error) {
+	var revisions []*models.CommentRevision
+	if err := conn(ctx, r.db).Where("comment_id = ?", commentID).Order("revision DESC").Find(&revisions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list revisions of comment with id %d: %w", commentID, dbError(err))
+	}
+	return revisions, nil
+}
+
+func (r *revisionRepositoryGorm) RetrieveCommentRevision(ctx context.Context, commentID, revision int) (*models.CommentRevision, error) {
+	rev := &models.CommentRevision{}
+	if err := conn(ctx, r.db).Where("comment_id = ? AND revision = ?", commentID, revision).First(rev).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve revision %d of comment with id %d: %w", revision, commentID, dbError(err))
+	}
+	return rev, nil
+}
+
+func NewRevisionRepository(db *gorm.DB) RevisionRepository {
+	return &revisionRepositoryGorm{db: db}
+}
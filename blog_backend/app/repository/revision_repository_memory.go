@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type revisionRepositoryMemory struct {
+	store *MemoryStore
+}
+
+func (r *revisionRepositoryMemory) CreatePostRevision(ctx context.Context, revision *models.PostRevision) (*models.PostRevision, error) {
+	r.store.run(ctx, func(t *memoryTables) error {
+		latest := 0
+		for _, rev := range t.postRevisions {
+			if rev.PostID == revision.PostID {
+				latest = max(latest, rev.Revision)
+			}
+		}
+		revision.ID = t.nextID("post_revisions")
+		revision.Revision = latest + 1
+		if revision.CreatedAt.IsZero() {
+			revision.CreatedAt = time.Now()
+		}
+		t.postRevisions[revision.ID] = *revision
+		return nil
+	})
+	return revision, nil
+}
+
+func (r *revisionRepositoryMemory) ListPostRevisions(ctx context.Context, postID int) ([]*models.PostRevision, error) {
+	revisions := []*models.PostRevision{}
+	r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.postRevisions {
+			if stored.PostID == postID {
+				rev := stored
+				revisions = append(revisions, &rev)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(revisions, func(a, b *models.PostRevision) int { return cmp.Compare(b.Revision, a.Revision) })
+	return revisions, nil
+}
+
+func (r *revisionRepositoryMemory) RetrievePostRevision(ctx context.Context, postID, revision int) (*models.PostRevision, error) {
+	rev := &models.PostRevision{}
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.postRevisions {
+			if stored.PostID == postID && stored.Revision == revision {
+				*rev = stored
+				return nil
+			}
+		}
+		return errNoRow
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve revision %d of post with id %d: %w", revision, postID, err)
+	}
+	return rev, nil
+}
+
+func (r *revisionRepositoryMemory) CreateCommentRevision(ctx context.Context, revision *models.CommentRevision) (*models.CommentRevision, error) {
+	r.store.run(ctx, func(t *memoryTables) error {
+		latest := 0
+		for _, rev := range t.commentRevisions {
+			if rev.CommentID == revision.CommentID {
+				latest = max(latest, rev.Revision)
+			}
+		}
+		revision.ID = t.nextID("comment_revisions")
+		revision.Revision = latest + 1
+		if revision.CreatedAt.IsZero() {
+			revision.CreatedAt = time.Now()
+		}
+		t.commentRevisions[revision.ID] = *revision
+		return nil
+	})
+	return revision, nil
+}
+
+func (r *revisionRepositoryMemory) ListCommentRevisions(ctx context.Context, commentID int) ([]*models.CommentRevision, error) {
+	revisions := []*models.CommentRevision{}
+	r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.commentRevisions {
+			if stored.CommentID == commentID {
+				rev := stored
+				revisions = append(revisions, &rev)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(revisions, func(a, b *models.CommentRevision) int { return cmp.Compare(b.Revision, a.Revision) })
+	return revisions, nil
+}
+
+func (r *revisionRepositoryMemory) RetrieveCommentRevision(ctx context.Context, commentID, revision int) (*models.CommentRevision, error) {
+	rev := &models.CommentRevision{}
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.commentRevisions {
+			if stored.CommentID == commentID && stored.Revision == revision {
+				*rev = stored
+				return nil
+			}
+		}
+		return errNoRow
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve revision %d of comment with id %d: %w", revision, commentID, err)
+	}
+	return rev, nil
+}
+
+func NewMemoryRevisionRepository(store *MemoryStore) RevisionRepository {
+	return &revisionRepositoryMemory{store: store}
+}
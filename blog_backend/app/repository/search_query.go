@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"strings"
+	"unicode"
+)
+
+// searchQuery is the parsed form of the user-facing search syntax: bare
+// words and "quoted phrases" must all match, and -word or -"phrase" excludes.
+type searchQuery struct {
+	include []string
+	exclude []string
+}
+
+func parseSearchQuery(raw string) searchQuery {
+	var q searchQuery
+	runes := []rune(raw)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		negated := false
+		if runes[i] == '-' {
+			negated = true
+			i++
+		}
+		var term string
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			term = string(runes[i+1 : end])
+			i = end + 1
+		} else {
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) {
+				i++
+			}
+			term = string(runes[start:i])
+		}
+		term = strings.Join(strings.FieldsFunc(term, isSearchSeparator), " ")
+		if term == "" {
+			continue
+		}
+		if negated {
+			q.exclude = append(q.exclude, term)
+		} else {
+			q.include = append(q.include, term)
+		}
+	}
+	return q
+}
+
+func isSearchSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// fts5 renders the query as an SQLite FTS5 MATCH expression. Every term is
+// quoted so FTS5 operators in user input are treated as plain text.
+func (q searchQuery) fts5() string {
+	if len(q.include) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(q.include)+len(q.exclude))
+	for _, term := range q.include {
+		parts = append(parts, `"`+term+`"`)
+	}
+	expr := "(" + strings.Join(parts, " AND ") + ")"
+	for _, term := range q.exclude {
+		expr += ` NOT "` + term + `"`
+	}
+	return expr
+}
+
+// websearch renders the query in the syntax accepted by PostgreSQL's
+// websearch_to_tsquery.
+func (q searchQuery) websearch() string {
+	parts := make([]string, 0, len(q.include)+len(q.exclude))
+	for _, term := range q.include {
+		parts = append(parts, `"`+term+`"`)
+	}
+	for _, term := range q.exclude {
+		parts = append(parts, `-"`+term+`"`)
+	}
+	return strings.Join(parts, " ")
+}
+
+// boolean renders the query for MySQL's MATCH ... AGAINST in boolean mode.
+// Terms are quoted, so boolean operators in user input stay plain text.
+func (q searchQuery) boolean() string {
+	parts := make([]string, 0, len(q.include)+len(q.exclude))
+	for _, term := range q.include {
+		parts = append(parts, `+"`+term+`"`)
+	}
+	for _, term := range q.exclude {
+		parts = append(parts, `-"`+term+`"`)
+	}
+	return strings.Join(parts, " ")
+}
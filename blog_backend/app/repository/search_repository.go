@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SearchKindPost    = "post"
+	SearchKindComment = "comment"
+
+	// The databases delimit the matches in snippets with these control
+	// characters, replaced by <mark> once the text is escaped
+	snippetStart = "\x02"
+	snippetStop  = "\x03"
+)
+
+var snippetMarks = strings.NewReplacer(snippetStart, "<mark>", snippetStop, "</mark>")
+
+// SearchHit is one ranked match. Higher Rank is more relevant. Snippet is an
+// excerpt of the matched text as HTML: the text is escaped, and the matching
+// terms are wrapped in <mark>.
+type SearchHit struct {
+	Kind      string
+	ID        int
+	PostID    int
+	Title     string
+	Snippet   string
+	Rank      float64
+	CreatedAt time.Time
+}
+
+type SearchRepository interface {
+	Search(ctx context.Context, query string, kinds []string, limit, offset int) ([]*SearchHit, error)
+}
+
+// searchRepositoryGorm searches with tsvector columns on PostgreSQL, FTS5
+// tables on SQLite and FULLTEXT indexes on MySQL, all created by migrations.
+type searchRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func (r *searchRepositoryGorm) Search(ctx context.Context, query string, kinds []string, limit, offset int) ([]*SearchHit, error) {
+	parsed := parseSearchQuery(query)
+	if len(parsed.include) == 0 {
+		return []*SearchHit{}, nil
+	}
+
+	// Each kind is ranked on its own, so fetch enough of both to cover the
+	// requested window before merging.
+	window := limit + offset
+	var hits []*SearchHit
+	for _, kind := range kinds {
+		var (
+			kindHits []*SearchHit
+			err      error
+		)
+		switch r.db.Dialector.Name() {
+		case "postgres":
+			kindHits, err = r.searchPostgres(ctx, kind, parsed, window)
+		case "sqlite":
+			kindHits, err = r.searchSQLite(ctx, kind, parsed, window)
+		case "mysql":
+			kindHits, err = r.searchMySQL(ctx, kind, parsed, window)
+		default:
+			return nil, fmt.Errorf("full-text search is not supported on %s", r.db.Dialector.Name())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %ss: %w", kind, err)
+		}
+		for _, hit := range kindHits {
+			hit.Snippet = highlightSnippet(hit.Snippet)
+		}
+		hits = append(hits, kindHits...)
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Rank > hits[j].Rank })
+	if offset >= len(hits) {
+		return []*SearchHit{}, nil
+	}
+	return hits[offset:min(len(hits), window)], nil
+}
+
+func (r *searchRepositoryGorm) searchPostgres(ctx context.Context, kind string, q searchQuery, limit int) ([]*SearchHit, error) {
+	headline := fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxFragments=2, MaxWords=30, MinWords=10`, snippetStart, snippetStop)
+	var sql string
+	switch kind {
+	case SearchKindPost:
+		sql = `SELECT 'post' AS kind, p.id, p.id AS post_id, p.title,
+			ts_headline('english', p.content, q, @headline) AS snippet,
+			ts_rank(p.search_vector, q) AS rank, p.created_at
+			FROM posts p, websearch_to_tsquery('english', @query) q
+			WHERE p.search_vector @@ q AND p.status = 'published'
+			ORDER BY rank DESC, p.id DESC LIMIT @limit`
+	case SearchKindComment:
+		sql = `SELECT 'comment' AS kind, c.id, c.post_id, p.title,
+			ts_headline('english', c.content, q, @headline) AS snippet,
+			ts_rank(c.search_vector, q) AS rank, c.created_at
+			FROM comments c JOIN posts p ON p.id = c.post_id, websearch_to_tsquery('english', @query) q
+			WHERE c.search_vector @@ q AND c.deleted_at IS NULL AND p.status = 'published'
+			ORDER BY rank DESC, c.id DESC LIMIT @limit`
+	default:
+		return nil, fmt.Errorf("unknown search kind %q", kind)
+	}
+	var hits []*SearchHit
+	err := conn(ctx, r.db).Raw(sql, map[string]any{
+		"query":    q.websearch(),
+		"headline": headline,
+		"limit":    limit,
+	}).Scan(&hits).Error
+	return hits, err
+}
+
+func (r *searchRepositoryGorm) searchSQLite(ctx context.Context, kind string, q searchQuery, limit int) ([]*SearchHit, error) {
+	var sql string
+	switch kind {
+	case SearchKindPost:
+		sql = `SELECT 'post' AS kind, p.id, p.id AS post_id, p.title,
+			snippet(posts_fts, 1, @start, @stop, '…', 16) AS snippet,
+			-bm25(posts_fts, 10.0, 1.0) AS rank, p.created_at
+			FROM posts_fts JOIN posts p ON p.id = posts_fts.rowid
+			WHERE posts_fts MATCH @query AND p.status = 'published'
+			ORDER BY rank DESC, p.id DESC LIMIT @limit`
+	case SearchKindComment:
+		sql = `SELECT 'comment' AS kind, c.id, c.post_id, p.title,
+			snippet(comments_fts, 0, @start, @stop, '…', 16) AS snippet,
+			-bm25(comments_fts) AS rank, c.created_at
+			FROM comments_fts JOIN comments c ON c.id = comments_fts.rowid
+			JOIN posts p ON p.id = c.post_id
+			WHERE comments_fts MATCH @query AND c.deleted_at IS NULL AND p.status = 'published'
+			ORDER BY rank DESC, c.id DESC LIMIT @limit`
+	default:
+		return nil, fmt.Errorf("unknown search kind %q", kind)
+	}
+	var hits []*SearchHit
+	err := conn(ctx, r.db).Raw(sql, map[string]any{
+		"query": q.fts5(),
+		"start": snippetStart,
+		"stop":  snippetStop,
+		"limit": limit,
+	}).Scan(&hits).Error
+	return hits, err
+}
+
+// searchMySQL ranks with MATCH ... AGAINST in boolean mode. MySQL has no
+// snippet function, so the snippet is the start of the text, unhighlighted.
+func (r *searchRepositoryGorm) searchMySQL(ctx context.Context, kind string, q searchQuery, limit int) ([]*SearchHit, error) {
+	var sql string
+	switch kind {
+	case SearchKindPost:
+		sql = `SELECT 'post' AS kind, p.id, p.id AS post_id, p.title,
+			LEFT(p.content, 200) AS snippet,
+			MATCH(p.title) AGAINST (@query IN BOOLEAN MODE) * 10 +
+				MATCH(p.title, p.content) AGAINST (@query IN BOOLEAN MODE) AS ` + "`rank`" + `, p.created_at
+			FROM posts p
+			WHERE MATCH(p.title, p.content) AGAINST (@query IN BOOLEAN MODE) AND p.status = 'published'
+			ORDER BY ` + "`rank`" + ` DESC, p.id DESC LIMIT @limit`
+	case SearchKindComment:
+		sql = `SELECT 'comment' AS kind, c.id, c.post_id, p.title,
+			LEFT(c.content, 200) AS snippet,
+			MATCH(c.content) AGAINST (@query IN BOOLEAN MODE) AS ` + "`rank`" + `, c.created_at
+			FROM comments c JOIN posts p ON p.id = c.post_id
+			WHERE MATCH(c.content) AGAINST (@query IN BOOLEAN MODE) AND c.deleted_at IS NULL AND p.status = 'published'
+			ORDER BY ` + "`rank`" + ` DESC, c.id DESC LIMIT @limit`
+	default:
+		return nil, fmt.Errorf("unknown search kind %q", kind)
+	}
+	var hits []*SearchHit
+	err := conn(ctx, r.db).Raw(sql, map[string]any{
+		"query": q.boolean(),
+		"limit": limit,
+	}).Scan(&hits).Error
+	return hits, err
+}
+
+// highlightSnippet turns a snippet delimited by the database into HTML. The
+// text is authored by users, so it is escaped before the marks go in.
+func highlightSnippet(snippet string) string {
+	return snippetMarks.Replace(html.EscapeString(snippet))
+}
+
+func NewSearchRepository(db *gorm.DB) SearchRepository {
+	return &searchRepositoryGorm{db: db}
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"cmp"
+	"context"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+)
+
+// searchRepositoryMemory matches terms as case-insensitive substrings and
+// ranks by how often they occur. Like the MySQL search, its snippet is the
+// start of the text, unhighlighted.
+type searchRepositoryMemory struct {
+	store *MemoryStore
+}
+
+func (r *searchRepositoryMemory) Search(ctx context.Context, query string, kinds []string, limit, offset int) ([]*SearchHit, error) {
+	parsed := parseSearchQuery(query)
+	hits := []*SearchHit{}
+	if len(parsed.include) == 0 {
+		return hits, nil
+	}
+	for _, kind := range kinds {
+		if kind != SearchKindPost && kind != SearchKindComment {
+			return nil, fmt.Errorf("failed to search %ss: unknown search kind %q", kind, kind)
+		}
+	}
+	r.store.run(ctx, func(t *memoryTables) error {
+		for _, post := range t.posts {
+			if post.Status != models.PostStatusPublished {
+				continue
+			}
+			if slices.Contains(kinds, SearchKindPost) {
+				if rank := parsed.rank(post.Title)*10 + parsed.rank(post.Title+" "+post.Content); rank > 0 {
+					hits = append(hits, &SearchHit{Kind: SearchKindPost, ID: post.ID, PostID: post.ID, Title: post.Title,
+						Snippet: snippetOf(post.Content), Rank: rank, CreatedAt: post.CreatedAt})
+				}
+			}
+		}
+		if !slices.Contains(kinds, SearchKindComment) {
+			return nil
+		}
+		for _, comment := range t.comments {
+			post, ok := t.posts[comment.PostID]
+			if comment.IsDeleted() || !ok || post.Status != models.PostStatusPublished {
+				continue
+			}
+			if rank := parsed.rank(comment.Content); rank > 0 {
+				hits = append(hits, &SearchHit{Kind: SearchKindComment, ID: comment.ID, PostID: post.ID, Title: post.Title,
+					Snippet: snippetOf(comment.Content), Rank: rank, CreatedAt: comment.CreatedAt})
+			}
+		}
+		return nil
+	})
+
+	slices.SortFunc(hits, func(a, b *SearchHit) int { return cmp.Or(cmp.Compare(b.Rank, a.Rank), cmp.Compare(b.ID, a.ID)) })
+	if offset >= len(hits) {
+		return []*SearchHit{}, nil
+	}
+	return hits[offset:min(len(hits), limit+offset)], nil
+}
+
+// rank counts the occurrences of the included terms in text, or returns 0
+// when a term is missing or an excluded one is present.
+func (q searchQuery) rank(text string) float64 {
+	text = strings.ToLower(strings.Join(strings.FieldsFunc(text, isSearchSeparator), " "))
+	for _, term := range q.exclude {
+		if strings.Contains(text, strings.ToLower(term)) {
+			return 0
+		}
+	}
+	var rank float64
+	for _, term := range q.include {
+		n := strings.Count(text, strings.ToLower(term))
+		if n == 0 {
+			return 0
+		}
+		rank += float64(n)
+	}
+	return rank
+}
+
+func snippetOf(text string) string {
+	runes := []rune(text)
+	return html.EscapeString(string(runes[:min(len(runes), 200)]))
+}
+
+func NewMemorySearchRepository(store *MemoryStore) SearchRepository {
+	return &searchRepositoryMemory{store: store}
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type SigningKeyRepository interface {
+	CreateSigningKey(ctx context.Context, key *models.SigningKey) (*models.SigningKey, error)
+	ListSigningKeys(ctx context.Context) ([]models.SigningKey, error)
+	DeleteSigningKey(ctx context.Context, kid string) error
+}
+
+type signingKeyRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func (r *signingKeyRepositoryGorm) CreateSigningKey(ctx context.Context, key *models.SigningKey) (*models.SigningKey, error) {
+	if err := conn(ctx, r.db).Create(key).Error; err != nil {
+		return nil, fmt.Errorf("failed to create signing key: %w", dbError(err))
+	}
+	return key, nil
+}
+
+// ListSigningKeys returns every key, in the order they activate.
+func (r *signingKeyRepositoryGorm) ListSigningKeys(ctx context.Context) ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	if err := conn(ctx, r.db).Order("activates_at, id").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", dbError(err))
+	}
+	return keys, nil
+}
+
+func (r *signingKeyRepositoryGorm) DeleteSigningKey(ctx context.Context, kid string) error {
+	if err := conn(ctx, r.db).Where("kid = ?", kid).Delete(&models.SigningKey{}).Error; err != nil {
+		return fmt.Errorf("failed to delete signing key %s: %w", kid, dbError(err))
+	}
+	return nil
+}
+
+func NewSigningKeyRepository(db *gorm.DB) SigningKeyRepository {
+	return &signingKeyRepositoryGorm{db: db}
+}
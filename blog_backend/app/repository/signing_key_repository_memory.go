@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type signingKeyRepositoryMemory struct {
+	store *MemoryStore
+}
+
+func (r *signingKeyRepositoryMemory) CreateSigningKey(ctx context.Context, key *models.SigningKey) (*models.SigningKey, error) {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.signingKeys {
+			if stored.KID == key.KID {
+				return errDuplicateRow
+			}
+		}
+		key.ID = t.nextID("signing_keys")
+		if key.CreatedAt.IsZero() {
+			key.CreatedAt = time.Now()
+		}
+		t.signingKeys[key.ID] = *key
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signing key: %w", err)
+	}
+	return key, nil
+}
+
+func (r *signingKeyRepositoryMemory) ListSigningKeys(ctx context.Context) ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.signingKeys {
+			keys = append(keys, stored)
+		}
+		return nil
+	})
+	slices.SortFunc(keys, func(a, b models.SigningKey) int {
+		return cmp.Or(a.ActivatesAt.Compare(b.ActivatesAt), cmp.Compare(a.ID, b.ID))
+	})
+	return keys, nil
+}
+
+func (r *signingKeyRepositoryMemory) DeleteSigningKey(ctx context.Context, kid string) error {
+	r.store.run(ctx, func(t *memoryTables) error {
+		for id, stored := range t.signingKeys {
+			if stored.KID == kid {
+				delete(t.signingKeys, id)
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+func NewMemorySigningKeyRepository(store *MemoryStore) SigningKeyRepository {
+	return &signingKeyRepositoryMemory{store: store}
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SIWENonceRepository interface {
+	CreateSIWENonce(ctx context.Context, nonce *models.SIWENonce) (*models.SIWENonce, error)
+	UseSIWENonce(ctx context.Context, nonceHash string) (bool, error)
+	DeleteExpiredSIWENonces(ctx context.Context, before time.Time) error
+}
+
+type siweNonceRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func (r *siweNonceRepositoryGorm) CreateSIWENonce(ctx context.Context, nonce *models.SIWENonce) (*models.SIWENonce, error) {
+	if err := conn(ctx, r.db).Create(nonce).Error; err != nil {
+		return nil, fmt.Errorf("failed to create siwe nonce: %w", dbError(err))
+	}
+	return nonce, nil
+}
+
+// UseSIWENonce marks an unexpired nonce as used. It reports false when there
+// is no such nonce, or when it was already used, so a signed message racing
+// with itself is only accepted once.
+func (r *siweNonceRepositoryGorm) UseSIWENonce(ctx context.Context, nonceHash string) (bool, error) {
+	now := time.Now()
+	result := conn(ctx, r.db).Model(&models.SIWENonce{}).
+		Where("nonce_hash = ? AND used_at IS NULL AND expires_at > ?", nonceHash, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to use siwe nonce: %w", dbError(result.Error))
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteExpiredSIWENonces drops the nonces that expired before a time,
+// used or not, since they can no longer be accepted.
+func (r *siweNonceRepositoryGorm) DeleteExpiredSIWENonces(ctx context.Context, before time.Time) error {
+	if err := conn(ctx, r.db).Where("expires_at < ?", before).Delete(&models.SIWENonce{}).Error; err != nil {
+		return fmt.Errorf("failed to delete expired siwe nonces: %w", dbError(err))
+	}
+	return nil
+}
+
+func NewSIWENonceRepository(db *gorm.DB) SIWENonceRepository {
+	return &siweNonceRepositoryGorm{db: db}
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+type siweNonceRepositoryMemory struct {
+	store *MemoryStore
+}
+
+func (r *siweNonceRepositoryMemory) CreateSIWENonce(ctx context.Context, nonce *models.SIWENonce) (*models.SIWENonce, error) {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.siweNonces {
+			if stored.NonceHash == nonce.NonceHash {
+				return errDuplicateRow
+			}
+		}
+		nonce.ID = t.nextID("siwe_nonces")
+		if nonce.CreatedAt.IsZero() {
+			nonce.CreatedAt = time.Now()
+		}
+		t.siweNonces[nonce.ID] = *nonce
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create siwe nonce: %w", err)
+	}
+	return nonce, nil
+}
+
+func (r *siweNonceRepositoryMemory) UseSIWENonce(ctx context.Context, nonceHash string) (bool, error) {
+	used := false
+	r.store.run(ctx, func(t *memoryTables) error {
+		now := time.Now()
+		for id, stored := range t.siweNonces {
+			if stored.NonceHash == nonceHash && stored.UsedAt == nil && stored.ExpiresAt.After(now) {
+				stored.UsedAt = &now
+				t.siweNonces[id] = stored
+				used = true
+				return nil
+			}
+		}
+		return nil
+	})
+	return used, nil
+}
+
+func (r *siweNonceRepositoryMemory) DeleteExpiredSIWENonces(ctx context.Context, before time.Time) error {
+	r.store.run(ctx, func(t *memoryTables) error {
+		for id, stored := range t.siweNonces {
+			if stored.ExpiresAt.Before(before) {
+				delete(t.siweNonces, id)
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+func NewMemorySIWENonceRepository(store *MemoryStore) SIWENonceRepository {
+	return &siweNonceRepositoryMemory{store: store}
+}
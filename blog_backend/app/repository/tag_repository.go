@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"blog_backend/app/utils"
+	"context"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// TagUsage is a tag together with the number of published posts carrying it.
+type TagUsage struct {
+	ID        int
+	Name      string
+	Slug      string
+	PostCount int64
+}
+
+type TagRepository interface {
+	FindOrCreateTags(ctx context.Context, names []string) ([]models.Tag, error)
+	RetrieveTagBySlug(ctx context.Context, slug string) (*models.Tag, error)
+	ListTagUsage(ctx context.Context, prefix string, limit int) ([]*TagUsage, error)
+}
+
+type tagRepositoryGorm struct {
+	db *gorm.DB
+}
+
+// FindOrCreateTags resolves tag names to stored tags, creating the missing
+// ones. Names that slugify to the same slug collapse into one tag.
+func (r *tagRepositoryGorm) FindOrCreateTags(ctx context.Context, names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	db := conn(ctx, r.db)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		slug := utils.Slugify(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		tag := models.Tag{}
+		err := db.Where(models.Tag{Slug: slug}).Attrs(models.Tag{Name: name}).FirstOrCreate(&tag).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to find or create tag %q: %w", name, dbError(err))
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (r *tagRepositoryGorm) RetrieveTagBySlug(ctx context.Context, slug string) (*models.Tag, error) {
+	tag := &models.Tag{}
+	if err := conn(ctx, r.db).Where("slug = ?", slug).First(tag).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve tag %s: %w", slug, dbError(err))
+	}
+	return tag, nil
+}
+
+// ListTagUsage lists tags by descending count of published posts. Tags only
+// unpublished posts carry are left out. A non-empty prefix restricts the
+// result to tags whose slug starts with it.
+func (r *tagRepositoryGorm) ListTagUsage(ctx context.Context, prefix string, limit int) ([]*TagUsage, error) {
+	query := conn(ctx, r.db).Table("tags").
+		Select("tags.id, tags.name, tags.slug, COUNT(post_tags.post_id) AS post_count").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("JOIN posts ON posts.id = post_tags.post_id AND posts.status = ?", models.PostStatusPublished).
+		Group("tags.id, tags.name, tags.slug").
+		Order("post_count DESC, tags.name ASC").
+		Limit(limit)
+	if prefix != "" {
+		// Slugs only hold letters, digits and dashes, so no LIKE escaping is needed
+		query = query.Where("tags.slug LIKE ?", utils.Slugify(prefix)+"%")
+	}
+	var usage []*TagUsage
+	if err := query.Scan(&usage).Error; err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", dbError(err))
+	}
+	return usage, nil
+}
+
+func NewTagRepository(db *gorm.DB) TagRepository {
+	return &tagRepositoryGorm{db: db}
+}
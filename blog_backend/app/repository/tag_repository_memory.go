@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"blog_backend/app/utils"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type tagRepositoryMemory struct {
+	store *MemoryStore
+}
+
+func (r *tagRepositoryMemory) FindOrCreateTags(ctx context.Context, names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	r.store.run(ctx, func(t *memoryTables) error {
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			slug := utils.Slugify(name)
+			if slug == "" || seen[slug] {
+				continue
+			}
+			seen[slug] = true
+			tag, ok := t.tagBySlug(slug)
+			if !ok {
+				tag = models.Tag{ID: t.nextID("tags"), Name: name, Slug: slug, CreatedAt: time.Now()}
+				t.tags[tag.ID] = tag
+			}
+			tags = append(tags, tag)
+		}
+		return nil
+	})
+	return tags, nil
+}
+
+func (r *tagRepositoryMemory) RetrieveTagBySlug(ctx context.Context, slug string) (*models.Tag, error) {
+	tag := &models.Tag{}
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.tagBySlug(slug)
+		if !ok {
+			return errNoRow
+		}
+		*tag = stored
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve tag %s: %w", slug, err)
+	}
+	return tag, nil
+}
+
+func (r *tagRepositoryMemory) ListTagUsage(ctx context.Context, prefix string, limit int) ([]*TagUsage, error) {
+	usage := []*TagUsage{}
+	prefix = utils.Slugify(prefix)
+	r.store.run(ctx, func(t *memoryTables) error {
+		counts := map[int]int64{}
+		for postID, ids := range t.postTags {
+			if t.posts[postID].Status != models.PostStatusPublished {
+				continue
+			}
+			for _, id := range ids {
+				counts[id]++
+			}
+		}
+		for _, tag := range t.tags {
+			if counts[tag.ID] > 0 && strings.HasPrefix(tag.Slug, prefix) {
+				usage = append(usage, &TagUsage{ID: tag.ID, Name: tag.Name, Slug: tag.Slug, PostCount: counts[tag.ID]})
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(usage, func(a, b *TagUsage) int {
+		return cmp.Or(cmp.Compare(b.PostCount, a.PostCount), strings.Compare(a.Name, b.Name))
+	})
+	return usage[:min(len(usage), limit)], nil
+}
+
+func (t *memoryTables) tagBySlug(slug string) (models.Tag, bool) {
+	for _, tag := range t.tags {
+		if tag.Slug == slug {
+			return tag, true
+		}
+	}
+	return models.Tag{}, false
+}
+
+func NewMemoryTagRepository(store *MemoryStore) TagRepository {
+	return &tagRepositoryMemory{store: store}
+}
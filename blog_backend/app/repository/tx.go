@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TxManager groups repository calls into a unit of work. The transaction
+// travels in the context, so repositories called with the context passed to
+// fn take part in it without knowing.
+type TxManager interface {
+	// WithTx runs fn in a transaction that is committed when fn returns nil
+	// and rolled back otherwise. Called inside another WithTx, it joins the
+	// outer transaction.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+type txManagerGorm struct {
+	db *gorm.DB
+}
+
+func (m *txManagerGorm) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+func NewTxManager(db *gorm.DB) TxManager {
+	return &txManagerGorm{db: db}
+}
+
+// conn returns the transaction of ctx if there is one and db otherwise, bound
+// to ctx so that cancellation and deadlines reach the database.
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// lockForUpdate locks the rows a query reads until the transaction of ctx
+// ends, so that a service can check a row and then write it without another
+// request changing it in between. Outside a transaction it does nothing, and
+// SQLite, which only has one writer at a time anyway, ignores it.
+func lockForUpdate(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if _, ok := ctx.Value(txKey{}).(*gorm.DB); !ok {
+			return db
+		}
+		return db.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate})
+	}
+}
@@ -2,33 +2,139 @@ package repository
 
 import (
 	"blog_backend/app/models"
+	"context"
+	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 type UserRepository interface {
-	CreateUser(user *models.User) (*models.User, error)
-	RetriveUser(user *models.User) (*models.User, error)
+	CreateUser(ctx context.Context, user *models.User) (*models.User, error)
+	RetriveUser(ctx context.Context, user *models.User) (*models.User, error)
+	RetrieveUserByEmail(ctx context.Context, email string) (*models.User, error)
+	RetrieveUserByWallet(ctx context.Context, address string) (*models.User, error)
+	UpdateUserRole(ctx context.Context, id int, role models.Role) (*models.User, error)
+	MarkEmailVerified(ctx context.Context, id int) error
+	UpdatePassword(ctx context.Context, id int, passwordHash string) error
+	UpdateTOTP(ctx context.Context, id int, secret string, enabledAt *time.Time) error
+	UseTOTPStep(ctx context.Context, id int, step int64) (bool, error)
+	LinkWallet(ctx context.Context, id int, address string) error
 }
 
 type userRepositoryGorm struct {
 	db *gorm.DB
 }
 
-func (r *userRepositoryGorm) CreateUser(user *models.User) (*models.User, error) {
-	if err := r.db.Create(user).Error; err != nil {
-		return nil, err
+func (r *userRepositoryGorm) CreateUser(ctx context.Context, user *models.User) (*models.User, error) {
+	if err := conn(ctx, r.db).Create(user).Error; err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", dbError(err))
 	}
 	return user, nil
 }
 
-func (r *userRepositoryGorm) RetriveUser(user *models.User) (*models.User, error) {
-	if err := r.db.First(user).Error; err != nil {
-		return nil, err
+func (r *userRepositoryGorm) RetriveUser(ctx context.Context, user *models.User) (*models.User, error) {
+	if err := conn(ctx, r.db).First(user).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve user with id %d: %w", user.ID, dbError(err))
 	}
 	return user, nil
 }
 
+func (r *userRepositoryGorm) RetrieveUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	user := &models.User{}
+	if err := conn(ctx, r.db).Where("email = ?", email).First(user).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve user by email: %w", dbError(err))
+	}
+	return user, nil
+}
+
+func (r *userRepositoryGorm) RetrieveUserByWallet(ctx context.Context, address string) (*models.User, error) {
+	user := &models.User{}
+	if err := conn(ctx, r.db).Where("wallet_address = ?", address).First(user).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve user by wallet: %w", dbError(err))
+	}
+	return user, nil
+}
+
+func (r *userRepositoryGorm) UpdateUserRole(ctx context.Context, id int, role models.Role) (*models.User, error) {
+	user := &models.User{ID: id}
+	db := conn(ctx, r.db)
+	if err := db.Scopes(lockForUpdate(ctx)).First(user).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve user with id %d: %w", id, dbError(err))
+	}
+	if err := db.Model(user).Update("role", role).Error; err != nil {
+		return nil, fmt.Errorf("failed to update role of user with id %d: %w", id, dbError(err))
+	}
+	return user, nil
+}
+
+// MarkEmailVerified records that the user verified their email address,
+// keeping the time of the first verification.
+func (r *userRepositoryGorm) MarkEmailVerified(ctx context.Context, id int) error {
+	err := conn(ctx, r.db).Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Update("email_verified_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark email of user with id %d verified: %w", id, dbError(err))
+	}
+	return nil
+}
+
+func (r *userRepositoryGorm) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	result := conn(ctx, r.db).Model(&models.User{ID: id}).Update("password", passwordHash)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update password of user with id %d: %w", id, dbError(result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to update password of user with id %d: %w", id, errNoRow)
+	}
+	return nil
+}
+
+// UpdateTOTP sets the TOTP secret of a user and when it was enabled, and
+// forgets the codes used with the previous one. An empty secret turns
+// two-factor authentication off.
+func (r *userRepositoryGorm) UpdateTOTP(ctx context.Context, id int, secret string, enabledAt *time.Time) error {
+	result := conn(ctx, r.db).Model(&models.User{ID: id}).Updates(map[string]any{
+		"totp_secret":     secret,
+		"totp_enabled_at": enabledAt,
+		"totp_last_step":  0,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update totp of user with id %d: %w", id, dbError(result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to update totp of user with id %d: %w", id, errNoRow)
+	}
+	return nil
+}
+
+// UseTOTPStep records that the code of a time step was used. It reports
+// false when a code of that step or a later one was already used, so a
+// code racing with itself is only accepted once.
+func (r *userRepositoryGorm) UseTOTPStep(ctx context.Context, id int, step int64) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to use totp step of user with id %d: %w", id, dbError(result.Error))
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// LinkWallet sets the wallet address of a user, replacing the one linked
+// before. It fails with a conflict when another user has the address.
+func (r *userRepositoryGorm) LinkWallet(ctx context.Context, id int, address string) error {
+	result := conn(ctx, r.db).Model(&models.User{ID: id}).Update("wallet_address", address)
+	if result.Error != nil {
+		return fmt.Errorf("failed to link wallet to user with id %d: %w", id, dbError(result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to link wallet to user with id %d: %w", id, errNoRow)
+	}
+	return nil
+}
+
 func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepositoryGorm{db: db}
 }
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+type userRepositoryMemory struct {
+	store *MemoryStore
+}
+
+func (r *userRepositoryMemory) CreateUser(ctx context.Context, user *models.User) (*models.User, error) {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		for _, u := range t.users {
+			if u.Email == user.Email || sameWallet(u.WalletAddress, user.WalletAddress) {
+				return errDuplicateRow
+			}
+		}
+		now := time.Now()
+		user.ID = t.nextID("users")
+		if user.Role == "" {
+			user.Role = models.RoleUser
+		}
+		if user.CreatedAt.IsZero() {
+			user.CreatedAt = now
+		}
+		user.UpdatedAt = now
+		t.users[user.ID] = storedUser(*user)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	return user, nil
+}
+
+func (r *userRepositoryMemory) RetriveUser(ctx context.Context, user *models.User) (*models.User, error) {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.users[user.ID]
+		if !ok {
+			return errNoRow
+		}
+		*user = stored
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve user with id %d: %w", user.ID, err)
+	}
+	return user, nil
+}
+
+func (r *userRepositoryMemory) RetrieveUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	user := &models.User{}
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		for _, u := range t.users {
+			if u.Email == email {
+				*user = u
+				return nil
+			}
+		}
+		return errNoRow
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve user by email: %w", err)
+	}
+	return user, nil
+}
+
+func (r *userRepositoryMemory) RetrieveUserByWallet(ctx context.Context, address string) (*models.User, error) {
+	user := &models.User{}
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		for _, u := range t.users {
+			if sameWallet(u.WalletAddress, &address) {
+				*user = u
+				return nil
+			}
+		}
+		return errNoRow
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve user by wallet: %w", err)
+	}
+	return user, nil
+}
+
+func (r *userRepositoryMemory) UpdateUserRole(ctx context.Context, id int, role models.Role) (*models.User, error) {
+	user := &models.User{}
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.users[id]
+		if !ok {
+			return errNoRow
+		}
+		stored.Role = role
+		stored.UpdatedAt = time.Now()
+		t.users[id] = stored
+		*user = stored
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update role of user with id %d: %w", id, err)
+	}
+	return user, nil
+}
+
+func (r *userRepositoryMemory) MarkEmailVerified(ctx context.Context, id int) error {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.users[id]
+		if !ok {
+			return errNoRow
+		}
+		if stored.EmailVerifiedAt == nil {
+			now := time.Now()
+			stored.EmailVerifiedAt = &now
+			stored.UpdatedAt = now
+			t.users[id] = stored
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark email of user with id %d verified: %w", id, err)
+	}
+	return nil
+}
+
+func (r *userRepositoryMemory) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.users[id]
+		if !ok {
+			return errNoRow
+		}
+		stored.Password = passwordHash
+		stored.UpdatedAt = time.Now()
+		t.users[id] = stored
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update password of user with id %d: %w", id, err)
+	}
+	return nil
+}
+
+func (r *userRepositoryMemory) UpdateTOTP(ctx context.Context, id int, secret string, enabledAt *time.Time) error {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.users[id]
+		if !ok {
+			return errNoRow
+		}
+		stored.TOTPSecret = secret
+		stored.TOTPEnabledAt = enabledAt
+		stored.TOTPLastStep = 0
+		stored.UpdatedAt = time.Now()
+		t.users[id] = stored
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update totp of user with id %d: %w", id, err)
+	}
+	return nil
+}
+
+func (r *userRepositoryMemory) UseTOTPStep(ctx context.Context, id int, step int64) (bool, error) {
+	used := false
+	r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.users[id]
+		if !ok || stored.TOTPLastStep >= step {
+			return nil
+		}
+		stored.TOTPLastStep = step
+		t.users[id] = stored
+		used = true
+		return nil
+	})
+	return used, nil
+}
+
+func (r *userRepositoryMemory) LinkWallet(ctx context.Context, id int, address string) error {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.users[id]
+		if !ok {
+			return errNoRow
+		}
+		for _, u := range t.users {
+			if u.ID != id && sameWallet(u.WalletAddress, &address) {
+				return errDuplicateRow
+			}
+		}
+		stored.WalletAddress = &address
+		stored.UpdatedAt = time.Now()
+		t.users[id] = stored
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to link wallet to user with id %d: %w", id, err)
+	}
+	return nil
+}
+
+// sameWallet compares nullable wallet addresses like a unique index does:
+// users without one never collide.
+func sameWallet(a, b *string) bool {
+	return a != nil && b != nil && *a == *b
+}
+
+// storedUser drops the associations, which live in their own tables.
+func storedUser(u models.User) models.User {
+	u.Posts = nil
+	u.Comments = nil
+	return u
+}
+
+func NewMemoryUserRepository(store *MemoryStore) UserRepository {
+	return &userRepositoryMemory{store: store}
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UserTokenRepository interface {
+	CreateUserToken(ctx context.Context, token *models.UserToken) (*models.UserToken, error)
+	RetrieveUserTokenByHash(ctx context.Context, tokenHash string) (*models.UserToken, error)
+	UseUserToken(ctx context.Context, id int) (bool, error)
+	InvalidateUserTokens(ctx context.Context, userID int, purpose models.UserTokenPurpose) error
+}
+
+type userTokenRepositoryGorm struct {
+	db *gorm.DB
+}
+
+func (r *userTokenRepositoryGorm) CreateUserToken(ctx context.Context, token *models.UserToken) (*models.UserToken, error) {
+	if err := conn(ctx, r.db).Create(token).Error; err != nil {
+		return nil, fmt.Errorf("failed to create user token: %w", dbError(err))
+	}
+	return token, nil
+}
+
+func (r *userTokenRepositoryGorm) RetrieveUserTokenByHash(ctx context.Context, tokenHash string) (*models.UserToken, error) {
+	token := &models.UserToken{}
+	if err := conn(ctx, r.db).Scopes(lockForUpdate(ctx)).Where("token_hash = ?", tokenHash).First(token).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve user token: %w", dbError(err))
+	}
+	return token, nil
+}
+
+// UseUserToken marks a token as used. It reports false when the token had
+// already been used, so a token racing with itself is only accepted once.
+func (r *userTokenRepositoryGorm) UseUserToken(ctx context.Context, id int) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to use user token with id %d: %w", id, dbError(result.Error))
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// InvalidateUserTokens retires the unused tokens a user was sent for
+// purpose, leaving only the one about to be sent usable.
+func (r *userTokenRepositoryGorm) InvalidateUserTokens(ctx context.Context, userID int, purpose models.UserTokenPurpose) error {
+	err := conn(ctx, r.db).Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to invalidate %s tokens of user with id %d: %w", purpose, userID, dbError(err))
+	}
+	return nil
+}
+
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepositoryGorm{db: db}
+}
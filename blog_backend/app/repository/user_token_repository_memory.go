@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"blog_backend/app/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+type userTokenRepositoryMemory struct {
+	store *MemoryStore
+}
+
+func (r *userTokenRepositoryMemory) CreateUserToken(ctx context.Context, token *models.UserToken) (*models.UserToken, error) {
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		if _, ok := t.users[token.UserID]; !ok {
+			return errNoRow
+		}
+		for _, stored := range t.userTokens {
+			if stored.TokenHash == token.TokenHash {
+				return errDuplicateRow
+			}
+		}
+		token.ID = t.nextID("user_tokens")
+		if token.CreatedAt.IsZero() {
+			token.CreatedAt = time.Now()
+		}
+		stored := *token
+		stored.User = models.User{}
+		t.userTokens[token.ID] = stored
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *userTokenRepositoryMemory) RetrieveUserTokenByHash(ctx context.Context, tokenHash string) (*models.UserToken, error) {
+	token := &models.UserToken{}
+	err := r.store.run(ctx, func(t *memoryTables) error {
+		for _, stored := range t.userTokens {
+			if stored.TokenHash == tokenHash {
+				*token = stored
+				return nil
+			}
+		}
+		return errNoRow
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve user token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *userTokenRepositoryMemory) UseUserToken(ctx context.Context, id int) (bool, error) {
+	used := false
+	r.store.run(ctx, func(t *memoryTables) error {
+		stored, ok := t.userTokens[id]
+		if !ok || stored.UsedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		stored.UsedAt = &now
+		t.userTokens[id] = stored
+		used = true
+		return nil
+	})
+	return used, nil
+}
+
+func (r *userTokenRepositoryMemory) InvalidateUserTokens(ctx context.Context, userID int, purpose models.UserTokenPurpose) error {
+	r.store.run(ctx, func(t *memoryTables) error {
+		now := time.Now()
+		for id, stored := range t.userTokens {
+			if stored.UserID == userID && stored.Purpose == purpose && stored.UsedAt == nil {
+				stored.UsedAt = &now
+				t.userTokens[id] = stored
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+func NewMemoryUserTokenRepository(store *MemoryStore) UserTokenRepository {
+	return &userTokenRepositoryMemory{store: store}
+}
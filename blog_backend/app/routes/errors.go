@@ -0,0 +1,171 @@
+package routes
+
+import (
+	"blog_backend/app/apperror"
+	"blog_backend/app/dto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// errorKinds maps each apperror kind to its status code and envelope code.
+var errorKinds = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{apperror.ErrValidation, http.StatusBadRequest, "validation_failed"},
+	{apperror.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{apperror.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{apperror.ErrNotFound, http.StatusNotFound, "not_found"},
+	{apperror.ErrConflict, http.StatusConflict, "conflict"},
+	{apperror.ErrLocked, http.StatusLocked, "locked"},
+	{apperror.ErrTooManyRequests, http.StatusTooManyRequests, "too_many_requests"},
+}
+
+func init() {
+	// Name fields in validation errors the way the client sent them
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(requestFieldName)
+	}
+}
+
+// ErrorHandler answers with the error envelope when a handler or middleware
+// reports a failure with ctx.Error instead of writing a response. Errors of
+// type gin.ErrorTypeBind come from request binding; anything that is not an
+// apperror is a server failure, which is logged and whose message is not
+// shown to the client.
+func ErrorHandler(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		status, resp := errorResponse(c.Errors.Last())
+		if status == http.StatusInternalServerError {
+			logger.ErrorContext(c.Request.Context(), "request failed", "error", c.Errors.Last().Err)
+		}
+		resp.RequestID = c.GetString("requestId")
+		c.JSON(status, resp)
+	}
+}
+
+// notFoundHandler answers requests to unknown routes with the error envelope.
+func notFoundHandler(c *gin.Context) {
+	c.Error(apperror.NotFound("route not found"))
+}
+
+func errorResponse(ginErr *gin.Error) (int, dto.ErrorResponse) {
+	if ginErr.IsType(gin.ErrorTypeBind) {
+		return http.StatusBadRequest, bindingErrorResponse(ginErr.Err)
+	}
+
+	var appErr *apperror.Error
+	if errors.As(ginErr.Err, &appErr) {
+		for _, k := range errorKinds {
+			if appErr.Kind == k.kind {
+				resp := dto.ErrorResponse{Code: k.code, Message: appErr.Message, Details: []dto.ErrorDetail{}}
+				for _, d := range appErr.Details {
+					resp.Details = append(resp.Details, dto.ErrorDetail{Field: d.Field, Rule: d.Rule, Message: d.Message})
+				}
+				return k.status, resp
+			}
+		}
+	}
+	for _, k := range errorKinds {
+		if errors.Is(ginErr.Err, k.kind) {
+			return k.status, dto.ErrorResponse{Code: k.code, Message: k.kind.Error(), Details: []dto.ErrorDetail{}}
+		}
+	}
+	return http.StatusInternalServerError, dto.ErrorResponse{
+		Code:    "internal_error",
+		Message: "internal server error",
+		Details: []dto.ErrorDetail{},
+	}
+}
+
+func bindingErrorResponse(err error) dto.ErrorResponse {
+	resp := dto.ErrorResponse{
+		Code:    "validation_failed",
+		Message: "invalid request",
+		Details: []dto.ErrorDetail{},
+	}
+	var (
+		fieldErrors validator.ValidationErrors
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &fieldErrors):
+		resp.Message = "request validation failed"
+		for _, fe := range fieldErrors {
+			resp.Details = append(resp.Details, dto.ErrorDetail{
+				Field:   fe.Field(),
+				Rule:    fe.Tag(),
+				Message: describeFieldError(fe),
+			})
+		}
+	case errors.As(err, &typeErr):
+		resp.Message = "request validation failed"
+		resp.Details = append(resp.Details, dto.ErrorDetail{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: "must be of type " + typeErr.Type.String(),
+		})
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		resp.Message = "malformed JSON body"
+	case errors.Is(err, io.EOF):
+		resp.Message = "request body is empty"
+	default:
+		resp.Message = "invalid request: " + err.Error()
+	}
+	return resp
+}
+
+func describeFieldError(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_if":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "min", "max":
+		bound := "at least"
+		if fe.Tag() == "max" {
+			bound = "at most"
+		}
+		switch fe.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("must be %s %s characters long", bound, fe.Param())
+		case reflect.Slice, reflect.Map, reflect.Array:
+			return fmt.Sprintf("must have %s %s items", bound, fe.Param())
+		}
+		return fmt.Sprintf("must be %s %s", bound, fe.Param())
+	}
+	return "fails the " + fe.Tag() + " rule"
+}
+
+// requestFieldName is the name a struct field is bound from: its json, form
+// or uri key.
+func requestFieldName(sf reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return sf.Name
+}
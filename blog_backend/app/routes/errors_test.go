@@ -0,0 +1,105 @@
+package routes
+
+import (
+	"blog_backend/app/models"
+	"blog_backend/app/services"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sentinels are the errors services and models report to handlers.
+var sentinels = map[string]error{
+	"models.ErrRevisionImmutable":       models.ErrRevisionImmutable,
+	"services.ErrAccessTokenNotFound":   services.ErrAccessTokenNotFound,
+	"services.ErrAccountLocked":         services.ErrAccountLocked,
+	"services.ErrCategoryExists":        services.ErrCategoryExists,
+	"services.ErrCategoryNotFound":      services.ErrCategoryNotFound,
+	"services.ErrCommentDeleted":        services.ErrCommentDeleted,
+	"services.ErrCommentNotFound":       services.ErrCommentNotFound,
+	"services.ErrCommentTooDeep":        services.ErrCommentTooDeep,
+	"services.ErrEmailAlreadyVerified":  services.ErrEmailAlreadyVerified,
+	"services.ErrEmailNotVerified":      services.ErrEmailNotVerified,
+	"services.ErrEmailTaken":            services.ErrEmailTaken,
+	"services.ErrInvalidAccessToken":    services.ErrInvalidAccessToken,
+	"services.ErrInvalidCategory":       services.ErrInvalidCategory,
+	"services.ErrInvalidCredentials":    services.ErrInvalidCredentials,
+	"services.ErrInvalidMFACode":        services.ErrInvalidMFACode,
+	"services.ErrInvalidMFAToken":       services.ErrInvalidMFAToken,
+	"services.ErrInvalidParent":         services.ErrInvalidParent,
+	"services.ErrInvalidParentCategory": services.ErrInvalidParentCategory,
+	"services.ErrInvalidRefreshToken":   services.ErrInvalidRefreshToken,
+	"services.ErrInvalidSchedule":       services.ErrInvalidSchedule,
+	"services.ErrInvalidToken":          services.ErrInvalidToken,
+	"services.ErrInvalidUserToken":      services.ErrInvalidUserToken,
+	"services.ErrMFAAlreadyEnabled":     services.ErrMFAAlreadyEnabled,
+	"services.ErrMFANotEnabled":         services.ErrMFANotEnabled,
+	"services.ErrMFANotEnrolled":        services.ErrMFANotEnrolled,
+	"services.ErrPermissionDenied":      services.ErrPermissionDenied,
+	"services.ErrPostNotFound":          services.ErrPostNotFound,
+	"services.ErrRefreshTokenReused":    services.ErrRefreshTokenReused,
+	"services.ErrRevisionNotFound":      services.ErrRevisionNotFound,
+	"services.ErrSIWERejected":          services.ErrSIWERejected,
+	"services.ErrSessionRevoked":        services.ErrSessionRevoked,
+	"services.ErrTagNotFound":           services.ErrTagNotFound,
+	"services.ErrTooManyAttempts":       services.ErrTooManyAttempts,
+	"services.ErrUserNotFound":          services.ErrUserNotFound,
+	"services.ErrWalletTaken":           services.ErrWalletTaken,
+}
+
+// declaredSentinels lists the exported Err variables declared in a package.
+func declaredSentinels(t *testing.T, dir string) []string {
+	t.Helper()
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("parse %s: %v", dir, err)
+	}
+	var names []string
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					for _, name := range spec.(*ast.ValueSpec).Names {
+						if name.IsExported() && strings.HasPrefix(name.Name, "Err") {
+							names = append(names, pkg.Name+"."+name.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+func TestSentinelsAreTyped(t *testing.T) {
+	declared := append(declaredSentinels(t, "../services"), declaredSentinels(t, "../models")...)
+	for _, name := range declared {
+		if _, ok := sentinels[name]; !ok {
+			t.Errorf("%s is not listed in sentinels", name)
+		}
+	}
+	for name, err := range sentinels {
+		if !slices.Contains(declared, name) {
+			t.Errorf("%s is listed in sentinels but not declared", name)
+		}
+		// Services wrap sentinels with context before returning them
+		wrapped := fmt.Errorf("post 1: %w", err)
+		if status, _ := errorResponse(&gin.Error{Err: wrapped, Type: gin.ErrorTypePrivate}); status == http.StatusInternalServerError {
+			t.Errorf("%s answers 500", name)
+		}
+	}
+}
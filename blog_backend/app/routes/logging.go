@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"blog_backend/app/logging"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// probePaths are polled by the orchestrator every few seconds, so their
+// access logs only show up at debug level.
+var probePaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+var errPanic = errors.New("handler panicked")
+
+// AccessLog logs every request once it has been answered, with its route,
+// status, latency and the user who made it. It must run after RequestID so
+// the records carry the request ID.
+func AccessLog(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case probePaths[c.Request.URL.Path]:
+			level = slog.LevelDebug
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		}
+		if query := logging.RedactQuery(c.Request.URL.RawQuery); query != "" {
+			attrs = append(attrs, slog.String("query", query))
+		}
+		if userID, ok := c.Get("userId"); ok {
+			attrs = append(attrs, slog.Any("user_id", userID))
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Recovery answers a request whose handler panicked with the internal error
+// envelope and logs the panic with its stack.
+func Recovery(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				logger.ErrorContext(c.Request.Context(), "handler panicked",
+					"panic", p, "stack", string(debug.Stack()))
+				if !c.Writer.Written() {
+					_, resp := errorResponse(&gin.Error{Err: errPanic})
+					resp.RequestID = c.GetString("requestId")
+					c.JSON(http.StatusInternalServerError, resp)
+				}
+				c.Abort()
+			}
+		}()
+		c.Next()
+	}
+}
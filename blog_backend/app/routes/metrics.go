@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"blog_backend/app/metrics"
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestMetrics records the latency of every request under its route
+// template. Requests matching no route share the "unmatched" series.
+func RequestMetrics(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.ObserveHTTPRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// requireBearerToken rejects requests that do not present token as their
+// bearer token.
+func requireBearerToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presented, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			c.Error(errUnauthorized)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
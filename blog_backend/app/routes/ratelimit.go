@@ -0,0 +1,73 @@
+package routes
+
+import (
+	"blog_backend/app/apperror"
+	"blog_backend/app/ratelimit"
+	"blog_backend/app/services"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errRateLimited = apperror.TooManyRequests("rate limit exceeded")
+
+// RateLimit takes a token from the caller's bucket for the rule matching the
+// request, and rejects the request once the bucket is empty. Callers are
+// the user of a valid access token, and the client IP otherwise. Responses
+// carry the RateLimit-* headers of the IETF draft, and Retry-After when
+// rejected. Probes and metrics are never limited, and requests are let
+// through when the store fails, so an outage of a shared store does not
+// take the API down.
+func RateLimit(rules *ratelimit.Rules, store ratelimit.Store, authService services.AuthService, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if probePaths[c.Request.URL.Path] || c.Request.URL.Path == "/metrics" {
+			c.Next()
+			return
+		}
+		name, limit, ok := rules.For(c.Request.Method, c.FullPath())
+		if !ok {
+			c.Next()
+			return
+		}
+		result, err := store.Take(c.Request.Context(), name+"|"+rateLimitCaller(c, authService), limit)
+		if err != nil {
+			logger.WarnContext(c.Request.Context(), "rate limit store failed, request let through", "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", strconv.Itoa(limit.Tokens)+";w="+seconds(limit.Period))
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Tokens))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", seconds(result.Reset))
+		if !result.Allowed {
+			c.Header("Retry-After", seconds(result.RetryAfter))
+			c.Error(errRateLimited)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitCaller identifies who a request counts against. Only the token's
+// signature is checked: a revoked session is still limited as its user, and
+// is rejected by authMiddleWare afterwards. Personal access tokens can only
+// be checked in the database, so their requests are limited by IP.
+func rateLimitCaller(c *gin.Context, authService services.AuthService) string {
+	if tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		if claims, err := authService.ParseAccessToken(tokenString); err == nil {
+			return "user:" + strconv.Itoa(claims.UserID)
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// seconds formats d in whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -1,87 +1,247 @@
 package routes
 
 import (
+	"blog_backend/app/apperror"
 	"blog_backend/app/config"
 	"blog_backend/app/controller"
-	"blog_backend/app/utils"
+	"blog_backend/app/metrics"
+	"blog_backend/app/models"
+	"blog_backend/app/policy"
+	"blog_backend/app/ratelimit"
+	"blog_backend/app/services"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func SetupRoutes(cfg *config.Config, router *gin.Engine,
+	authService services.AuthService,
 	authController *controller.AuthController,
 	postController *controller.PostController,
-	commentController *controller.CommentController) {
-	// Define your routes here
-	router.GET("/ping", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"message": "pong",
-		})
-	})
+	commentController *controller.CommentController,
+	userController *controller.UserController,
+	searchController *controller.SearchController,
+	taxonomyController *controller.TaxonomyController,
+	healthController *controller.HealthController,
+	logger *slog.Logger,
+	appMetrics *metrics.Metrics,
+	tracerProvider trace.TracerProvider,
+	rateLimits *ratelimit.Rules,
+	rateLimitStore ratelimit.Store) {
+	// Failures are reported with ctx.Error and answered by ErrorHandler
+	router.Use(Tracing(tracerProvider), RequestID(), AccessLog(logger), RequestMetrics(appMetrics),
+		Recovery(logger), ErrorHandler(logger))
+	if rateLimitStore != nil {
+		router.Use(RateLimit(rateLimits, rateLimitStore, authService, logger))
+	}
+	router.NoRoute(notFoundHandler)
+
+	// Probes for the orchestrator
+	router.GET("/healthz", healthController.Live)
+	router.GET("/readyz", healthController.Ready)
+
+	// Public keys for the services verifying access tokens
+	router.GET("/.well-known/jwks.json", authController.JWKS)
+
+	// Without an admin port the metrics are only served to the scraper
+	// holding the metrics token
+	if cfg.MetricsPort == "" && cfg.MetricsToken != "" {
+		router.GET("/metrics", requireBearerToken(cfg.MetricsToken), gin.WrapH(appMetrics.Handler()))
+	}
 
 	// Protected routes
 	userRouter := router.Group("/user")
 	{
 		userRouter.POST("/register", authController.Register)
 		userRouter.POST("/login", authController.Login)
+		userRouter.POST("/login/mfa", authController.CompleteMFALogin)
+		userRouter.POST("/refresh", authController.Refresh)
+		userRouter.POST("/logout", authController.Logout)
+		userRouter.POST("/verify", authController.VerifyEmail)
+		userRouter.POST("/password/forgot", authController.ForgotPassword)
+		userRouter.POST("/password/reset", authController.ResetPassword)
+		userRouter.GET("/siwe/nonce", authController.SIWENonce)
+		userRouter.POST("/siwe/verify", authController.SIWEVerify)
 		// Profile route is protected
-		userRouter.Use(authMiddleWare(cfg.JWTSecret))
+		userRouter.Use(authMiddleWare(authService), RequireScope(models.ScopeRead, ""))
+		userRouter.POST("/verify/resend", authController.ResendVerification)
+		userRouter.POST("/mfa/enroll", authController.EnrollMFA)
+		userRouter.POST("/mfa/confirm", authController.ConfirmMFA)
+		userRouter.POST("/mfa/disable", authController.DisableMFA)
+		userRouter.POST("/siwe/link", authController.LinkWallet)
+		userRouter.GET("/tokens", authController.ListAccessTokens)
+		userRouter.POST("/tokens", authController.CreateAccessToken)
+		userRouter.DELETE("/tokens/:token_id", authController.RevokeAccessToken)
 		// This middleware will check for a valid JWT token
 		userRouter.GET("/profile", func(c *gin.Context) {
 			userId, exists := c.Get("userId")
 			if !exists {
-				c.JSON(401, gin.H{"error": "Unauthorized"})
+				c.Error(errUnauthorized)
 				return
 			}
 			email, _ := c.Get("email")
 			c.JSON(200, gin.H{
-				"userId": userId,
-				"email":  email,
+				"userId":         userId,
+				"email":          email,
+				"role":           c.GetString("role"),
+				"email_verified": c.GetBool("emailVerified"),
 			})
 		})
+		userRouter.GET("/posts", postController.ListMyPosts)
 	}
 	// Post routes get post is public, create post is protected
 	postRouter := router.Group("/post")
 	{
-		postRouter.GET("/:post_id", postController.RetrievePost)
+		postRouter.GET("", postController.ListPosts)
+		// Anonymous readers see published posts, authors can preview their drafts
+		postRouter.GET("/:post_id", optionalAuthMiddleWare(authService), postController.RetrievePost)
 		// Create post route is protected
-		postRouter.Use(authMiddleWare(cfg.JWTSecret))
-		postRouter.POST("/", postController.CreatePost)
+		postRouter.Use(authMiddleWare(authService), RequireScope(models.ScopeRead, models.ScopePostsWrite))
+		postRouter.POST("/", RequirePermission(policy.PostCreate), postController.CreatePost)
 		postRouter.PUT("/:post_id", postController.UpdatePost)
 		postRouter.DELETE("/:post_id", postController.DeletePost)
+		postRouter.GET("/:post_id/revisions", postController.ListRevisions)
+		postRouter.GET("/:post_id/revisions/:revision", postController.RetrieveRevision)
+		postRouter.POST("/:post_id/revisions/:revision/restore", postController.RestoreRevision)
+		postRouter.GET("/:post_id/diff", postController.DiffRevisions)
 	}
 
 	commentRouter := router.Group("/comment")
 	{
-		commentRouter.GET("/:comment_id", commentController.RetrieveComment)
-		commentRouter.GET("/post/:post_id", commentController.ListComments)
-		commentRouter.Use(authMiddleWare(cfg.JWTSecret))
+		commentRouter.GET("/:comment_id", optionalAuthMiddleWare(authService), commentController.RetrieveComment)
+		commentRouter.GET("/post/:post_id", optionalAuthMiddleWare(authService), commentController.ListComments)
+		commentRouter.Use(authMiddleWare(authService), RequireScope(models.ScopeRead, models.ScopeCommentsWrite))
 		commentRouter.PUT("/:comment_id", commentController.UpdateComment)
-		commentRouter.POST("/", commentController.CreateComment)
+		commentRouter.POST("/", RequirePermission(policy.CommentCreate), commentController.CreateComment)
 		commentRouter.DELETE("/:comment_id", commentController.DeleteComment)
+		commentRouter.GET("/:comment_id/revisions", commentController.ListRevisions)
+		commentRouter.GET("/:comment_id/revisions/:revision", commentController.RetrieveRevision)
+		commentRouter.POST("/:comment_id/revisions/:revision/restore", commentController.RestoreRevision)
+		commentRouter.GET("/:comment_id/diff", commentController.DiffRevisions)
+	}
+
+	router.GET("/search", searchController.Search)
+
+	tagRouter := router.Group("/tag")
+	{
+		tagRouter.GET("", taxonomyController.ListTags)
+		tagRouter.GET("/autocomplete", taxonomyController.AutocompleteTags)
+		tagRouter.GET("/:slug/posts", taxonomyController.ListTagPosts)
+	}
+
+	categoryRouter := router.Group("/category")
+	{
+		categoryRouter.GET("", taxonomyController.ListCategories)
+		categoryRouter.GET("/:slug/posts", taxonomyController.ListCategoryPosts)
+		categoryRouter.Use(authMiddleWare(authService), RequireScope(models.ScopeRead, ""))
+		categoryRouter.POST("/", RequirePermission(policy.CategoryManage), taxonomyController.CreateCategory)
+	}
+
+	adminRouter := router.Group("/admin")
+	{
+		adminRouter.Use(authMiddleWare(authService), RequireScope("", ""))
+		adminRouter.PUT("/user/:user_id/role", RequirePermission(policy.UserManageRoles), userController.UpdateRole)
+		adminRouter.DELETE("/user/:user_id/mfa", RequirePermission(policy.UserResetMFA), userController.ResetMFA)
 	}
 
 }
 
-func authMiddleWare(secretKey string) gin.HandlerFunc {
+var errUnauthorized = apperror.Unauthorized("missing bearer token")
+
+// authMiddleWare rejects requests without a valid login or personal access
+// token, and identifies the user of the others.
+func authMiddleWare(authService services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Implement your authentication logic here
-		// For example, check for a valid token in the request header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
+		tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || tokenString == "" {
+			c.Error(errUnauthorized)
+			c.Abort()
 			return
 		}
 
-		// If token is valid, proceed to the next handler
-		tokenString := authHeader[len("Bearer "):]
-		claims, err := utils.VerifyJWTToken(secretKey, tokenString)
+		principal, err := authService.Authenticate(c.Request.Context(), tokenString)
 		if err != nil {
-			c.AbortWithStatusJSON(401, gin.H{"error": "Invalid token"})
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		setPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// optionalAuthMiddleWare identifies the caller when a valid token is sent and
+// lets the request through anonymously otherwise. Personal access tokens
+// without the read scope count as anonymous.
+func optionalAuthMiddleWare(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok {
+			c.Next()
+			return
+		}
+		principal, err := authService.Authenticate(c.Request.Context(), tokenString)
+		if err == nil && principal.HasScope(models.ScopeRead) {
+			setPrincipal(c, principal)
+		}
+		c.Next()
+	}
+}
+
+func setPrincipal(c *gin.Context, principal *services.Principal) {
+	c.Set("principal", principal)
+	c.Set("userId", principal.UserID)
+	c.Set("email", principal.Email)
+	c.Set("role", principal.Role)
+	c.Set("emailVerified", principal.EmailVerified)
+}
+
+var errSessionRequired = apperror.Forbidden("personal access tokens cannot be used for this request")
+
+// RequireScope lets personal access tokens make the read requests (GET and
+// HEAD) of a route group when they have the read scope, and the others when
+// they have the write scope. An empty scope keeps personal access tokens
+// out. Login sessions may make any request. It must run after
+// authMiddleWare.
+func RequireScope(read, write models.TokenScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := write
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			scope = read
+		}
+		principal := c.MustGet("principal").(*services.Principal)
+		if !principal.HasScope(scope) {
+			if scope == "" {
+				c.Error(errSessionRequired)
+			} else {
+				c.Error(apperror.Forbidden(fmt.Sprintf("personal access token lacks the %s scope", scope)))
+			}
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission rejects requests whose role does not grant perm, or
+// whose user has not verified their email address. It must run after
+// authMiddleWare.
+func RequirePermission(perm policy.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !policy.HasPermission(models.Role(c.GetString("role")), perm) {
+			c.Error(services.ErrPermissionDenied)
+			c.Abort()
+			return
+		}
+		if !c.GetBool("emailVerified") {
+			c.Error(services.ErrEmailNotVerified)
+			c.Abort()
 			return
 		}
-		c.Set("userId", claims.UserID)
-		c.Set("email", claims.Email)
 		c.Next()
 	}
 }
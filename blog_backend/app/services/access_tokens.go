@@ -0,0 +1,135 @@
+package services
+
+import (
+	"blog_backend/app/apperror"
+	"blog_backend/app/models"
+	"blog_backend/app/utils"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var (
+	ErrInvalidAccessToken  = apperror.Unauthorized("invalid or expired personal access token")
+	ErrAccessTokenNotFound = apperror.NotFound("personal access token not found")
+)
+
+// lastUsedPrecision is how often the last use of a personal access token is
+// recorded, so that a busy script does not write on every request.
+const lastUsedPrecision = time.Minute
+
+// Principal is the user a request is made by.
+type Principal struct {
+	UserID        int
+	Email         string
+	Role          string
+	EmailVerified bool
+	// AccessTokenID is the personal access token the request is made with.
+	// It is 0 for login sessions.
+	AccessTokenID int
+	// Scopes limits a personal access token to some requests.
+	Scopes []models.TokenScope
+}
+
+// HasScope reports whether the principal may make requests needing scope.
+// Login sessions may make any request, personal access tokens only those
+// their scopes allow, so a token without scopes may make none.
+func (p *Principal) HasScope(scope models.TokenScope) bool {
+	return p.AccessTokenID == 0 || slices.Contains(p.Scopes, scope)
+}
+
+// CreateAccessToken creates a personal access token for a user. The token
+// is returned alone, since only its hash is kept. A nil expiresAt makes a
+// token that does not expire.
+func (a *authServiceImpl) CreateAccessToken(ctx context.Context, userID int, name string, scopes []models.TokenScope,
+	expiresAt *time.Time) (*models.PersonalAccessToken, string, error) {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", apperror.InvalidField("expires_at", "expiry must be in the future")
+	}
+	// Scopes are kept in a fixed order, once each
+	var scopeList []byte
+	for _, scope := range models.TokenScopes {
+		if slices.Contains(scopes, scope) {
+			if len(scopeList) > 0 {
+				scopeList = append(scopeList, ',')
+			}
+			scopeList = append(scopeList, scope...)
+		}
+	}
+	if len(scopeList) == 0 {
+		return nil, "", apperror.InvalidField("scopes", "at least one known scope is required")
+	}
+
+	secret, _, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("create personal access token failed: %w", err)
+	}
+	token := models.PersonalAccessTokenPrefix + secret
+	stored, err := a.accessTokenRepo.CreatePersonalAccessToken(ctx, &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: utils.HashToken(token),
+		Scopes:    string(scopeList),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("store personal access token failed: %w", err)
+	}
+	return stored, token, nil
+}
+
+func (a *authServiceImpl) ListAccessTokens(ctx context.Context, userID int) ([]models.PersonalAccessToken, error) {
+	tokens, err := a.accessTokenRepo.ListPersonalAccessTokens(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list personal access tokens failed: %w", err)
+	}
+	return tokens, nil
+}
+
+// RevokeAccessToken deletes a personal access token of a user, which stops
+// it working at once.
+func (a *authServiceImpl) RevokeAccessToken(ctx context.Context, userID, tokenID int) error {
+	if err := a.accessTokenRepo.DeletePersonalAccessToken(ctx, userID, tokenID); err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return ErrAccessTokenNotFound
+		}
+		return fmt.Errorf("delete personal access token failed: %w", err)
+	}
+	return nil
+}
+
+// authenticateAccessToken checks a personal access token and records its
+// use. The role and email verification of the user are read at every use,
+// so that the token follows changes to them.
+func (a *authServiceImpl) authenticateAccessToken(ctx context.Context, token string) (*Principal, error) {
+	stored, err := a.accessTokenRepo.RetrievePersonalAccessTokenByHash(ctx, utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, ErrInvalidAccessToken
+		}
+		return nil, fmt.Errorf("retrieve personal access token failed: %w", err)
+	}
+	now := time.Now()
+	if stored.Expired(now) {
+		return nil, ErrInvalidAccessToken
+	}
+	user, err := a.userRepo.RetriveUser(ctx, &models.User{ID: stored.UserID})
+	if err != nil {
+		return nil, fmt.Errorf("retrieve user failed: %w", err)
+	}
+	if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) >= lastUsedPrecision {
+		if err := a.accessTokenRepo.TouchPersonalAccessToken(ctx, stored.ID, now); err != nil {
+			return nil, fmt.Errorf("record personal access token use failed: %w", err)
+		}
+	}
+	return &Principal{
+		UserID:        user.ID,
+		Email:         user.Email,
+		Role:          string(user.Role),
+		EmailVerified: user.EmailVerified(),
+		AccessTokenID: stored.ID,
+		Scopes:        stored.ScopeList(),
+	}, nil
+}
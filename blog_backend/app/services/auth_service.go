@@ -1,56 +1,330 @@
 package services
 
 import (
+	"blog_backend/app/apperror"
 	"blog_backend/app/config"
+	"blog_backend/app/keyring"
+	"blog_backend/app/mail"
 	"blog_backend/app/models"
 	"blog_backend/app/repository"
 	"blog_backend/app/utils"
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 )
 
+var (
+	ErrEmailTaken          = apperror.Conflict("email is already registered")
+	ErrInvalidToken        = apperror.Unauthorized("invalid token")
+	ErrInvalidRefreshToken = apperror.Unauthorized("invalid refresh token")
+	ErrRefreshTokenReused  = apperror.Unauthorized("refresh token reuse detected")
+	ErrSessionRevoked      = apperror.Unauthorized("session has been revoked")
+)
+
+// TokenPair is what a successful login or refresh hands back to the client.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
 type AuthService interface {
-	Register(username, email, password string) (*models.User, error)
-	Login(email, password string) (user *models.User, token string, err error)
+	Register(ctx context.Context, username, email, password string) (*models.User, error)
+	Login(ctx context.Context, email, password, clientIP string) (*LoginResult, error)
+	CompleteMFALogin(ctx context.Context, mfaToken, code, clientIP string) (*LoginResult, error)
+	Refresh(ctx context.Context, refreshToken string) (*TokenPair, error)
+	Logout(ctx context.Context, refreshToken string) error
+	Authenticate(ctx context.Context, accessToken string) (*Principal, error)
+	ParseAccessToken(accessToken string) (*utils.CustomClaims, error)
+	// JWKS returns the public keys access tokens are verified with.
+	JWKS() keyring.JWKSet
+	ResendVerification(ctx context.Context, userID int) error
+	VerifyEmail(ctx context.Context, token string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	EnrollMFA(ctx context.Context, userID int) (*MFAEnrollment, error)
+	ConfirmMFA(ctx context.Context, userID int, code string) ([]string, error)
+	DisableMFA(ctx context.Context, userID int, code, clientIP string) error
+	IssueSIWENonce(ctx context.Context) (*SIWEChallenge, error)
+	SignInWithEthereum(ctx context.Context, message, signature string) (*LoginResult, error)
+	LinkWallet(ctx context.Context, userID int, message, signature string) (*models.User, error)
+	CreateAccessToken(ctx context.Context, userID int, name string, scopes []models.TokenScope,
+		expiresAt *time.Time) (*models.PersonalAccessToken, string, error)
+	ListAccessTokens(ctx context.Context, userID int) ([]models.PersonalAccessToken, error)
+	RevokeAccessToken(ctx context.Context, userID, tokenID int) error
 }
 
 type authServiceImpl struct {
-	cfg *config.Config
+	cfg  *config.Config
+	tx   repository.TxManager
+	keys *keyring.Keyring
 
-	userRepo repository.UserRepository
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	userTokenRepo    repository.UserTokenRepository
+	recoveryCodeRepo repository.RecoveryCodeRepository
+	siweNonceRepo    repository.SIWENonceRepository
+	accessTokenRepo  repository.PersonalAccessTokenRepository
+	throttle         *loginThrottle
+	mailer           mail.Mailer
+	templates        *mail.Templates
+	events           Events
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-func (a *authServiceImpl) Register(username, email, password string) (user *models.User, err error) {
+func (a *authServiceImpl) Register(ctx context.Context, username, email, password string) (user *models.User, err error) {
 	user = &models.User{}
 	user.Username = username
-	user.Email = email
+	user.Email = strings.ToLower(strings.TrimSpace(email))
+	user.Role = models.RoleUser
 	user.Password, err = utils.HashPassword(password)
 	if err != nil {
 		return nil, err
 	}
-	return a.userRepo.CreateUser(user)
+	// The verification email is sent before the account is committed, so a
+	// failure to send leaves no account behind
+	user, err = inTx(ctx, a.tx, func(ctx context.Context) (*models.User, error) {
+		user, err := a.userRepo.CreateUser(ctx, user)
+		if err != nil {
+			if errors.Is(err, apperror.ErrConflict) {
+				return nil, ErrEmailTaken
+			}
+			return nil, fmt.Errorf("create user failed: %w", err)
+		}
+		return user, a.sendUserToken(ctx, user, models.UserTokenVerifyEmail)
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.events.UserRegistered()
+	return user, nil
 }
 
-func (a *authServiceImpl) Login(email, password string) (user *models.User, token string, err error) {
-	user = &models.User{}
-	user.Email = email
-	user.Password, err = utils.HashPassword(password)
+// Login verifies the credentials and starts a new session, or challenges
+// for a code when the account has two-factor authentication. Unknown emails
+// still pay for a bcrypt comparison so that response times do not reveal
+// which accounts exist.
+func (a *authServiceImpl) Login(ctx context.Context, email, password, clientIP string) (*LoginResult, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if err := a.throttle.check(ctx, email, clientIP); err != nil {
+		var lockoutErr *LockoutError
+		if errors.As(err, &lockoutErr) {
+			a.events.LoginFailed(LoginFailureLockedOut)
+		}
+		return nil, err
+	}
+
+	user, err := a.userRepo.RetrieveUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, apperror.ErrNotFound) {
+		return nil, fmt.Errorf("retrieve user failed: %w", err)
+	}
+	hashedPassword := a.getDummyHash()
+	if user != nil {
+		hashedPassword = user.Password
+	}
+	if !utils.CheckPassword(password, hashedPassword) || user == nil {
+		if err := a.throttle.recordFailure(ctx, email, clientIP); err != nil {
+			return nil, fmt.Errorf("record failed login failed: %w", err)
+		}
+		a.events.LoginFailed(LoginFailureInvalidCredentials)
+		return nil, ErrInvalidCredentials
+	}
+	if user.MFAEnabled() {
+		// The failures of the account are only cleared by the right code,
+		// so that knowing the password does not reset the guesses at it
+		challenge, err := a.challengeMFA(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{User: user, MFA: challenge}, nil
+	}
+	if err := a.throttle.recordSuccess(ctx, email); err != nil {
+		return nil, fmt.Errorf("reset failed logins failed: %w", err)
+	}
+
+	sessionID, err := utils.GenerateSessionID()
+	if err != nil {
+		return nil, fmt.Errorf("create session failed: %w", err)
+	}
+	tokens, err := a.issueTokens(ctx, user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	a.events.LoginSucceeded()
+	return &LoginResult{User: user, Tokens: tokens}, nil
+}
+
+// Refresh rotates a refresh token: the presented token is revoked and a new
+// one from the same family is issued. Presenting a token that was already
+// rotated means it leaked, so the whole family is revoked.
+func (a *authServiceImpl) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	stored, err := a.retrieveRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if stored.RevokedAt != nil {
+		if err := a.refreshTokenRepo.RevokeTokenFamily(ctx, stored.FamilyID); err != nil {
+			return nil, fmt.Errorf("revoke token family failed: %w", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Revoking the presented token and storing its successor commit together,
+	// so a failure in between cannot leave the session without a live token
+	tokens, err := inTx(ctx, a.tx, func(ctx context.Context) (*TokenPair, error) {
+		revoked, err := a.refreshTokenRepo.RevokeRefreshToken(ctx, stored.ID)
+		if err != nil {
+			return nil, fmt.Errorf("revoke refresh token failed: %w", err)
+		}
+		if !revoked {
+			return nil, ErrRefreshTokenReused
+		}
+		user := &models.User{ID: stored.UserID}
+		user, err = a.userRepo.RetriveUser(ctx, user)
+		if err != nil {
+			return nil, fmt.Errorf("retrieve user failed: %w", err)
+		}
+		return a.issueTokens(ctx, user, stored.FamilyID)
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		// Another request rotated this token between our read and write. The
+		// family is revoked outside the rolled back transaction so it sticks.
+		if err := a.refreshTokenRepo.RevokeTokenFamily(ctx, stored.FamilyID); err != nil {
+			return nil, fmt.Errorf("revoke token family failed: %w", err)
+		}
+	}
+	return tokens, err
+}
+
+func (a *authServiceImpl) Logout(ctx context.Context, refreshToken string) error {
+	stored, err := a.retrieveRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	if err := a.refreshTokenRepo.RevokeTokenFamily(ctx, stored.FamilyID); err != nil {
+		return fmt.Errorf("revoke token family failed: %w", err)
+	}
+	return nil
+}
+
+// Authenticate verifies a personal access token, or an access token and that
+// the session it was issued for has not been revoked since.
+func (a *authServiceImpl) Authenticate(ctx context.Context, accessToken string) (*Principal, error) {
+	if strings.HasPrefix(accessToken, models.PersonalAccessTokenPrefix) {
+		return a.authenticateAccessToken(ctx, accessToken)
+	}
+	claims, err := a.ParseAccessToken(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	active, err := a.refreshTokenRepo.IsFamilyActive(ctx, claims.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("check session failed: %w", err)
+	}
+	if !active {
+		return nil, ErrSessionRevoked
+	}
+	return &Principal{
+		UserID:        claims.UserID,
+		Email:         claims.Email,
+		Role:          claims.Role,
+		EmailVerified: claims.EmailVerified,
+	}, nil
+}
+
+// ParseAccessToken verifies the signature and expiry of an access token,
+// without checking whether its session was revoked.
+func (a *authServiceImpl) ParseAccessToken(accessToken string) (*utils.CustomClaims, error) {
+	claims, err := utils.VerifyJWTToken(a.keys, a.cfg.JWTIssuer, a.cfg.JWTAudience, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+func (a *authServiceImpl) JWKS() keyring.JWKSet {
+	return a.keys.JWKS()
+}
+
+func (a *authServiceImpl) retrieveRefreshToken(ctx context.Context, refreshToken string) (*models.RefreshToken, error) {
+	stored, err := a.refreshTokenRepo.RetrieveRefreshTokenByHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("retrieve refresh token failed: %w", err)
+	}
+	return stored, nil
+}
+
+// getDummyHash lazily builds the hash compared against when a login names an
+// account that does not exist.
+func (a *authServiceImpl) getDummyHash() string {
+	a.dummyHashOnce.Do(func() {
+		a.dummyHash, _ = utils.HashPassword("dummy-password-for-timing")
+	})
+	return a.dummyHash
+}
+
+func (a *authServiceImpl) issueTokens(ctx context.Context, user *models.User, sessionID string) (*TokenPair, error) {
+	key, err := a.keys.Signing(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := utils.CreateJWTToken(key, a.cfg.JWTIssuer, a.cfg.JWTAudience, user.ID, user.Email,
+		string(user.Role), sessionID, user.EmailVerified(), a.cfg.AccessTokenTTL)
 	if err != nil {
-		return nil, "", fmt.Errorf("hash password failed: %w", err)
+		return nil, fmt.Errorf("create jwt token failed: %w", err)
 	}
-	user, err = a.userRepo.RetriveUser(user)
+	refreshToken, refreshHash, err := utils.GenerateOpaqueToken()
 	if err != nil {
-		return nil, "", fmt.Errorf("retrieve user failed: %w", err)
+		return nil, fmt.Errorf("create refresh token failed: %w", err)
 	}
-	token, err = utils.CreateJWTToken(a.cfg.JWTSecret, user.ID, user.Email)
+	_, err = a.refreshTokenRepo.CreateRefreshToken(ctx, &models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  sessionID,
+		TokenHash: refreshHash,
+		ExpiresAt: time.Now().Add(a.cfg.RefreshTokenTTL),
+	})
 	if err != nil {
-		return nil, "", fmt.Errorf("create jwt token failed: %w", err)
+		return nil, fmt.Errorf("store refresh token failed: %w", err)
 	}
-	return user, token, nil
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    a.cfg.AccessTokenTTL,
+	}, nil
 }
 
-func NewAuthService(cfg *config.Config, userRepo repository.UserRepository) AuthService {
+func NewAuthService(cfg *config.Config, tx repository.TxManager, keys *keyring.Keyring, userRepo repository.UserRepository,
+	refreshTokenRepo repository.RefreshTokenRepository,
+	loginAttemptRepo repository.LoginAttemptRepository,
+	userTokenRepo repository.UserTokenRepository,
+	recoveryCodeRepo repository.RecoveryCodeRepository,
+	siweNonceRepo repository.SIWENonceRepository,
+	accessTokenRepo repository.PersonalAccessTokenRepository,
+	mailer mail.Mailer, templates *mail.Templates, events Events) AuthService {
 	return &authServiceImpl{
-		cfg:      cfg,
-		userRepo: userRepo,
+		cfg:              cfg,
+		tx:               tx,
+		keys:             keys,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		userTokenRepo:    userTokenRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		siweNonceRepo:    siweNonceRepo,
+		accessTokenRepo:  accessTokenRepo,
+		throttle:         &loginThrottle{tx: tx, attemptRepo: loginAttemptRepo},
+		mailer:           mailer,
+		templates:        templates,
+		events:           events,
 	}
 }
@@ -1,86 +1,377 @@
 package services
 
 import (
+	"blog_backend/app/apperror"
 	"blog_backend/app/models"
+	"blog_backend/app/policy"
 	"blog_backend/app/repository"
+	"context"
+	"errors"
 	"fmt"
+	"time"
 )
 
+var (
+	ErrCommentNotFound = apperror.NotFound("comment not found")
+	ErrInvalidParent   = apperror.InvalidField("parent_id", "parent comment belongs to another post")
+	ErrCommentTooDeep  = apperror.InvalidField("parent_id", "reply nesting limit reached")
+	ErrCommentDeleted  = apperror.Conflict("comment has been deleted")
+)
+
+// CommentNode is a comment together with the replies below it.
+type CommentNode struct {
+	Comment *models.Comment
+	Replies []*CommentNode
+}
+
+// CommentThreadPage is one page of threads, counted by their root comments.
+type CommentThreadPage struct {
+	Threads    []*CommentNode
+	Total      int64
+	NextCursor string
+}
+
 type CommentService interface {
-	CreateComment(postID int, userID int, content string) (*models.Comment, error)
-	RetrieveComment(commentID int) (*models.Comment, error)
-	UpdateComment(userId int, commentID int, content string) (*models.Comment, error)
-	DeleteComment(userId int, commentID int) error
-	ListComments(postID int) ([]*models.Comment, error)
+	CreateComment(ctx context.Context, actor policy.Actor, postID int, parentID *int, content string) (*models.Comment, error)
+	RetrieveComment(ctx context.Context, actor policy.Actor, commentID int) (*models.Comment, error)
+	UpdateComment(ctx context.Context, actor policy.Actor, commentID int, content string) (*models.Comment, error)
+	DeleteComment(ctx context.Context, actor policy.Actor, commentID int) error
+	ListComments(ctx context.Context, actor policy.Actor, postID int, page repository.Page) (*repository.PageResult[*models.Comment], error)
+	ListCommentThreads(ctx context.Context, actor policy.Actor, postID int, page repository.Page) (*CommentThreadPage, error)
+	ListRevisions(ctx context.Context, actor policy.Actor, commentID int) ([]*models.CommentRevision, error)
+	RetrieveRevision(ctx context.Context, actor policy.Actor, commentID, revision int) (*models.CommentRevision, error)
+	DiffRevisions(ctx context.Context, actor policy.Actor, commentID, from, to int) (*RevisionDiff, error)
+	RestoreRevision(ctx context.Context, actor policy.Actor, commentID, revision int) (*models.Comment, error)
 }
 
 type commentServiceImpl struct {
-	commentRepo repository.CommentRepository
+	tx           repository.TxManager
+	commentRepo  repository.CommentRepository
+	postRepo     repository.PostRepository
+	revisionRepo repository.RevisionRepository
+	maxDepth     int
+	events       Events
 }
 
-func (c *commentServiceImpl) CreateComment(postID int, userID int, content string) (*models.Comment, error) {
-	comment := &models.Comment{
-		PostID:  postID,
-		UserID:  userID,
-		Content: content,
-	}
-	createdComment, err := c.commentRepo.CreateComment(comment)
+func (c *commentServiceImpl) CreateComment(ctx context.Context, actor policy.Actor, postID int, parentID *int, content string) (*models.Comment, error) {
+	createdComment, err := inTx(ctx, c.tx, func(ctx context.Context) (*models.Comment, error) {
+		if err := c.checkPostVisible(ctx, actor, postID); err != nil {
+			return nil, err
+		}
+		comment := &models.Comment{
+			PostID:   postID,
+			UserID:   actor.UserID,
+			Content:  content,
+			ParentID: parentID,
+		}
+		if parentID != nil {
+			parent, err := c.retrieveComment(ctx, *parentID)
+			if err != nil {
+				return nil, err
+			}
+			if parent.PostID != postID {
+				return nil, ErrInvalidParent
+			}
+			if parent.IsDeleted() {
+				return nil, fmt.Errorf("reply to comment %d: %w", parent.ID, ErrCommentDeleted)
+			}
+			if parent.Depth+1 > c.maxDepth {
+				return nil, ErrCommentTooDeep
+			}
+			comment.Depth = parent.Depth + 1
+		}
+		createdComment, err := c.commentRepo.CreateComment(ctx, comment)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create comment: %w", err)
+		}
+		if err := c.recordRevision(ctx, createdComment, actor.UserID); err != nil {
+			return nil, err
+		}
+		return createdComment, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create comment: %w", err)
+		return nil, err
 	}
+	c.events.CommentCreated()
 	return createdComment, nil
 }
 
-func (c *commentServiceImpl) RetrieveComment(commentID int) (*models.Comment, error) {
-	comment, err := c.commentRepo.RetrieveComment(commentID)
+// RetrieveComment returns a comment unless its post is hidden from actor,
+// in which case the comment is not found either.
+func (c *commentServiceImpl) RetrieveComment(ctx context.Context, actor policy.Actor, commentID int) (*models.Comment, error) {
+	comment, err := c.retrieveComment(ctx, commentID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve comment: %w", err)
+		return nil, err
+	}
+	if err := c.checkPostVisible(ctx, actor, comment.PostID); err != nil {
+		if errors.Is(err, ErrPostNotFound) {
+			return nil, ErrCommentNotFound
+		}
+		return nil, err
 	}
 	return comment, nil
 }
 
-func (c *commentServiceImpl) UpdateComment(userId int, commentID int, content string) (*models.Comment, error) {
-	comment, err := c.commentRepo.RetrieveComment(commentID)
+func (c *commentServiceImpl) UpdateComment(ctx context.Context, actor policy.Actor, commentID int, content string) (*models.Comment, error) {
+	return inTx(ctx, c.tx, func(ctx context.Context) (*models.Comment, error) {
+		comment, err := c.retrieveComment(ctx, commentID)
+		if err != nil {
+			return nil, err
+		}
+		if !policy.CanUpdateComment(actor, comment) {
+			return nil, fmt.Errorf("update comment %d: %w", commentID, ErrPermissionDenied)
+		}
+		if comment.IsDeleted() {
+			return nil, fmt.Errorf("update comment %d: %w", commentID, ErrCommentDeleted)
+		}
+		if err := c.ensureBaseRevision(ctx, comment); err != nil {
+			return nil, err
+		}
+		comment.Content = content
+		updatedComment, err := c.commentRepo.UpdateComment(ctx, comment)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update comment: %w", err)
+		}
+		if err := c.recordRevision(ctx, updatedComment, actor.UserID); err != nil {
+			return nil, err
+		}
+		return updatedComment, nil
+	})
+}
+
+func (c *commentServiceImpl) DeleteComment(ctx context.Context, actor policy.Actor, commentID int) error {
+	return c.tx.WithTx(ctx, func(ctx context.Context) error {
+		// Check if the comment exists before attempting to delete
+		comment, err := c.retrieveComment(ctx, commentID)
+		if err != nil {
+			return err
+		}
+		if !policy.CanDeleteComment(actor, comment) {
+			return fmt.Errorf("delete comment %d: %w", commentID, ErrPermissionDenied)
+		}
+		if comment.IsDeleted() {
+			return nil
+		}
+		// A comment with replies stays as a placeholder so the thread holds together
+		replies, err := c.commentRepo.CountReplies(ctx, commentID)
+		if err != nil {
+			return err
+		}
+		if replies > 0 {
+			return c.commentRepo.MarkCommentDeleted(ctx, commentID, time.Now())
+		}
+		if err := c.commentRepo.DeleteComment(ctx, commentID); err != nil {
+			return fmt.Errorf("failed to delete comment: %w", err)
+		}
+		return c.prunePlaceholders(ctx, comment.ParentID)
+	})
+}
+
+// prunePlaceholders removes the placeholders above a deleted comment that no
+// longer have any replies to hold together.
+func (c *commentServiceImpl) prunePlaceholders(ctx context.Context, parentID *int) error {
+	for parentID != nil {
+		parent, err := c.commentRepo.RetrieveComment(ctx, *parentID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve parent comment: %w", err)
+		}
+		if !parent.IsDeleted() {
+			return nil
+		}
+		replies, err := c.commentRepo.CountReplies(ctx, parent.ID)
+		if err != nil {
+			return err
+		}
+		if replies > 0 {
+			return nil
+		}
+		if err := c.commentRepo.DeleteComment(ctx, parent.ID); err != nil {
+			return fmt.Errorf("failed to delete comment placeholder: %w", err)
+		}
+		parentID = parent.ParentID
+	}
+	return nil
+}
+
+func (c *commentServiceImpl) ListComments(ctx context.Context, actor policy.Actor, postID int, page repository.Page) (*repository.PageResult[*models.Comment], error) {
+	if err := c.checkPostVisible(ctx, actor, postID); err != nil {
+		return nil, err
+	}
+	comments, err := c.commentRepo.ListComments(ctx, postID, page)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve comment for update: %w", err)
+		return nil, fmt.Errorf("failed to list comments: %w", err)
 	}
-	if comment.UserID != userId {
-		return nil, fmt.Errorf("user does not have permission to update this comment")
+	return comments, nil
+}
+
+// ListCommentThreads pages through the threads of a post by root comment and
+// returns each root with all of its replies nested below it.
+func (c *commentServiceImpl) ListCommentThreads(ctx context.Context, actor policy.Actor, postID int, page repository.Page) (*CommentThreadPage, error) {
+	if err := c.checkPostVisible(ctx, actor, postID); err != nil {
+		return nil, err
 	}
-	comment.Content = content
-	updatedComment, err := c.commentRepo.UpdateComment(comment)
+	roots, err := c.commentRepo.ListRootComments(ctx, postID, page)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update comment: %w", err)
+		return nil, fmt.Errorf("failed to list comments: %w", err)
 	}
-	return updatedComment, nil
+	replies, err := c.commentRepo.ListThreadReplies(ctx, roots.Items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+
+	result := &CommentThreadPage{
+		Threads:    make([]*CommentNode, len(roots.Items)),
+		Total:      roots.Total,
+		NextCursor: roots.NextCursor,
+	}
+	nodes := make(map[int]*CommentNode, len(roots.Items)+len(replies))
+	for i, root := range roots.Items {
+		result.Threads[i] = &CommentNode{Comment: root}
+		nodes[root.ID] = result.Threads[i]
+	}
+	// Replies come in path order, so every parent is seen before its replies
+	for _, reply := range replies {
+		node := &CommentNode{Comment: reply}
+		nodes[reply.ID] = node
+		if parent, ok := nodes[*reply.ParentID]; ok {
+			parent.Replies = append(parent.Replies, node)
+		}
+	}
+	return result, nil
 }
 
-func (c *commentServiceImpl) DeleteComment(userId, commentID int) error {
-	// Check if the comment exists before attempting to delete
-	comment, err := c.commentRepo.RetrieveComment(commentID)
+func (c *commentServiceImpl) ListRevisions(ctx context.Context, actor policy.Actor, commentID int) ([]*models.CommentRevision, error) {
+	if err := c.checkEditable(ctx, actor, commentID); err != nil {
+		return nil, err
+	}
+	revisions, err := c.revisionRepo.ListCommentRevisions(ctx, commentID)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve comment for deletion: %w", err)
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
 	}
-	if comment.UserID != userId {
-		return fmt.Errorf("user does not have permission to delete this comment")
+	return revisions, nil
+}
+
+func (c *commentServiceImpl) RetrieveRevision(ctx context.Context, actor policy.Actor, commentID, revision int) (*models.CommentRevision, error) {
+	if err := c.checkEditable(ctx, actor, commentID); err != nil {
+		return nil, err
 	}
-	// Proceed to delete the comment
-	if err := c.commentRepo.DeleteComment(commentID); err != nil {
-		return fmt.Errorf("failed to delete comment: %w", err)
+	return c.retrieveRevision(ctx, commentID, revision)
+}
+
+func (c *commentServiceImpl) DiffRevisions(ctx context.Context, actor policy.Actor, commentID, from, to int) (*RevisionDiff, error) {
+	if err := c.checkEditable(ctx, actor, commentID); err != nil {
+		return nil, err
+	}
+	fromRev, err := c.retrieveRevision(ctx, commentID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := c.retrieveRevision(ctx, commentID, to)
+	if err != nil {
+		return nil, err
+	}
+	return newRevisionDiff(from, to, fromRev.Content, toRev.Content), nil
+}
+
+// RestoreRevision brings back the content of an old revision as a new one.
+func (c *commentServiceImpl) RestoreRevision(ctx context.Context, actor policy.Actor, commentID, revision int) (*models.Comment, error) {
+	return inTx(ctx, c.tx, func(ctx context.Context) (*models.Comment, error) {
+		if err := c.checkEditable(ctx, actor, commentID); err != nil {
+			return nil, err
+		}
+		rev, err := c.retrieveRevision(ctx, commentID, revision)
+		if err != nil {
+			return nil, err
+		}
+		return c.UpdateComment(ctx, actor, commentID, rev.Content)
+	})
+}
+
+// checkEditable limits revision history to the people who may edit the
+// comment.
+func (c *commentServiceImpl) checkEditable(ctx context.Context, actor policy.Actor, commentID int) error {
+	comment, err := c.retrieveComment(ctx, commentID)
+	if err != nil {
+		return err
+	}
+	if !policy.CanUpdateComment(actor, comment) {
+		return fmt.Errorf("comment %d revisions: %w", commentID, ErrPermissionDenied)
 	}
 	return nil
 }
 
-func (c *commentServiceImpl) ListComments(postID int) ([]*models.Comment, error) {
-	comments, err := c.commentRepo.ListComments(postID)
+func (c *commentServiceImpl) retrieveComment(ctx context.Context, commentID int) (*models.Comment, error) {
+	comment, err := c.commentRepo.RetrieveComment(ctx, commentID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list comments: %w", err)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, ErrCommentNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve comment: %w", err)
 	}
-	return comments, nil
+	return comment, nil
+}
+
+func (c *commentServiceImpl) retrieveRevision(ctx context.Context, commentID, revision int) (*models.CommentRevision, error) {
+	rev, err := c.revisionRepo.RetrieveCommentRevision(ctx, commentID, revision)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, ErrRevisionNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve revision: %w", err)
+	}
+	return rev, nil
+}
+
+func (c *commentServiceImpl) recordRevision(ctx context.Context, comment *models.Comment, editorID int) error {
+	_, err := c.revisionRepo.CreateCommentRevision(ctx, &models.CommentRevision{
+		CommentID: comment.ID,
+		Content:   comment.Content,
+		EditorID:  editorID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record comment revision: %w", err)
+	}
+	return nil
+}
+
+// ensureBaseRevision snapshots comments written before revisions were kept.
+func (c *commentServiceImpl) ensureBaseRevision(ctx context.Context, comment *models.Comment) error {
+	_, err := c.revisionRepo.RetrieveCommentRevision(ctx, comment.ID, 1)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, apperror.ErrNotFound) {
+		return fmt.Errorf("failed to check comment revisions: %w", err)
+	}
+	return c.recordRevision(ctx, comment, comment.UserID)
+}
+
+// checkPostVisible hides comments of posts the actor may not see, the same
+// way PostService.RetrievePost hides the post itself.
+func (c *commentServiceImpl) checkPostVisible(ctx context.Context, actor policy.Actor, postID int) error {
+	post, err := c.postRepo.RetrievePost(ctx, postID)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return ErrPostNotFound
+		}
+		return fmt.Errorf("failed to retrieve post: %w", err)
+	}
+	if !policy.CanViewPost(actor, post) {
+		return ErrPostNotFound
+	}
+	return nil
 }
 
-func NewCommentService(commentRepo repository.CommentRepository) CommentService {
+// NewCommentService creates a CommentService that accepts replies up to
+// maxDepth levels below a top-level comment.
+func NewCommentService(tx repository.TxManager, commentRepo repository.CommentRepository, postRepo repository.PostRepository,
+	revisionRepo repository.RevisionRepository, maxDepth int, events Events) CommentService {
 	return &commentServiceImpl{
-		commentRepo: commentRepo,
+		tx:           tx,
+		commentRepo:  commentRepo,
+		postRepo:     postRepo,
+		revisionRepo: revisionRepo,
+		maxDepth:     maxDepth,
+		events:       events,
 	}
 }
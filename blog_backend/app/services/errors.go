@@ -0,0 +1,5 @@
+package services
+
+import "blog_backend/app/apperror"
+
+var ErrPermissionDenied = apperror.Forbidden("permission denied")
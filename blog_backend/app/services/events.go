@@ -0,0 +1,29 @@
+package services
+
+// Reasons a login fails, as reported to Events.LoginFailed.
+const (
+	LoginFailureInvalidCredentials = "invalid_credentials"
+	LoginFailureLockedOut          = "locked_out"
+	LoginFailureInvalidMFACode     = "invalid_mfa_code"
+)
+
+// Events is told about the business events the services complete, to count
+// them for metrics. Events are reported once their transaction committed.
+type Events interface {
+	UserRegistered()
+	LoginSucceeded()
+	LoginFailed(reason string)
+	PostCreated()
+	CommentCreated()
+}
+
+// NoEvents ignores every event.
+var NoEvents Events = noEvents{}
+
+type noEvents struct{}
+
+func (noEvents) UserRegistered()    {}
+func (noEvents) LoginSucceeded()    {}
+func (noEvents) LoginFailed(string) {}
+func (noEvents) PostCreated()       {}
+func (noEvents) CommentCreated()    {}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"blog_backend/app/apperror"
+	"blog_backend/app/models"
+	"blog_backend/app/repository"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidCredentials = apperror.Unauthorized("invalid email or password")
+	ErrAccountLocked      = apperror.Locked("account is temporarily locked")
+	ErrTooManyAttempts    = apperror.TooManyRequests("too many failed login attempts")
+)
+
+// LockoutError is returned while an account or client IP is locked out.
+// It unwraps to ErrAccountLocked or ErrTooManyAttempts.
+type LockoutError struct {
+	Reason     error
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Reason, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockoutError) Unwrap() error {
+	return e.Reason
+}
+
+type lockoutPolicy struct {
+	threshold   int
+	baseLockout time.Duration
+	maxLockout  time.Duration
+	window      time.Duration
+}
+
+var (
+	accountLockoutPolicy = lockoutPolicy{threshold: 5, baseLockout: time.Minute, maxLockout: time.Hour, window: 24 * time.Hour}
+	ipLockoutPolicy      = lockoutPolicy{threshold: 20, baseLockout: time.Minute, maxLockout: time.Hour, window: time.Hour}
+)
+
+// lockoutFor doubles the lockout for every failure past the threshold.
+func (p lockoutPolicy) lockoutFor(failedCount int) time.Duration {
+	if failedCount < p.threshold {
+		return 0
+	}
+	lockout := p.baseLockout
+	for i := p.threshold; i < failedCount && lockout < p.maxLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, p.maxLockout)
+}
+
+// loginThrottle tracks failed logins per account and per client IP and
+// applies a progressive lockout to both.
+type loginThrottle struct {
+	tx          repository.TxManager
+	attemptRepo repository.LoginAttemptRepository
+}
+
+func (t *loginThrottle) check(ctx context.Context, email, clientIP string) error {
+	now := time.Now()
+	checks := []struct {
+		scope      string
+		identifier string
+		reason     error
+	}{
+		{models.LoginAttemptScopeIP, clientIP, ErrTooManyAttempts},
+		{models.LoginAttemptScopeAccount, email, ErrAccountLocked},
+	}
+	for _, c := range checks {
+		attempt, err := t.attemptRepo.RetrieveLoginAttempt(ctx, c.scope, c.identifier)
+		if err != nil {
+			return err
+		}
+		if attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+			return &LockoutError{Reason: c.reason, RetryAfter: attempt.LockedUntil.Sub(now)}
+		}
+	}
+	return nil
+}
+
+func (t *loginThrottle) recordFailure(ctx context.Context, email, clientIP string) error {
+	if err := t.bump(ctx, models.LoginAttemptScopeIP, clientIP, ipLockoutPolicy); err != nil {
+		return err
+	}
+	return t.bump(ctx, models.LoginAttemptScopeAccount, email, accountLockoutPolicy)
+}
+
+func (t *loginThrottle) recordSuccess(ctx context.Context, email string) error {
+	return t.attemptRepo.ResetLoginAttempts(ctx, models.LoginAttemptScopeAccount, email)
+}
+
+// bump counts one more failure. The counter row stays locked from read to
+// write, so concurrent failures are all counted.
+func (t *loginThrottle) bump(ctx context.Context, scope, identifier string, policy lockoutPolicy) error {
+	err := t.bumpOnce(ctx, scope, identifier, policy)
+	if errors.Is(err, apperror.ErrConflict) {
+		// There was no row to lock, and a concurrent failure inserted it
+		// first. It exists now, so the second try locks it.
+		err = t.bumpOnce(ctx, scope, identifier, policy)
+	}
+	return err
+}
+
+func (t *loginThrottle) bumpOnce(ctx context.Context, scope, identifier string, policy lockoutPolicy) error {
+	return t.tx.WithTx(ctx, func(ctx context.Context) error {
+		now := time.Now()
+		attempt, err := t.attemptRepo.RetrieveLoginAttempt(ctx, scope, identifier)
+		if err != nil {
+			return err
+		}
+		if now.Sub(attempt.LastFailedAt) > policy.window {
+			attempt.FailedCount = 0
+		}
+		attempt.FailedCount++
+		attempt.LastFailedAt = now
+		if lockout := policy.lockoutFor(attempt.FailedCount); lockout > 0 {
+			lockedUntil := now.Add(lockout)
+			attempt.LockedUntil = &lockedUntil
+		}
+		_, err = t.attemptRepo.SaveLoginAttempt(ctx, attempt)
+		return err
+	})
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"blog_backend/app/apperror"
+	"blog_backend/app/models"
+	"blog_backend/app/utils"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+var (
+	ErrInvalidMFACode    = apperror.InvalidField("code", "code is invalid or was already used")
+	ErrInvalidMFAToken   = apperror.Unauthorized("two-factor login is invalid or expired, log in again")
+	ErrMFAAlreadyEnabled = apperror.Conflict("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = apperror.Conflict("two-factor authentication enrollment has not started")
+	ErrMFANotEnabled     = apperror.Conflict("two-factor authentication is not enabled")
+)
+
+const (
+	// Codes are those of RFC 6238 with the defaults every authenticator
+	// app supports: six digits, SHA-1 and a new code every 30 seconds.
+	// The codes of the steps before and after now are accepted too, for
+	// clocks that drift and users that type slowly.
+	totpPeriod = 30
+	totpSkew   = 1
+	totpDigits = otp.DigitsSix
+	// recoveryCodeCount codes of recoveryCodeBytes random bytes are handed
+	// out on enrollment
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 10
+	qrCodeSize        = 256
+)
+
+// MFAEnrollment is what an authenticator app needs to generate the codes of
+// an account: the secret, as an otpauth:// URI and a QR code of it, or
+// alone for typing in.
+type MFAEnrollment struct {
+	Secret string
+	URI    string
+	// QRCode is a PNG image of URI
+	QRCode []byte
+}
+
+// MFAChallenge is handed out instead of a session by a login with the right
+// password for an account with two-factor authentication. Token completes
+// the login together with a code before ExpiresIn.
+type MFAChallenge struct {
+	Token     string
+	ExpiresIn time.Duration
+}
+
+// LoginResult is the outcome of a login with the right password: a new
+// session, or a challenge when the account has two-factor authentication.
+type LoginResult struct {
+	User   *models.User
+	Tokens *TokenPair
+	MFA    *MFAChallenge
+}
+
+// EnrollMFA creates a new TOTP secret for the user. Logins keep working with
+// the password alone until ConfirmMFA proves the app was set up.
+func (a *authServiceImpl) EnrollMFA(ctx context.Context, userID int) (*MFAEnrollment, error) {
+	return inTx(ctx, a.tx, func(ctx context.Context) (*MFAEnrollment, error) {
+		user, err := a.userRepo.RetriveUser(ctx, &models.User{ID: userID})
+		if err != nil {
+			return nil, fmt.Errorf("retrieve user failed: %w", err)
+		}
+		if user.MFAEnabled() {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		key, err := totp.Generate(totp.GenerateOpts{
+			Issuer:      a.cfg.MFAIssuer,
+			AccountName: user.Email,
+			Period:      totpPeriod,
+			Digits:      totpDigits,
+			Algorithm:   otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create totp secret failed: %w", err)
+		}
+		image, err := key.Image(qrCodeSize, qrCodeSize)
+		if err != nil {
+			return nil, fmt.Errorf("create qr code failed: %w", err)
+		}
+		var qrCode bytes.Buffer
+		if err := png.Encode(&qrCode, image); err != nil {
+			return nil, fmt.Errorf("encode qr code failed: %w", err)
+		}
+		if err := a.userRepo.UpdateTOTP(ctx, user.ID, key.Secret(), nil); err != nil {
+			return nil, fmt.Errorf("store totp secret failed: %w", err)
+		}
+		return &MFAEnrollment{Secret: key.Secret(), URI: key.URL(), QRCode: qrCode.Bytes()}, nil
+	})
+}
+
+// ConfirmMFA turns two-factor authentication on with the first code of the
+// enrolled secret, and returns the recovery codes. Only their hashes are
+// kept, so they cannot be shown again.
+func (a *authServiceImpl) ConfirmMFA(ctx context.Context, userID int, code string) ([]string, error) {
+	return inTx(ctx, a.tx, func(ctx context.Context) ([]string, error) {
+		user, err := a.userRepo.RetriveUser(ctx, &models.User{ID: userID})
+		if err != nil {
+			return nil, fmt.Errorf("retrieve user failed: %w", err)
+		}
+		if user.MFAEnabled() {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		if user.TOTPSecret == "" {
+			return nil, ErrMFANotEnrolled
+		}
+		step, ok := matchTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return nil, ErrInvalidMFACode
+		}
+		now := time.Now()
+		if err := a.userRepo.UpdateTOTP(ctx, user.ID, user.TOTPSecret, &now); err != nil {
+			return nil, fmt.Errorf("enable totp failed: %w", err)
+		}
+		if _, err := a.userRepo.UseTOTPStep(ctx, user.ID, step); err != nil {
+			return nil, fmt.Errorf("use totp code failed: %w", err)
+		}
+		return a.replaceRecoveryCodes(ctx, user.ID)
+	})
+}
+
+// DisableMFA turns two-factor authentication off, given a code of the app
+// or a recovery code, so that a stolen session alone cannot do it.
+func (a *authServiceImpl) DisableMFA(ctx context.Context, userID int, code, clientIP string) error {
+	user, err := a.userRepo.RetriveUser(ctx, &models.User{ID: userID})
+	if err != nil {
+		return fmt.Errorf("retrieve user failed: %w", err)
+	}
+	if !user.MFAEnabled() {
+		return ErrMFANotEnabled
+	}
+	return a.withMFACode(ctx, user, code, clientIP, func(ctx context.Context) error {
+		if err := a.userRepo.UpdateTOTP(ctx, user.ID, "", nil); err != nil {
+			return fmt.Errorf("disable totp failed: %w", err)
+		}
+		if err := a.recoveryCodeRepo.DeleteRecoveryCodes(ctx, user.ID); err != nil {
+			return fmt.Errorf("delete recovery codes failed: %w", err)
+		}
+		return nil
+	})
+}
+
+// CompleteMFALogin finishes a login with two-factor authentication, given
+// the token of its challenge and a code of the app or a recovery code.
+// Wrong codes count as failed logins, so guessing codes locks the account
+// out like guessing passwords does.
+func (a *authServiceImpl) CompleteMFALogin(ctx context.Context, mfaToken, code, clientIP string) (*LoginResult, error) {
+	stored, err := a.userTokenRepo.RetrieveUserTokenByHash(ctx, utils.HashToken(mfaToken))
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, fmt.Errorf("retrieve %s token failed: %w", models.UserTokenMFAPending, err)
+	}
+	if stored.Purpose != models.UserTokenMFAPending || stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidMFAToken
+	}
+	user, err := a.userRepo.RetriveUser(ctx, &models.User{ID: stored.UserID})
+	if err != nil {
+		return nil, fmt.Errorf("retrieve user failed: %w", err)
+	}
+	if !user.MFAEnabled() {
+		return nil, ErrInvalidMFAToken
+	}
+
+	var tokens *TokenPair
+	err = a.withMFACode(ctx, user, code, clientIP, func(ctx context.Context) error {
+		used, err := a.userTokenRepo.UseUserToken(ctx, stored.ID)
+		if err != nil {
+			return fmt.Errorf("use %s token failed: %w", models.UserTokenMFAPending, err)
+		}
+		if !used {
+			return ErrInvalidMFAToken
+		}
+		sessionID, err := utils.GenerateSessionID()
+		if err != nil {
+			return fmt.Errorf("create session failed: %w", err)
+		}
+		tokens, err = a.issueTokens(ctx, user, sessionID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.events.LoginSucceeded()
+	return &LoginResult{User: user, Tokens: tokens}, nil
+}
+
+// challengeMFA hands out the token that completes a login with a code,
+// retiring the previous ones.
+func (a *authServiceImpl) challengeMFA(ctx context.Context, user *models.User) (*MFAChallenge, error) {
+	token, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("create %s token failed: %w", models.UserTokenMFAPending, err)
+	}
+	err = a.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := a.userTokenRepo.InvalidateUserTokens(ctx, user.ID, models.UserTokenMFAPending); err != nil {
+			return fmt.Errorf("invalidate %s tokens failed: %w", models.UserTokenMFAPending, err)
+		}
+		_, err := a.userTokenRepo.CreateUserToken(ctx, &models.UserToken{
+			UserID:    user.ID,
+			Purpose:   models.UserTokenMFAPending,
+			TokenHash: tokenHash,
+			ExpiresAt: time.Now().Add(a.cfg.MFAChallengeTTL),
+		})
+		if err != nil {
+			return fmt.Errorf("store %s token failed: %w", models.UserTokenMFAPending, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MFAChallenge{Token: token, ExpiresIn: a.cfg.MFAChallengeTTL}, nil
+}
+
+// withMFACode uses up code, a code of the user's app or one of their
+// recovery codes, and runs fn in the same transaction. Codes are checked
+// against the login throttle: wrong ones are recorded as failed logins, and
+// a right one clears the failures of the account.
+func (a *authServiceImpl) withMFACode(ctx context.Context, user *models.User, code, clientIP string, fn func(ctx context.Context) error) error {
+	if err := a.throttle.check(ctx, user.Email, clientIP); err != nil {
+		var lockoutErr *LockoutError
+		if errors.As(err, &lockoutErr) {
+			a.events.LoginFailed(LoginFailureLockedOut)
+		}
+		return err
+	}
+	err := a.tx.WithTx(ctx, func(ctx context.Context) error {
+		used, err := a.useMFACode(ctx, user, code)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidMFACode
+		}
+		return fn(ctx)
+	})
+	if errors.Is(err, ErrInvalidMFACode) {
+		// Recorded outside the rolled back transaction so it sticks
+		if err := a.throttle.recordFailure(ctx, user.Email, clientIP); err != nil {
+			return fmt.Errorf("record failed login failed: %w", err)
+		}
+		a.events.LoginFailed(LoginFailureInvalidMFACode)
+		return ErrInvalidMFACode
+	}
+	if err != nil {
+		return err
+	}
+	if err := a.throttle.recordSuccess(ctx, user.Email); err != nil {
+		return fmt.Errorf("reset failed logins failed: %w", err)
+	}
+	return nil
+}
+
+// useMFACode reports whether code is a code of the user's app that was not
+// used yet, or one of their unused recovery codes, and marks it used.
+func (a *authServiceImpl) useMFACode(ctx context.Context, user *models.User, code string) (bool, error) {
+	if step, ok := matchTOTP(user.TOTPSecret, code, time.Now()); ok {
+		used, err := a.userRepo.UseTOTPStep(ctx, user.ID, step)
+		if err != nil {
+			return false, fmt.Errorf("use totp code failed: %w", err)
+		}
+		return used, nil
+	}
+	recoveryCode := normalizeRecoveryCode(code)
+	if recoveryCode == "" {
+		return false, nil
+	}
+	used, err := a.recoveryCodeRepo.UseRecoveryCode(ctx, user.ID, utils.HashToken(recoveryCode))
+	if err != nil {
+		return false, fmt.Errorf("use recovery code failed: %w", err)
+	}
+	return used, nil
+}
+
+// replaceRecoveryCodes hands out new recovery codes, which replace the
+// previous ones.
+func (a *authServiceImpl) replaceRecoveryCodes(ctx context.Context, userID int) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("create recovery code failed: %w", err)
+		}
+		code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))
+		hashes[i] = utils.HashToken(code)
+		// Grouped by four for reading out; the dashes are optional
+		codes[i] = code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+	}
+	if err := a.recoveryCodeRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("store recovery codes failed: %w", err)
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode drops the dashes and spaces of a recovery code and
+// lowercases it, returning "" for what cannot be one.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != base32.StdEncoding.WithPadding(base32.NoPadding).EncodedLen(recoveryCodeBytes) {
+		return ""
+	}
+	return code
+}
+
+// matchTOTP returns the time step whose code is code, among those accepted
+// at now, and false when there is none.
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if secret == "" || len(code) != totpDigits.Length() {
+		return 0, false
+	}
+	opts := totp.ValidateOpts{Period: totpPeriod, Digits: totpDigits, Algorithm: otp.AlgorithmSHA1}
+	step := now.Unix() / totpPeriod
+	for s := step - totpSkew; s <= step+totpSkew; s++ {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(s*totpPeriod, 0), opts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
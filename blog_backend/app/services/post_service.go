@@ -1,76 +1,364 @@
 package services
 
 import (
+	"blog_backend/app/apperror"
 	"blog_backend/app/models"
+	"blog_backend/app/policy"
 	"blog_backend/app/repository"
+	"context"
+	"errors"
 	"fmt"
+	"time"
+)
+
+// PostInput carries the editable fields of a post. On update a nil Tags
+// leaves the tags untouched while an empty slice removes them all, and an
+// empty Status keeps the current status.
+type PostInput struct {
+	Title      string
+	Content    string
+	Tags       []string
+	CategoryID *int
+	Status     models.PostStatus
+	PublishAt  *time.Time
+}
+
+var (
+	ErrCategoryNotFound = apperror.NotFound("category not found")
+	ErrPostNotFound     = apperror.NotFound("post not found")
+	ErrInvalidCategory  = apperror.InvalidField("category_id", "category does not exist")
+	ErrInvalidSchedule  = apperror.InvalidField("publish_at", "scheduled posts need a publish_at in the future")
 )
 
 type PostService interface {
-	CreatePost(title string, content string, userId int) (*models.Post, error)
-	RetrievePost(id int) (*models.Post, error)
-	UpdatePost(userId int, id int, title, content string) (*models.Post, error)
-	DeletePost(userId int, id int) error
+	CreatePost(ctx context.Context, input PostInput, userId int) (*models.Post, error)
+	RetrievePost(ctx context.Context, actor policy.Actor, id int) (*models.Post, error)
+	UpdatePost(ctx context.Context, actor policy.Actor, id int, input PostInput) (*models.Post, error)
+	DeletePost(ctx context.Context, actor policy.Actor, id int) error
+	ListPosts(ctx context.Context, filter repository.PostListFilter, page repository.Page) (*repository.PageResult[*models.Post], error)
+	PublishDuePosts(ctx context.Context, now time.Time) (int, error)
+	ListRevisions(ctx context.Context, actor policy.Actor, postID int) ([]*models.PostRevision, error)
+	RetrieveRevision(ctx context.Context, actor policy.Actor, postID, revision int) (*models.PostRevision, error)
+	DiffRevisions(ctx context.Context, actor policy.Actor, postID, from, to int) (*RevisionDiff, error)
+	RestoreRevision(ctx context.Context, actor policy.Actor, postID, revision int) (*models.Post, error)
 }
 
 type postServiceImpl struct {
-	postRepo repository.PostRepository
+	tx           repository.TxManager
+	postRepo     repository.PostRepository
+	tagRepo      repository.TagRepository
+	categoryRepo repository.CategoryRepository
+	revisionRepo repository.RevisionRepository
+	events       Events
 }
 
-func (p *postServiceImpl) CreatePost(title string, content string, userId int) (*models.Post, error) {
-	post := &models.Post{
-		Title:   title,
-		Content: content,
-		UserID:  userId,
-	}
-	createdPost, err := p.postRepo.CreatePost(post)
+func (p *postServiceImpl) CreatePost(ctx context.Context, input PostInput, userId int) (*models.Post, error) {
+	createdPost, err := inTx(ctx, p.tx, func(ctx context.Context) (*models.Post, error) {
+		if err := p.checkCategory(ctx, input.CategoryID); err != nil {
+			return nil, err
+		}
+		tags, err := p.tagRepo.FindOrCreateTags(ctx, input.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve tags: %w", err)
+		}
+		post := &models.Post{
+			Title:      input.Title,
+			Content:    input.Content,
+			UserID:     userId,
+			CategoryID: input.CategoryID,
+			Tags:       tags,
+		}
+		status := input.Status
+		if status == "" {
+			status = models.PostStatusPublished
+		}
+		if err := applyStatus(post, status, input.PublishAt, time.Now()); err != nil {
+			return nil, err
+		}
+		createdPost, err := p.postRepo.CreatePost(ctx, post)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create post: %w", err)
+		}
+		if err := p.recordRevision(ctx, createdPost, userId); err != nil {
+			return nil, err
+		}
+		return createdPost, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create post: %w", err)
+		return nil, err
 	}
+	p.events.PostCreated()
 	return createdPost, nil
 }
 
-func (p *postServiceImpl) RetrievePost(id int) (*models.Post, error) {
-	post, err := p.postRepo.RetrievePost(id)
+// RetrievePost returns ErrPostNotFound both for missing posts and for posts
+// the actor may not see yet, so drafts do not leak their existence.
+func (p *postServiceImpl) RetrievePost(ctx context.Context, actor policy.Actor, id int) (*models.Post, error) {
+	post, err := p.retrievePost(ctx, id)
 	if err != nil {
+		return nil, err
+	}
+	if !policy.CanViewPost(actor, post) {
+		return nil, ErrPostNotFound
+	}
+	return post, nil
+}
+
+func (p *postServiceImpl) UpdatePost(ctx context.Context, actor policy.Actor, id int, input PostInput) (*models.Post, error) {
+	return inTx(ctx, p.tx, func(ctx context.Context) (*models.Post, error) {
+		post, err := p.retrievePost(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !policy.CanUpdatePost(actor, post) {
+			return nil, fmt.Errorf("update post %d: %w", id, ErrPermissionDenied)
+		}
+		if err := p.checkCategory(ctx, input.CategoryID); err != nil {
+			return nil, err
+		}
+		if err := p.ensureBaseRevision(ctx, post); err != nil {
+			return nil, err
+		}
+		post.Title = input.Title
+		post.Content = input.Content
+		if input.CategoryID != nil {
+			post.CategoryID = input.CategoryID
+		}
+		if input.Status != "" {
+			if err := applyStatus(post, input.Status, input.PublishAt, time.Now()); err != nil {
+				return nil, err
+			}
+		}
+		updatedPost, err := p.postRepo.UpdatePost(ctx, post)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update post: %w", err)
+		}
+		if err := p.recordRevision(ctx, updatedPost, actor.UserID); err != nil {
+			return nil, err
+		}
+		if input.Tags != nil {
+			tags, err := p.tagRepo.FindOrCreateTags(ctx, input.Tags)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve tags: %w", err)
+			}
+			if err := p.postRepo.ReplacePostTags(ctx, updatedPost, tags); err != nil {
+				return nil, fmt.Errorf("failed to update post tags: %w", err)
+			}
+		}
+		return updatedPost, nil
+	})
+}
+
+func (p *postServiceImpl) DeletePost(ctx context.Context, actor policy.Actor, id int) error {
+	return p.tx.WithTx(ctx, func(ctx context.Context) error {
+		post, err := p.retrievePost(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !policy.CanDeletePost(actor, post) {
+			return fmt.Errorf("delete post %d: %w", id, ErrPermissionDenied)
+		}
+		if err := p.postRepo.DeletePost(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete post: %w", err)
+		}
+		return nil
+	})
+}
+
+func (p *postServiceImpl) ListPosts(ctx context.Context, filter repository.PostListFilter, page repository.Page) (*repository.PageResult[*models.Post], error) {
+	posts, err := p.postRepo.ListPosts(ctx, filter, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list posts: %w", err)
+	}
+	return posts, nil
+}
+
+// PublishDuePosts publishes every scheduled post whose publish time has
+// passed. Several instances may run it at once; each post is counted by the
+// single instance whose update won.
+func (p *postServiceImpl) PublishDuePosts(ctx context.Context, now time.Time) (int, error) {
+	const batchSize = 100
+	published := 0
+	for {
+		due, err := p.postRepo.ListDueScheduledPosts(ctx, now, batchSize)
+		if err != nil {
+			return published, err
+		}
+		for _, post := range due {
+			ok, err := p.postRepo.PublishScheduledPost(ctx, post.ID, now)
+			if err != nil {
+				return published, err
+			}
+			if ok {
+				published++
+			}
+		}
+		if len(due) < batchSize {
+			return published, nil
+		}
+	}
+}
+
+func (p *postServiceImpl) ListRevisions(ctx context.Context, actor policy.Actor, postID int) ([]*models.PostRevision, error) {
+	if _, err := p.editablePost(ctx, actor, postID); err != nil {
+		return nil, err
+	}
+	revisions, err := p.revisionRepo.ListPostRevisions(ctx, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+func (p *postServiceImpl) RetrieveRevision(ctx context.Context, actor policy.Actor, postID, revision int) (*models.PostRevision, error) {
+	if _, err := p.editablePost(ctx, actor, postID); err != nil {
+		return nil, err
+	}
+	return p.retrieveRevision(ctx, postID, revision)
+}
+
+func (p *postServiceImpl) DiffRevisions(ctx context.Context, actor policy.Actor, postID, from, to int) (*RevisionDiff, error) {
+	if _, err := p.editablePost(ctx, actor, postID); err != nil {
+		return nil, err
+	}
+	fromRev, err := p.retrieveRevision(ctx, postID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := p.retrieveRevision(ctx, postID, to)
+	if err != nil {
+		return nil, err
+	}
+	diff := newRevisionDiff(from, to, fromRev.Content, toRev.Content)
+	diff.FromTitle = fromRev.Title
+	diff.ToTitle = toRev.Title
+	return diff, nil
+}
+
+// RestoreRevision brings back the title and content of an old revision. The
+// restore is an ordinary edit, so it is recorded as a new revision and the
+// history stays append-only.
+func (p *postServiceImpl) RestoreRevision(ctx context.Context, actor policy.Actor, postID, revision int) (*models.Post, error) {
+	return inTx(ctx, p.tx, func(ctx context.Context) (*models.Post, error) {
+		if _, err := p.editablePost(ctx, actor, postID); err != nil {
+			return nil, err
+		}
+		rev, err := p.retrieveRevision(ctx, postID, revision)
+		if err != nil {
+			return nil, err
+		}
+		return p.UpdatePost(ctx, actor, postID, PostInput{
+			Title:   rev.Title,
+			Content: rev.Content,
+		})
+	})
+}
+
+func (p *postServiceImpl) retrievePost(ctx context.Context, id int) (*models.Post, error) {
+	post, err := p.postRepo.RetrievePost(ctx, id)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, ErrPostNotFound
+		}
 		return nil, fmt.Errorf("failed to retrieve post: %w", err)
 	}
 	return post, nil
 }
 
-func (p *postServiceImpl) UpdatePost(userId int, id int, title, content string) (*models.Post, error) {
-	post, err := p.postRepo.RetrievePost(id)
+func (p *postServiceImpl) editablePost(ctx context.Context, actor policy.Actor, postID int) (*models.Post, error) {
+	post, err := p.retrievePost(ctx, postID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve post for update: %w", err)
+		return nil, err
 	}
-	if post.UserID != userId {
-		return nil, fmt.Errorf("user does not have permission to update this post")
+	if !policy.CanUpdatePost(actor, post) {
+		return nil, fmt.Errorf("post %d revisions: %w", postID, ErrPermissionDenied)
 	}
-	post.Title = title
-	post.Content = content
-	updatedPost, err := p.postRepo.UpdatePost(post)
+	return post, nil
+}
+
+func (p *postServiceImpl) retrieveRevision(ctx context.Context, postID, revision int) (*models.PostRevision, error) {
+	rev, err := p.revisionRepo.RetrievePostRevision(ctx, postID, revision)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update post: %w", err)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, ErrRevisionNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve revision: %w", err)
 	}
-	return updatedPost, nil
+	return rev, nil
 }
 
-func (p *postServiceImpl) DeletePost(userId int, id int) error {
-	post, err := p.postRepo.RetrievePost(id)
+func (p *postServiceImpl) recordRevision(ctx context.Context, post *models.Post, editorID int) error {
+	_, err := p.revisionRepo.CreatePostRevision(ctx, &models.PostRevision{
+		PostID:   post.ID,
+		Title:    post.Title,
+		Content:  post.Content,
+		EditorID: editorID,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to retrieve post for deletion: %w", err)
+		return fmt.Errorf("failed to record post revision: %w", err)
+	}
+	return nil
+}
+
+// ensureBaseRevision snapshots posts written before revisions were kept, so
+// their first edit does not lose the original text.
+func (p *postServiceImpl) ensureBaseRevision(ctx context.Context, post *models.Post) error {
+	_, err := p.revisionRepo.RetrievePostRevision(ctx, post.ID, 1)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, apperror.ErrNotFound) {
+		return fmt.Errorf("failed to check post revisions: %w", err)
 	}
-	if post.UserID != userId {
-		return fmt.Errorf("permission denied")
+	return p.recordRevision(ctx, post, post.UserID)
+}
+
+// applyStatus moves post to status and keeps the lifecycle timestamps
+// consistent with it.
+func applyStatus(post *models.Post, status models.PostStatus, publishAt *time.Time, now time.Time) error {
+	switch status {
+	case models.PostStatusDraft:
+		post.PublishAt = nil
+	case models.PostStatusScheduled:
+		if publishAt == nil || !publishAt.After(now) {
+			return ErrInvalidSchedule
+		}
+		post.PublishAt = publishAt
+	case models.PostStatusPublished:
+		post.PublishAt = nil
+		if post.PublishedAt == nil {
+			post.PublishedAt = &now
+		}
+	case models.PostStatusArchived:
+		post.PublishAt = nil
+	default:
+		return apperror.InvalidField("status", fmt.Sprintf("unknown post status %q", status))
+	}
+	post.Status = status
+	return nil
+}
+
+func (p *postServiceImpl) checkCategory(ctx context.Context, categoryID *int) error {
+	if categoryID == nil {
+		return nil
 	}
-	if err := p.postRepo.DeletePost(id); err != nil {
-		return fmt.Errorf("failed to delete post: %w", err)
+	if _, err := p.categoryRepo.RetrieveCategory(ctx, *categoryID); err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return ErrInvalidCategory
+		}
+		return fmt.Errorf("failed to retrieve category: %w", err)
 	}
 	return nil
 }
 
-func NewPostService(postRepo repository.PostRepository) PostService {
+func NewPostService(tx repository.TxManager, postRepo repository.PostRepository, tagRepo repository.TagRepository,
+	categoryRepo repository.CategoryRepository, revisionRepo repository.RevisionRepository, events Events) PostService {
 	return &postServiceImpl{
-		postRepo: postRepo,
+		tx:           tx,
+		postRepo:     postRepo,
+		tagRepo:      tagRepo,
+		categoryRepo: categoryRepo,
+		revisionRepo: revisionRepo,
+		events:       events,
 	}
 }
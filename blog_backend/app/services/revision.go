@@ -0,0 +1,31 @@
+package services
+
+import (
+	"blog_backend/app/apperror"
+	"blog_backend/app/utils"
+	"fmt"
+)
+
+var ErrRevisionNotFound = apperror.NotFound("revision not found")
+
+const diffContextLines = 3
+
+// RevisionDiff compares the content of two revisions of a post or comment.
+// Titles are only set for posts.
+type RevisionDiff struct {
+	From      int
+	To        int
+	FromTitle string
+	ToTitle   string
+	Unified   string
+	Lines     []utils.DiffLine
+}
+
+func newRevisionDiff(from, to int, fromContent, toContent string) *RevisionDiff {
+	return &RevisionDiff{
+		From:    from,
+		To:      to,
+		Unified: utils.UnifiedDiff(fmt.Sprintf("revision %d", from), fmt.Sprintf("revision %d", to), fromContent, toContent, diffContextLines),
+		Lines:   utils.LineDiff(fromContent, toContent),
+	}
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"blog_backend/app/repository"
+	"context"
+	"fmt"
+)
+
+type SearchService interface {
+	Search(ctx context.Context, query, kind string, limit, offset int) ([]*repository.SearchHit, error)
+}
+
+type searchServiceImpl struct {
+	searchRepo repository.SearchRepository
+}
+
+// Search looks up posts and comments matching query. kind narrows the search
+// to "post" or "comment"; empty searches both.
+func (s *searchServiceImpl) Search(ctx context.Context, query, kind string, limit, offset int) ([]*repository.SearchHit, error) {
+	kinds := []string{repository.SearchKindPost, repository.SearchKindComment}
+	if kind != "" {
+		kinds = []string{kind}
+	}
+	hits, err := s.searchRepo.Search(ctx, query, kinds, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	return hits, nil
+}
+
+func NewSearchService(searchRepo repository.SearchRepository) SearchService {
+	return &searchServiceImpl{
+		searchRepo: searchRepo,
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"blog_backend/app/config"
+	"blog_backend/app/keyring"
+	"blog_backend/app/models"
+	"blog_backend/app/repository"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// KeyRotation is what a pass of RotateKeys changed.
+type KeyRotation struct {
+	// Created is the key published for the next rotation, if one was due
+	Created *keyring.Key
+	// Retired are the kids of the keys dropped once their tokens expired
+	Retired []string
+}
+
+// SigningKeyService keeps the keys access tokens are signed with, stored
+// in the database so that every instance signs with the same keys.
+type SigningKeyService interface {
+	// RotateKeys loads the stored keys into the keyring, creating the next
+	// key when it is due and dropping those no token needs anymore.
+	RotateKeys(ctx context.Context, now time.Time) (*KeyRotation, error)
+}
+
+type signingKeyServiceImpl struct {
+	cfg      *config.Config
+	repo     repository.SigningKeyRepository
+	keys     *keyring.Keyring
+	schedule keyring.Schedule
+}
+
+func (s *signingKeyServiceImpl) RotateKeys(ctx context.Context, now time.Time) (*KeyRotation, error) {
+	keys, err := s.loadKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rotation := &KeyRotation{}
+	next, retired := s.schedule.Plan(keys, now)
+	if next != nil {
+		key, err := keyring.Generate(s.cfg.JWTAlgorithm, *next)
+		if err != nil {
+			return nil, err
+		}
+		sealed, err := keyring.SealPrivateKey(key, s.cfg.JWTSecret)
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.repo.CreateSigningKey(ctx, &models.SigningKey{
+			KID:         key.ID,
+			Algorithm:   key.Algorithm,
+			PrivateKey:  sealed,
+			ActivatesAt: key.ActivatesAt,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("store signing key failed: %w", err)
+		}
+		keys = append(keys, key)
+		rotation.Created = key
+	}
+	for _, key := range retired {
+		if err := s.repo.DeleteSigningKey(ctx, key.ID); err != nil {
+			return nil, fmt.Errorf("retire signing key failed: %w", err)
+		}
+		keys = slices.DeleteFunc(keys, func(k *keyring.Key) bool { return k == key })
+		rotation.Retired = append(rotation.Retired, key.ID)
+	}
+	s.keys.Set(keys)
+	return rotation, nil
+}
+
+// loadKeys decrypts the stored keys. They are all loaded, including those
+// another instance created, so that tokens it signed verify here too.
+func (s *signingKeyServiceImpl) loadKeys(ctx context.Context) ([]*keyring.Key, error) {
+	stored, err := s.repo.ListSigningKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list signing keys failed: %w", err)
+	}
+	keys := make([]*keyring.Key, 0, len(stored))
+	for _, row := range stored {
+		private, err := keyring.OpenPrivateKey(row.PrivateKey, row.KID, s.cfg.JWTSecret)
+		if err != nil {
+			return nil, fmt.Errorf("open signing key %s failed: %w", row.KID, err)
+		}
+		key, err := keyring.NewKey(row.Algorithm, private, row.ActivatesAt)
+		if err != nil {
+			return nil, fmt.Errorf("load signing key %s failed: %w", row.KID, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func NewSigningKeyService(cfg *config.Config, repo repository.SigningKeyRepository, keys *keyring.Keyring) SigningKeyService {
+	return &signingKeyServiceImpl{
+		cfg:  cfg,
+		repo: repo,
+		keys: keys,
+		schedule: keyring.Schedule{
+			Rotation:   cfg.JWTKeyRotation,
+			Prepublish: cfg.JWTKeyPrepublish,
+			TokenTTL:   cfg.AccessTokenTTL,
+		},
+	}
+}
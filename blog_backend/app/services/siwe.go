@@ -0,0 +1,204 @@
+package services
+
+import (
+	"blog_backend/app/apperror"
+	"blog_backend/app/models"
+	"blog_backend/app/siwe"
+	"blog_backend/app/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSIWERejected = apperror.Unauthorized("sign-in message or signature is invalid")
+	ErrWalletTaken  = apperror.Conflict("wallet is already linked to another account")
+)
+
+// siweClockSkew is how far ahead of ours the clock of a wallet may be when
+// it dates a message.
+const siweClockSkew = time.Minute
+
+// SIWEChallenge is what a wallet needs to write the EIP-4361 message it
+// signs: a fresh nonce, valid for ExpiresIn, and the domain and chains the
+// message must be for.
+type SIWEChallenge struct {
+	Nonce     string
+	Domain    string
+	ChainIDs  []int64
+	ExpiresIn time.Duration
+}
+
+// IssueSIWENonce hands out a nonce for a Sign-In with Ethereum message, and
+// clears out the nonces that expired.
+func (a *authServiceImpl) IssueSIWENonce(ctx context.Context) (*SIWEChallenge, error) {
+	chainIDs, err := siwe.ParseChainIDs(a.cfg.SIWEChainIDs)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := siwe.GenerateNonce()
+	if err != nil {
+		return nil, fmt.Errorf("create siwe nonce failed: %w", err)
+	}
+	now := time.Now()
+	if err := a.siweNonceRepo.DeleteExpiredSIWENonces(ctx, now); err != nil {
+		return nil, fmt.Errorf("delete expired siwe nonces failed: %w", err)
+	}
+	_, err = a.siweNonceRepo.CreateSIWENonce(ctx, &models.SIWENonce{
+		NonceHash: utils.HashToken(nonce),
+		ExpiresAt: now.Add(a.cfg.SIWENonceTTL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("store siwe nonce failed: %w", err)
+	}
+	return &SIWEChallenge{
+		Nonce:     nonce,
+		Domain:    a.siweDomain(),
+		ChainIDs:  chainIDs,
+		ExpiresIn: a.cfg.SIWENonceTTL,
+	}, nil
+}
+
+// SignInWithEthereum logs in the user whose wallet signed message, creating
+// an account for wallets seen for the first time. Accounts with two-factor
+// authentication are challenged for a code as with a password.
+func (a *authServiceImpl) SignInWithEthereum(ctx context.Context, message, signature string) (*LoginResult, error) {
+	created := false
+	result, err := inTx(ctx, a.tx, func(ctx context.Context) (*LoginResult, error) {
+		address, err := a.verifySIWE(ctx, message, signature)
+		if err != nil {
+			return nil, err
+		}
+		user, err := a.userRepo.RetrieveUserByWallet(ctx, address.Hex())
+		if errors.Is(err, apperror.ErrNotFound) {
+			user, err = a.createWalletUser(ctx, address)
+			created = true
+		}
+		if err != nil {
+			return nil, fmt.Errorf("retrieve user failed: %w", err)
+		}
+		if user.MFAEnabled() {
+			challenge, err := a.challengeMFA(ctx, user)
+			if err != nil {
+				return nil, err
+			}
+			return &LoginResult{User: user, MFA: challenge}, nil
+		}
+		sessionID, err := utils.GenerateSessionID()
+		if err != nil {
+			return nil, fmt.Errorf("create session failed: %w", err)
+		}
+		tokens, err := a.issueTokens(ctx, user, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{User: user, Tokens: tokens}, nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrSIWERejected) {
+			a.events.LoginFailed(LoginFailureInvalidCredentials)
+		}
+		return nil, err
+	}
+	if created {
+		a.events.UserRegistered()
+	}
+	if result.Tokens != nil {
+		a.events.LoginSucceeded()
+	}
+	return result, nil
+}
+
+// LinkWallet links the wallet that signed message to the account of the
+// user, so that they can sign in with it. It replaces the wallet linked
+// before, if any.
+func (a *authServiceImpl) LinkWallet(ctx context.Context, userID int, message, signature string) (*models.User, error) {
+	return inTx(ctx, a.tx, func(ctx context.Context) (*models.User, error) {
+		address, err := a.verifySIWE(ctx, message, signature)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.userRepo.LinkWallet(ctx, userID, address.Hex()); err != nil {
+			if errors.Is(err, apperror.ErrConflict) {
+				return nil, ErrWalletTaken
+			}
+			return nil, fmt.Errorf("link wallet failed: %w", err)
+		}
+		user, err := a.userRepo.RetriveUser(ctx, &models.User{ID: userID})
+		if err != nil {
+			return nil, fmt.Errorf("retrieve user failed: %w", err)
+		}
+		return user, nil
+	})
+}
+
+// verifySIWE checks a signed EIP-4361 message and uses up its nonce,
+// returning the address that signed it. Why a message was rejected is
+// wrapped in the error, but not told to the client.
+func (a *authServiceImpl) verifySIWE(ctx context.Context, message, signature string) (siwe.Address, error) {
+	msg, err := siwe.ParseMessage(message)
+	if err != nil {
+		return siwe.Address{}, apperror.InvalidField("message", err.Error())
+	}
+	sig, err := siwe.DecodeSignature(signature)
+	if err != nil {
+		return siwe.Address{}, apperror.InvalidField("signature", err.Error())
+	}
+	chainIDs, err := siwe.ParseChainIDs(a.cfg.SIWEChainIDs)
+	if err != nil {
+		return siwe.Address{}, err
+	}
+	err = msg.Verify(message, sig, siwe.Expectations{
+		Domain:   a.siweDomain(),
+		ChainIDs: chainIDs,
+		Nonce:    msg.Nonce,
+		Now:      time.Now(),
+		Skew:     siweClockSkew,
+	})
+	if err != nil {
+		return siwe.Address{}, fmt.Errorf("%w: %w", ErrSIWERejected, err)
+	}
+	used, err := a.siweNonceRepo.UseSIWENonce(ctx, utils.HashToken(msg.Nonce))
+	if err != nil {
+		return siwe.Address{}, fmt.Errorf("use siwe nonce failed: %w", err)
+	}
+	if !used {
+		return siwe.Address{}, fmt.Errorf("%w: unknown, expired or used nonce", ErrSIWERejected)
+	}
+	return msg.Address, nil
+}
+
+// createWalletUser creates the account of a wallet signing in for the first
+// time. It has no password, and no email address: the one it is given is
+// in the .invalid domain, which no email can reach. The signature proved
+// the user controls the wallet, which stands in for verifying an address.
+func (a *authServiceImpl) createWalletUser(ctx context.Context, address siwe.Address) (*models.User, error) {
+	wallet := address.Hex()
+	now := time.Now()
+	user, err := a.userRepo.CreateUser(ctx, &models.User{
+		Username:        wallet,
+		Email:           strings.ToLower(wallet) + "@wallet.invalid",
+		Role:            models.RoleUser,
+		WalletAddress:   &wallet,
+		EmailVerifiedAt: &now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create user failed: %w", err)
+	}
+	return user, nil
+}
+
+// siweDomain is the domain messages must ask to sign in to.
+func (a *authServiceImpl) siweDomain() string {
+	if a.cfg.SIWEDomain != "" {
+		return a.cfg.SIWEDomain
+	}
+	appURL, err := url.Parse(a.cfg.AppURL)
+	if err != nil {
+		return ""
+	}
+	return appURL.Host
+}
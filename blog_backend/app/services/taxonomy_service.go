@@ -0,0 +1,144 @@
+package services
+
+import (
+	"blog_backend/app/apperror"
+	"blog_backend/app/models"
+	"blog_backend/app/policy"
+	"blog_backend/app/repository"
+	"blog_backend/app/utils"
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrTagNotFound           = apperror.NotFound("tag not found")
+	ErrInvalidParentCategory = apperror.InvalidField("parent_id", "parent category does not exist")
+	ErrCategoryExists        = apperror.Conflict("a category with this name already exists")
+)
+
+// CategoryNode is a category with its subcategories resolved.
+type CategoryNode struct {
+	*models.Category
+	Children []*CategoryNode
+}
+
+type TaxonomyService interface {
+	ListTags(ctx context.Context, prefix string, limit int) ([]*repository.TagUsage, error)
+	RetrieveTag(ctx context.Context, slug string) (*models.Tag, error)
+	CreateCategory(ctx context.Context, actor policy.Actor, name string, parentID *int) (*models.Category, error)
+	CategoryTree(ctx context.Context) ([]*CategoryNode, error)
+	CategorySubtreeIDs(ctx context.Context, slug string) ([]int, error)
+}
+
+type taxonomyServiceImpl struct {
+	tx           repository.TxManager
+	tagRepo      repository.TagRepository
+	categoryRepo repository.CategoryRepository
+}
+
+func (t *taxonomyServiceImpl) ListTags(ctx context.Context, prefix string, limit int) ([]*repository.TagUsage, error) {
+	tags, err := t.tagRepo.ListTagUsage(ctx, prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	return tags, nil
+}
+
+func (t *taxonomyServiceImpl) RetrieveTag(ctx context.Context, slug string) (*models.Tag, error) {
+	tag, err := t.tagRepo.RetrieveTagBySlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, ErrTagNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve tag: %w", err)
+	}
+	return tag, nil
+}
+
+func (t *taxonomyServiceImpl) CreateCategory(ctx context.Context, actor policy.Actor, name string, parentID *int) (*models.Category, error) {
+	return inTx(ctx, t.tx, func(ctx context.Context) (*models.Category, error) {
+		if !actor.Can(policy.CategoryManage) {
+			return nil, fmt.Errorf("create category: %w", ErrPermissionDenied)
+		}
+		if parentID != nil {
+			if _, err := t.categoryRepo.RetrieveCategory(ctx, *parentID); err != nil {
+				if errors.Is(err, apperror.ErrNotFound) {
+					return nil, ErrInvalidParentCategory
+				}
+				return nil, fmt.Errorf("failed to retrieve parent category: %w", err)
+			}
+		}
+		category := &models.Category{
+			Name:     name,
+			Slug:     utils.Slugify(name),
+			ParentID: parentID,
+		}
+		created, err := t.categoryRepo.CreateCategory(ctx, category)
+		if err != nil {
+			if errors.Is(err, apperror.ErrConflict) {
+				return nil, ErrCategoryExists
+			}
+			return nil, fmt.Errorf("failed to create category: %w", err)
+		}
+		return created, nil
+	})
+}
+
+func (t *taxonomyServiceImpl) CategoryTree(ctx context.Context) ([]*CategoryNode, error) {
+	categories, err := t.categoryRepo.ListCategories(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", err)
+	}
+	nodes := make(map[int]*CategoryNode, len(categories))
+	for _, c := range categories {
+		nodes[c.ID] = &CategoryNode{Category: c, Children: []*CategoryNode{}}
+	}
+	roots := []*CategoryNode{}
+	for _, c := range categories {
+		node := nodes[c.ID]
+		if c.ParentID != nil {
+			if parent, ok := nodes[*c.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots, nil
+}
+
+// CategorySubtreeIDs returns the ID of the category named by slug followed by
+// the IDs of all of its descendants.
+func (t *taxonomyServiceImpl) CategorySubtreeIDs(ctx context.Context, slug string) ([]int, error) {
+	root, err := t.categoryRepo.RetrieveCategoryBySlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve category: %w", err)
+	}
+	categories, err := t.categoryRepo.ListCategories(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", err)
+	}
+	children := make(map[int][]int)
+	for _, c := range categories {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c.ID)
+		}
+	}
+	ids := []int{root.ID}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids, nil
+}
+
+func NewTaxonomyService(tx repository.TxManager, tagRepo repository.TagRepository, categoryRepo repository.CategoryRepository) TaxonomyService {
+	return &taxonomyServiceImpl{
+		tx:           tx,
+		tagRepo:      tagRepo,
+		categoryRepo: categoryRepo,
+	}
+}
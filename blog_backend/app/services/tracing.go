@@ -0,0 +1,320 @@
+package services
+
+import (
+	"blog_backend/app/keyring"
+	"blog_backend/app/models"
+	"blog_backend/app/policy"
+	"blog_backend/app/repository"
+	"blog_backend/app/tracing"
+	"blog_backend/app/utils"
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traced runs fn in a span named name, which records the error fn returns.
+func traced[T any](ctx context.Context, tracer trace.Tracer, name string, fn func(context.Context) (T, error), attrs ...attribute.KeyValue) (T, error) {
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	defer span.End()
+	result, err := fn(ctx)
+	tracing.RecordError(span, err)
+	return result, err
+}
+
+// tracedErr is traced for methods that only return an error.
+func tracedErr(ctx context.Context, tracer trace.Tracer, name string, fn func(context.Context) error, attrs ...attribute.KeyValue) error {
+	_, err := traced(ctx, tracer, name, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	}, attrs...)
+	return err
+}
+
+func actorAttr(actor policy.Actor) attribute.KeyValue {
+	return attribute.Int("user.id", actor.UserID)
+}
+
+// tracedPostService wraps every PostService method in a span.
+type tracedPostService struct {
+	next   PostService
+	tracer trace.Tracer
+}
+
+// NewTracedPostService returns next with its methods traced by tp.
+func NewTracedPostService(next PostService, tp trace.TracerProvider) PostService {
+	return &tracedPostService{next: next, tracer: tp.Tracer(tracing.InstrumentationName)}
+}
+
+func (s *tracedPostService) CreatePost(ctx context.Context, input PostInput, userId int) (*models.Post, error) {
+	return traced(ctx, s.tracer, "PostService.CreatePost", func(ctx context.Context) (*models.Post, error) {
+		return s.next.CreatePost(ctx, input, userId)
+	}, attribute.Int("user.id", userId))
+}
+
+func (s *tracedPostService) RetrievePost(ctx context.Context, actor policy.Actor, id int) (*models.Post, error) {
+	return traced(ctx, s.tracer, "PostService.RetrievePost", func(ctx context.Context) (*models.Post, error) {
+		return s.next.RetrievePost(ctx, actor, id)
+	}, actorAttr(actor), attribute.Int("post.id", id))
+}
+
+func (s *tracedPostService) UpdatePost(ctx context.Context, actor policy.Actor, id int, input PostInput) (*models.Post, error) {
+	return traced(ctx, s.tracer, "PostService.UpdatePost", func(ctx context.Context) (*models.Post, error) {
+		return s.next.UpdatePost(ctx, actor, id, input)
+	}, actorAttr(actor), attribute.Int("post.id", id))
+}
+
+func (s *tracedPostService) DeletePost(ctx context.Context, actor policy.Actor, id int) error {
+	return tracedErr(ctx, s.tracer, "PostService.DeletePost", func(ctx context.Context) error {
+		return s.next.DeletePost(ctx, actor, id)
+	}, actorAttr(actor), attribute.Int("post.id", id))
+}
+
+func (s *tracedPostService) ListPosts(ctx context.Context, filter repository.PostListFilter, page repository.Page) (*repository.PageResult[*models.Post], error) {
+	return traced(ctx, s.tracer, "PostService.ListPosts", func(ctx context.Context) (*repository.PageResult[*models.Post], error) {
+		return s.next.ListPosts(ctx, filter, page)
+	})
+}
+
+func (s *tracedPostService) PublishDuePosts(ctx context.Context, now time.Time) (int, error) {
+	return traced(ctx, s.tracer, "PostService.PublishDuePosts", func(ctx context.Context) (int, error) {
+		return s.next.PublishDuePosts(ctx, now)
+	})
+}
+
+func (s *tracedPostService) ListRevisions(ctx context.Context, actor policy.Actor, postID int) ([]*models.PostRevision, error) {
+	return traced(ctx, s.tracer, "PostService.ListRevisions", func(ctx context.Context) ([]*models.PostRevision, error) {
+		return s.next.ListRevisions(ctx, actor, postID)
+	}, actorAttr(actor), attribute.Int("post.id", postID))
+}
+
+func (s *tracedPostService) RetrieveRevision(ctx context.Context, actor policy.Actor, postID, revision int) (*models.PostRevision, error) {
+	return traced(ctx, s.tracer, "PostService.RetrieveRevision", func(ctx context.Context) (*models.PostRevision, error) {
+		return s.next.RetrieveRevision(ctx, actor, postID, revision)
+	}, actorAttr(actor), attribute.Int("post.id", postID))
+}
+
+func (s *tracedPostService) DiffRevisions(ctx context.Context, actor policy.Actor, postID, from, to int) (*RevisionDiff, error) {
+	return traced(ctx, s.tracer, "PostService.DiffRevisions", func(ctx context.Context) (*RevisionDiff, error) {
+		return s.next.DiffRevisions(ctx, actor, postID, from, to)
+	}, actorAttr(actor), attribute.Int("post.id", postID))
+}
+
+func (s *tracedPostService) RestoreRevision(ctx context.Context, actor policy.Actor, postID, revision int) (*models.Post, error) {
+	return traced(ctx, s.tracer, "PostService.RestoreRevision", func(ctx context.Context) (*models.Post, error) {
+		return s.next.RestoreRevision(ctx, actor, postID, revision)
+	}, actorAttr(actor), attribute.Int("post.id", postID))
+}
+
+// tracedCommentService wraps every CommentService method in a span.
+type tracedCommentService struct {
+	next   CommentService
+	tracer trace.Tracer
+}
+
+// NewTracedCommentService returns next with its methods traced by tp.
+func NewTracedCommentService(next CommentService, tp trace.TracerProvider) CommentService {
+	return &tracedCommentService{next: next, tracer: tp.Tracer(tracing.InstrumentationName)}
+}
+
+func (s *tracedCommentService) CreateComment(ctx context.Context, actor policy.Actor, postID int, parentID *int, content string) (*models.Comment, error) {
+	return traced(ctx, s.tracer, "CommentService.CreateComment", func(ctx context.Context) (*models.Comment, error) {
+		return s.next.CreateComment(ctx, actor, postID, parentID, content)
+	}, actorAttr(actor), attribute.Int("post.id", postID))
+}
+
+func (s *tracedCommentService) RetrieveComment(ctx context.Context, actor policy.Actor, commentID int) (*models.Comment, error) {
+	return traced(ctx, s.tracer, "CommentService.RetrieveComment", func(ctx context.Context) (*models.Comment, error) {
+		return s.next.RetrieveComment(ctx, actor, commentID)
+	}, actorAttr(actor), attribute.Int("comment.id", commentID))
+}
+
+func (s *tracedCommentService) UpdateComment(ctx context.Context, actor policy.Actor, commentID int, content string) (*models.Comment, error) {
+	return traced(ctx, s.tracer, "CommentService.UpdateComment", func(ctx context.Context) (*models.Comment, error) {
+		return s.next.UpdateComment(ctx, actor, commentID, content)
+	}, actorAttr(actor), attribute.Int("comment.id", commentID))
+}
+
+func (s *tracedCommentService) DeleteComment(ctx context.Context, actor policy.Actor, commentID int) error {
+	return tracedErr(ctx, s.tracer, "CommentService.DeleteComment", func(ctx context.Context) error {
+		return s.next.DeleteComment(ctx, actor, commentID)
+	}, actorAttr(actor), attribute.Int("comment.id", commentID))
+}
+
+func (s *tracedCommentService) ListComments(ctx context.Context, actor policy.Actor, postID int, page repository.Page) (*repository.PageResult[*models.Comment], error) {
+	return traced(ctx, s.tracer, "CommentService.ListComments", func(ctx context.Context) (*repository.PageResult[*models.Comment], error) {
+		return s.next.ListComments(ctx, actor, postID, page)
+	}, actorAttr(actor), attribute.Int("post.id", postID))
+}
+
+func (s *tracedCommentService) ListCommentThreads(ctx context.Context, actor policy.Actor, postID int, page repository.Page) (*CommentThreadPage, error) {
+	return traced(ctx, s.tracer, "CommentService.ListCommentThreads", func(ctx context.Context) (*CommentThreadPage, error) {
+		return s.next.ListCommentThreads(ctx, actor, postID, page)
+	}, actorAttr(actor), attribute.Int("post.id", postID))
+}
+
+func (s *tracedCommentService) ListRevisions(ctx context.Context, actor policy.Actor, commentID int) ([]*models.CommentRevision, error) {
+	return traced(ctx, s.tracer, "CommentService.ListRevisions", func(ctx context.Context) ([]*models.CommentRevision, error) {
+		return s.next.ListRevisions(ctx, actor, commentID)
+	}, actorAttr(actor), attribute.Int("comment.id", commentID))
+}
+
+func (s *tracedCommentService) RetrieveRevision(ctx context.Context, actor policy.Actor, commentID, revision int) (*models.CommentRevision, error) {
+	return traced(ctx, s.tracer, "CommentService.RetrieveRevision", func(ctx context.Context) (*models.CommentRevision, error) {
+		return s.next.RetrieveRevision(ctx, actor, commentID, revision)
+	}, actorAttr(actor), attribute.Int("comment.id", commentID))
+}
+
+func (s *tracedCommentService) DiffRevisions(ctx context.Context, actor policy.Actor, commentID, from, to int) (*RevisionDiff, error) {
+	return traced(ctx, s.tracer, "CommentService.DiffRevisions", func(ctx context.Context) (*RevisionDiff, error) {
+		return s.next.DiffRevisions(ctx, actor, commentID, from, to)
+	}, actorAttr(actor), attribute.Int("comment.id", commentID))
+}
+
+func (s *tracedCommentService) RestoreRevision(ctx context.Context, actor policy.Actor, commentID, revision int) (*models.Comment, error) {
+	return traced(ctx, s.tracer, "CommentService.RestoreRevision", func(ctx context.Context) (*models.Comment, error) {
+		return s.next.RestoreRevision(ctx, actor, commentID, revision)
+	}, actorAttr(actor), attribute.Int("comment.id", commentID))
+}
+
+// tracedAuthService wraps every AuthService method in a span. Credentials
+// and tokens are never recorded.
+type tracedAuthService struct {
+	next   AuthService
+	tracer trace.Tracer
+}
+
+// NewTracedAuthService returns next with its methods traced by tp.
+func NewTracedAuthService(next AuthService, tp trace.TracerProvider) AuthService {
+	return &tracedAuthService{next: next, tracer: tp.Tracer(tracing.InstrumentationName)}
+}
+
+func (s *tracedAuthService) Register(ctx context.Context, username, email, password string) (*models.User, error) {
+	return traced(ctx, s.tracer, "AuthService.Register", func(ctx context.Context) (*models.User, error) {
+		return s.next.Register(ctx, username, email, password)
+	})
+}
+
+func (s *tracedAuthService) Login(ctx context.Context, email, password, clientIP string) (*LoginResult, error) {
+	return traced(ctx, s.tracer, "AuthService.Login", func(ctx context.Context) (*LoginResult, error) {
+		return s.next.Login(ctx, email, password, clientIP)
+	})
+}
+
+func (s *tracedAuthService) CompleteMFALogin(ctx context.Context, mfaToken, code, clientIP string) (*LoginResult, error) {
+	return traced(ctx, s.tracer, "AuthService.CompleteMFALogin", func(ctx context.Context) (*LoginResult, error) {
+		return s.next.CompleteMFALogin(ctx, mfaToken, code, clientIP)
+	})
+}
+
+func (s *tracedAuthService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	return traced(ctx, s.tracer, "AuthService.Refresh", func(ctx context.Context) (*TokenPair, error) {
+		return s.next.Refresh(ctx, refreshToken)
+	})
+}
+
+func (s *tracedAuthService) Logout(ctx context.Context, refreshToken string) error {
+	return tracedErr(ctx, s.tracer, "AuthService.Logout", func(ctx context.Context) error {
+		return s.next.Logout(ctx, refreshToken)
+	})
+}
+
+func (s *tracedAuthService) Authenticate(ctx context.Context, accessToken string) (*Principal, error) {
+	return traced(ctx, s.tracer, "AuthService.Authenticate", func(ctx context.Context) (*Principal, error) {
+		return s.next.Authenticate(ctx, accessToken)
+	})
+}
+
+func (s *tracedAuthService) ResendVerification(ctx context.Context, userID int) error {
+	return tracedErr(ctx, s.tracer, "AuthService.ResendVerification", func(ctx context.Context) error {
+		return s.next.ResendVerification(ctx, userID)
+	})
+}
+
+func (s *tracedAuthService) VerifyEmail(ctx context.Context, token string) error {
+	return tracedErr(ctx, s.tracer, "AuthService.VerifyEmail", func(ctx context.Context) error {
+		return s.next.VerifyEmail(ctx, token)
+	})
+}
+
+func (s *tracedAuthService) ForgotPassword(ctx context.Context, email string) error {
+	return tracedErr(ctx, s.tracer, "AuthService.ForgotPassword", func(ctx context.Context) error {
+		return s.next.ForgotPassword(ctx, email)
+	})
+}
+
+func (s *tracedAuthService) ResetPassword(ctx context.Context, token, password string) error {
+	return tracedErr(ctx, s.tracer, "AuthService.ResetPassword", func(ctx context.Context) error {
+		return s.next.ResetPassword(ctx, token, password)
+	})
+}
+
+func (s *tracedAuthService) EnrollMFA(ctx context.Context, userID int) (*MFAEnrollment, error) {
+	return traced(ctx, s.tracer, "AuthService.EnrollMFA", func(ctx context.Context) (*MFAEnrollment, error) {
+		return s.next.EnrollMFA(ctx, userID)
+	}, attribute.Int("user.id", userID))
+}
+
+func (s *tracedAuthService) ConfirmMFA(ctx context.Context, userID int, code string) ([]string, error) {
+	return traced(ctx, s.tracer, "AuthService.ConfirmMFA", func(ctx context.Context) ([]string, error) {
+		return s.next.ConfirmMFA(ctx, userID, code)
+	}, attribute.Int("user.id", userID))
+}
+
+func (s *tracedAuthService) DisableMFA(ctx context.Context, userID int, code, clientIP string) error {
+	return tracedErr(ctx, s.tracer, "AuthService.DisableMFA", func(ctx context.Context) error {
+		return s.next.DisableMFA(ctx, userID, code, clientIP)
+	}, attribute.Int("user.id", userID))
+}
+
+func (s *tracedAuthService) IssueSIWENonce(ctx context.Context) (*SIWEChallenge, error) {
+	return traced(ctx, s.tracer, "AuthService.IssueSIWENonce", func(ctx context.Context) (*SIWEChallenge, error) {
+		return s.next.IssueSIWENonce(ctx)
+	})
+}
+
+func (s *tracedAuthService) SignInWithEthereum(ctx context.Context, message, signature string) (*LoginResult, error) {
+	return traced(ctx, s.tracer, "AuthService.SignInWithEthereum", func(ctx context.Context) (*LoginResult, error) {
+		return s.next.SignInWithEthereum(ctx, message, signature)
+	})
+}
+
+func (s *tracedAuthService) LinkWallet(ctx context.Context, userID int, message, signature string) (*models.User, error) {
+	return traced(ctx, s.tracer, "AuthService.LinkWallet", func(ctx context.Context) (*models.User, error) {
+		return s.next.LinkWallet(ctx, userID, message, signature)
+	}, attribute.Int("user.id", userID))
+}
+
+func (s *tracedAuthService) CreateAccessToken(ctx context.Context, userID int, name string, scopes []models.TokenScope,
+	expiresAt *time.Time) (*models.PersonalAccessToken, string, error) {
+	var token string
+	stored, err := traced(ctx, s.tracer, "AuthService.CreateAccessToken", func(ctx context.Context) (*models.PersonalAccessToken, error) {
+		stored, plain, err := s.next.CreateAccessToken(ctx, userID, name, scopes, expiresAt)
+		token = plain
+		return stored, err
+	}, attribute.Int("user.id", userID))
+	return stored, token, err
+}
+
+func (s *tracedAuthService) ListAccessTokens(ctx context.Context, userID int) ([]models.PersonalAccessToken, error) {
+	return traced(ctx, s.tracer, "AuthService.ListAccessTokens", func(ctx context.Context) ([]models.PersonalAccessToken, error) {
+		return s.next.ListAccessTokens(ctx, userID)
+	}, attribute.Int("user.id", userID))
+}
+
+func (s *tracedAuthService) RevokeAccessToken(ctx context.Context, userID, tokenID int) error {
+	return tracedErr(ctx, s.tracer, "AuthService.RevokeAccessToken", func(ctx context.Context) error {
+		return s.next.RevokeAccessToken(ctx, userID, tokenID)
+	}, attribute.Int("user.id", userID), attribute.Int("token.id", tokenID))
+}
+
+// ParseAccessToken runs for every rate limited request and touches no
+// storage, so it is not traced.
+func (s *tracedAuthService) ParseAccessToken(accessToken string) (*utils.CustomClaims, error) {
+	return s.next.ParseAccessToken(accessToken)
+}
+
+// JWKS only reads the keyring in memory, so it is not traced either.
+func (s *tracedAuthService) JWKS() keyring.JWKSet {
+	return s.next.JWKS()
+}
@@ -0,0 +1,22 @@
+package services
+
+import (
+	"blog_backend/app/repository"
+	"context"
+)
+
+// inTx runs fn as one unit of work and returns its result, or the zero value
+// when the transaction was rolled back.
+func inTx[T any](ctx context.Context, tx repository.TxManager, fn func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
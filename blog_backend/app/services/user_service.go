@@ -0,0 +1,86 @@
+package services
+
+import (
+	"blog_backend/app/apperror"
+	"blog_backend/app/models"
+	"blog_backend/app/policy"
+	"blog_backend/app/repository"
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrUserNotFound = apperror.NotFound("user not found")
+
+type UserService interface {
+	UpdateRole(ctx context.Context, actor policy.Actor, userID int, role models.Role) (*models.User, error)
+	ResetMFA(ctx context.Context, actor policy.Actor, userID int) error
+}
+
+type userServiceImpl struct {
+	tx               repository.TxManager
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	recoveryCodeRepo repository.RecoveryCodeRepository
+	accessTokenRepo  repository.PersonalAccessTokenRepository
+}
+
+func (u *userServiceImpl) UpdateRole(ctx context.Context, actor policy.Actor, userID int, role models.Role) (*models.User, error) {
+	return inTx(ctx, u.tx, func(ctx context.Context) (*models.User, error) {
+		if !actor.Can(policy.UserManageRoles) {
+			return nil, fmt.Errorf("update role of user %d: %w", userID, ErrPermissionDenied)
+		}
+		if !role.Valid() {
+			return nil, apperror.InvalidField("role", fmt.Sprintf("unknown role %q", role))
+		}
+		user, err := u.userRepo.UpdateUserRole(ctx, userID, role)
+		if err != nil {
+			if errors.Is(err, apperror.ErrNotFound) {
+				return nil, ErrUserNotFound
+			}
+			return nil, fmt.Errorf("failed to update user role: %w", err)
+		}
+		return user, nil
+	})
+}
+
+// ResetMFA turns two-factor authentication off for a user who lost both
+// their authenticator app and their recovery codes. Their sessions end and
+// their personal access tokens are deleted, in case the account was taken
+// over rather than the app lost.
+func (u *userServiceImpl) ResetMFA(ctx context.Context, actor policy.Actor, userID int) error {
+	return u.tx.WithTx(ctx, func(ctx context.Context) error {
+		if !actor.Can(policy.UserResetMFA) {
+			return fmt.Errorf("reset two-factor authentication of user %d: %w", userID, ErrPermissionDenied)
+		}
+		if err := u.userRepo.UpdateTOTP(ctx, userID, "", nil); err != nil {
+			if errors.Is(err, apperror.ErrNotFound) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("failed to disable totp: %w", err)
+		}
+		if err := u.recoveryCodeRepo.DeleteRecoveryCodes(ctx, userID); err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		if err := u.refreshTokenRepo.RevokeUserTokens(ctx, userID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		if err := u.accessTokenRepo.DeleteUserPersonalAccessTokens(ctx, userID); err != nil {
+			return fmt.Errorf("failed to revoke personal access tokens: %w", err)
+		}
+		return nil
+	})
+}
+
+func NewUserService(tx repository.TxManager, userRepo repository.UserRepository,
+	refreshTokenRepo repository.RefreshTokenRepository,
+	recoveryCodeRepo repository.RecoveryCodeRepository,
+	accessTokenRepo repository.PersonalAccessTokenRepository) UserService {
+	return &userServiceImpl{
+		tx:               tx,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		accessTokenRepo:  accessTokenRepo,
+	}
+}
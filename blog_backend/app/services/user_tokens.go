@@ -0,0 +1,161 @@
+package services
+
+import (
+	"blog_backend/app/apperror"
+	"blog_backend/app/mail"
+	"blog_backend/app/models"
+	"blog_backend/app/utils"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidUserToken     = apperror.Validation("token is invalid, expired or already used")
+	ErrEmailNotVerified     = apperror.Forbidden("email address is not verified")
+	ErrEmailAlreadyVerified = apperror.Conflict("email address is already verified")
+)
+
+// userTokenEmails says which email carries the tokens of each purpose, and
+// which page of the web app its link opens.
+var userTokenEmails = map[models.UserTokenPurpose]struct{ template, path string }{
+	models.UserTokenVerifyEmail:   {mail.TemplateVerifyEmail, "/verify-email"},
+	models.UserTokenResetPassword: {mail.TemplateResetPassword, "/reset-password"},
+}
+
+// ResendVerification sends a new verification email, which replaces the
+// link of the previous ones.
+func (a *authServiceImpl) ResendVerification(ctx context.Context, userID int) error {
+	return a.tx.WithTx(ctx, func(ctx context.Context) error {
+		user, err := a.userRepo.RetriveUser(ctx, &models.User{ID: userID})
+		if err != nil {
+			return fmt.Errorf("retrieve user failed: %w", err)
+		}
+		if user.EmailVerified() {
+			return ErrEmailAlreadyVerified
+		}
+		return a.sendUserToken(ctx, user, models.UserTokenVerifyEmail)
+	})
+}
+
+func (a *authServiceImpl) VerifyEmail(ctx context.Context, token string) error {
+	return a.tx.WithTx(ctx, func(ctx context.Context) error {
+		stored, err := a.useUserToken(ctx, token, models.UserTokenVerifyEmail)
+		if err != nil {
+			return err
+		}
+		if err := a.userRepo.MarkEmailVerified(ctx, stored.UserID); err != nil {
+			return fmt.Errorf("mark email verified failed: %w", err)
+		}
+		return nil
+	})
+}
+
+// ForgotPassword emails a password reset link to the account registered
+// with email. It succeeds whether or not there is one, so that callers
+// cannot tell which addresses have an account.
+func (a *authServiceImpl) ForgotPassword(ctx context.Context, email string) error {
+	user, err := a.userRepo.RetrieveUserByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if errors.Is(err, apperror.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("retrieve user failed: %w", err)
+	}
+	return a.tx.WithTx(ctx, func(ctx context.Context) error {
+		return a.sendUserToken(ctx, user, models.UserTokenResetPassword)
+	})
+}
+
+// ResetPassword sets a new password and ends every session of the user.
+// Following the emailed link also proves the user owns the address.
+func (a *authServiceImpl) ResetPassword(ctx context.Context, token, password string) error {
+	passwordHash, err := utils.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("hash password failed: %w", err)
+	}
+	return a.tx.WithTx(ctx, func(ctx context.Context) error {
+		stored, err := a.useUserToken(ctx, token, models.UserTokenResetPassword)
+		if err != nil {
+			return err
+		}
+		if err := a.userRepo.UpdatePassword(ctx, stored.UserID, passwordHash); err != nil {
+			return fmt.Errorf("update password failed: %w", err)
+		}
+		if err := a.userRepo.MarkEmailVerified(ctx, stored.UserID); err != nil {
+			return fmt.Errorf("mark email verified failed: %w", err)
+		}
+		if err := a.refreshTokenRepo.RevokeUserTokens(ctx, stored.UserID); err != nil {
+			return fmt.Errorf("revoke sessions failed: %w", err)
+		}
+		if err := a.accessTokenRepo.DeleteUserPersonalAccessTokens(ctx, stored.UserID); err != nil {
+			return fmt.Errorf("revoke personal access tokens failed: %w", err)
+		}
+		return nil
+	})
+}
+
+// sendUserToken issues a token for purpose and emails its link to user,
+// retiring the tokens sent before. It must run in a transaction: the email
+// is sent last, so a failure to send rolls the token back.
+func (a *authServiceImpl) sendUserToken(ctx context.Context, user *models.User, purpose models.UserTokenPurpose) error {
+	ttl := a.cfg.EmailVerificationTTL
+	if purpose == models.UserTokenResetPassword {
+		ttl = a.cfg.PasswordResetTTL
+	}
+	token, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("create %s token failed: %w", purpose, err)
+	}
+	if err := a.userTokenRepo.InvalidateUserTokens(ctx, user.ID, purpose); err != nil {
+		return fmt.Errorf("invalidate %s tokens failed: %w", purpose, err)
+	}
+	_, err = a.userTokenRepo.CreateUserToken(ctx, &models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return fmt.Errorf("store %s token failed: %w", purpose, err)
+	}
+
+	email := userTokenEmails[purpose]
+	msg, err := a.templates.Render(email.template, user.Email, mail.Data{
+		Username:  user.Username,
+		Link:      strings.TrimSuffix(a.cfg.AppURL, "/") + email.path + "?token=" + token,
+		ExpiresIn: ttl,
+	})
+	if err != nil {
+		return err
+	}
+	if err := a.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("send %s email failed: %w", purpose, err)
+	}
+	return nil
+}
+
+// useUserToken checks that token was issued for purpose and is still valid,
+// and marks it used.
+func (a *authServiceImpl) useUserToken(ctx context.Context, token string, purpose models.UserTokenPurpose) (*models.UserToken, error) {
+	stored, err := a.userTokenRepo.RetrieveUserTokenByHash(ctx, utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, ErrInvalidUserToken
+		}
+		return nil, fmt.Errorf("retrieve %s token failed: %w", purpose, err)
+	}
+	if stored.Purpose != purpose || stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidUserToken
+	}
+	used, err := a.userTokenRepo.UseUserToken(ctx, stored.ID)
+	if err != nil {
+		return nil, fmt.Errorf("use %s token failed: %w", purpose, err)
+	}
+	if !used {
+		return nil, ErrInvalidUserToken
+	}
+	return stored, nil
+}
@@ -0,0 +1,131 @@
+package siwe
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+// The helpers below follow go-ethereum's crypto and accounts packages, and
+// build on the same secp256k1 and Keccak implementations that go-ethereum
+// uses when built without cgo.
+
+// SignatureLength is the length of a signature in the [R || S || V] form
+// wallets return.
+const SignatureLength = 65
+
+// Address is an Ethereum account address.
+type Address [20]byte
+
+// ParseAddress parses a 0x-prefixed hex address. Mixed-case addresses must
+// carry a valid EIP-55 checksum; all-lowercase or all-uppercase ones have
+// none to check.
+func ParseAddress(s string) (Address, error) {
+	var a Address
+	digits, ok := strings.CutPrefix(s, "0x")
+	if !ok || len(digits) != 2*len(a) {
+		return a, fmt.Errorf("invalid address %q: want 0x and 40 hex digits", s)
+	}
+	if _, err := hex.Decode(a[:], []byte(digits)); err != nil {
+		return a, fmt.Errorf("invalid address %q: %w", s, err)
+	}
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && a.Hex() != s {
+		return a, fmt.Errorf("invalid address %q: bad EIP-55 checksum", s)
+	}
+	return a, nil
+}
+
+// Hex returns the address with its EIP-55 checksum: a hex letter is upper
+// case when the matching nibble of the Keccak-256 hash of the lowercase
+// address is 8 or more.
+func (a Address) Hex() string {
+	digits := []byte(hex.EncodeToString(a[:]))
+	hash := Keccak256(digits)
+	for i, c := range digits {
+		nibble := hash[i/2]
+		if i%2 == 0 {
+			nibble >>= 4
+		}
+		if c > '9' && nibble&0xf >= 8 {
+			digits[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(digits)
+}
+
+func (a Address) String() string {
+	return a.Hex()
+}
+
+// Keccak256 is the hash Ethereum uses, which predates and differs from
+// SHA3-256.
+func Keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+// TextHash is the EIP-191 hash of a message signed with personal_sign,
+// which prefixes it so that it cannot be a transaction.
+func TextHash(message []byte) []byte {
+	return Keccak256([]byte("\x19Ethereum Signed Message:\n"+strconv.Itoa(len(message))), message)
+}
+
+// PubkeyToAddress returns the address of a public key: the last 20 bytes of
+// the Keccak-256 hash of its uncompressed form.
+func PubkeyToAddress(pub *secp256k1.PublicKey) Address {
+	var a Address
+	copy(a[:], Keccak256(pub.SerializeUncompressed()[1:])[12:])
+	return a
+}
+
+// Sign signs a 32 byte hash, returning the signature in the [R || S || V]
+// form with V 0 or 1.
+func Sign(hash []byte, key *secp256k1.PrivateKey) ([]byte, error) {
+	if len(hash) != 32 {
+		return nil, fmt.Errorf("hash is %d bytes, want 32", len(hash))
+	}
+	compact := ecdsa.SignCompact(key, hash, false)
+	// SignCompact puts the recovery code, offset by 27, first
+	return append(compact[1:], compact[0]-27), nil
+}
+
+// SigToAddress returns the address whose key made sig over hash. V may be 0
+// or 1, or 27 or 28 as most wallets return it.
+func SigToAddress(hash, sig []byte) (Address, error) {
+	if len(sig) != SignatureLength {
+		return Address{}, fmt.Errorf("signature is %d bytes, want %d", len(sig), SignatureLength)
+	}
+	v := sig[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return Address{}, errors.New("invalid signature recovery id")
+	}
+	compact := make([]byte, SignatureLength)
+	compact[0] = v + 27
+	copy(compact[1:], sig[:64])
+	pub, _, err := ecdsa.RecoverCompact(compact, hash)
+	if err != nil {
+		return Address{}, fmt.Errorf("recover public key: %w", err)
+	}
+	return PubkeyToAddress(pub), nil
+}
+
+// DecodeSignature decodes a 0x-prefixed hex signature.
+func DecodeSignature(s string) ([]byte, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || len(sig) != SignatureLength {
+		return nil, fmt.Errorf("invalid signature: want 0x and %d hex digits", 2*SignatureLength)
+	}
+	return sig, nil
+}
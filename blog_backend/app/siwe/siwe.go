@@ -0,0 +1,294 @@
+// Package siwe implements Sign-In with Ethereum (EIP-4361): the message a
+// wallet signs to prove it controls an address, and the checks of the
+// signature against it.
+package siwe
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	header = " wants you to sign in with your Ethereum account:"
+	// Version is the only message version defined so far
+	Version = "1"
+	// MinNonceLength is the shortest nonce EIP-4361 allows
+	MinNonceLength = 8
+)
+
+// Message is a sign-in request, in the form of the EIP-4361 text the wallet
+// shows and signs. Optional fields are empty when absent.
+type Message struct {
+	// Scheme is the URI scheme of the origin, only given by some wallets
+	Scheme         string
+	Domain         string
+	Address        Address
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// ParseMessage parses the text of a message. It checks that the text is
+// well formed, not that it is fit for signing in; see Message.Verify.
+func ParseMessage(text string) (*Message, error) {
+	p := &parser{lines: strings.Split(text, "\n")}
+	m := &Message{}
+
+	origin, ok := strings.CutSuffix(p.next(), header)
+	if !ok || origin == "" {
+		return nil, p.errorf("want %q after the domain", header)
+	}
+	if scheme, domain, ok := strings.Cut(origin, "://"); ok {
+		m.Scheme, origin = scheme, domain
+	}
+	m.Domain = origin
+
+	address, err := ParseAddress(p.next())
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	m.Address = address
+	if p.next() != "" {
+		return nil, p.errorf("want an empty line after the address")
+	}
+	// The statement is optional, and followed by an empty line when given
+	if line := p.next(); line != "" {
+		m.Statement = line
+		if p.next() != "" {
+			return nil, p.errorf("want an empty line after the statement")
+		}
+	}
+
+	if m.URI, err = p.field("URI", true); err != nil {
+		return nil, err
+	}
+	if m.Version, err = p.field("Version", true); err != nil {
+		return nil, err
+	}
+	chainID, err := p.field("Chain ID", true)
+	if err != nil {
+		return nil, err
+	}
+	if m.ChainID, err = strconv.ParseInt(chainID, 10, 64); err != nil || m.ChainID <= 0 {
+		return nil, p.errorf("invalid chain ID %q", chainID)
+	}
+	if m.Nonce, err = p.field("Nonce", true); err != nil {
+		return nil, err
+	}
+	if !validNonce(m.Nonce) {
+		return nil, p.errorf("nonce must be at least %d letters and digits", MinNonceLength)
+	}
+	issuedAt, err := p.field("Issued At", true)
+	if err != nil {
+		return nil, err
+	}
+	if m.IssuedAt, err = p.time(issuedAt); err != nil {
+		return nil, err
+	}
+	if expirationTime, _ := p.field("Expiration Time", false); expirationTime != "" {
+		t, err := p.time(expirationTime)
+		if err != nil {
+			return nil, err
+		}
+		m.ExpirationTime = &t
+	}
+	if notBefore, _ := p.field("Not Before", false); notBefore != "" {
+		t, err := p.time(notBefore)
+		if err != nil {
+			return nil, err
+		}
+		m.NotBefore = &t
+	}
+	m.RequestID, _ = p.field("Request ID", false)
+	if p.peek() == "Resources:" {
+		p.next()
+		for strings.HasPrefix(p.peek(), "- ") {
+			m.Resources = append(m.Resources, strings.TrimPrefix(p.next(), "- "))
+		}
+	}
+	if !p.done() {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return m, nil
+}
+
+// String returns the text of the message, which is what the wallet signs.
+func (m *Message) String() string {
+	var b strings.Builder
+	if m.Scheme != "" {
+		b.WriteString(m.Scheme + "://")
+	}
+	b.WriteString(m.Domain + header + "\n")
+	b.WriteString(m.Address.Hex() + "\n\n")
+	if m.Statement != "" {
+		b.WriteString(m.Statement + "\n")
+	}
+	b.WriteString("\nURI: " + m.URI)
+	b.WriteString("\nVersion: " + m.Version)
+	b.WriteString("\nChain ID: " + strconv.FormatInt(m.ChainID, 10))
+	b.WriteString("\nNonce: " + m.Nonce)
+	b.WriteString("\nIssued At: " + m.IssuedAt.Format(time.RFC3339))
+	if m.ExpirationTime != nil {
+		b.WriteString("\nExpiration Time: " + m.ExpirationTime.Format(time.RFC3339))
+	}
+	if m.NotBefore != nil {
+		b.WriteString("\nNot Before: " + m.NotBefore.Format(time.RFC3339))
+	}
+	if m.RequestID != "" {
+		b.WriteString("\nRequest ID: " + m.RequestID)
+	}
+	if len(m.Resources) > 0 {
+		b.WriteString("\nResources:")
+		for _, resource := range m.Resources {
+			b.WriteString("\n- " + resource)
+		}
+	}
+	return b.String()
+}
+
+// Expectations are what the relying party requires of a message besides
+// its signature.
+type Expectations struct {
+	Domain   string
+	ChainIDs []int64
+	Nonce    string
+	Now      time.Time
+	// Skew is how far the clock of the wallet may be ahead of ours
+	Skew time.Duration
+}
+
+// Verify checks that the message asks to sign in to the expected domain and
+// chain with the expected nonce, that it is valid now, and that sig is the
+// signature of its text by the key of its address. text must be the text
+// the message was parsed from, since that is what was signed.
+func (m *Message) Verify(text string, sig []byte, want Expectations) error {
+	if m.Version != Version {
+		return fmt.Errorf("unsupported version %q", m.Version)
+	}
+	if !strings.EqualFold(m.Domain, want.Domain) {
+		return fmt.Errorf("message is for domain %q, want %q", m.Domain, want.Domain)
+	}
+	chainOK := false
+	for _, id := range want.ChainIDs {
+		chainOK = chainOK || id == m.ChainID
+	}
+	if !chainOK {
+		return fmt.Errorf("chain ID %d is not accepted", m.ChainID)
+	}
+	if m.Nonce != want.Nonce {
+		return fmt.Errorf("nonce does not match")
+	}
+	if m.IssuedAt.After(want.Now.Add(want.Skew)) {
+		return fmt.Errorf("message is issued in the future")
+	}
+	if m.ExpirationTime != nil && !want.Now.Before(*m.ExpirationTime) {
+		return fmt.Errorf("message expired at %s", m.ExpirationTime.Format(time.RFC3339))
+	}
+	if m.NotBefore != nil && want.Now.Add(want.Skew).Before(*m.NotBefore) {
+		return fmt.Errorf("message is not valid before %s", m.NotBefore.Format(time.RFC3339))
+	}
+	signer, err := SigToAddress(TextHash([]byte(text)), sig)
+	if err != nil {
+		return err
+	}
+	if signer != m.Address {
+		return fmt.Errorf("message is signed by %s, not %s", signer, m.Address)
+	}
+	return nil
+}
+
+// GenerateNonce returns a random nonce of 32 hex digits.
+func GenerateNonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// ParseChainIDs parses a comma-separated list of chain IDs, such as 1,10.
+func ParseChainIDs(s string) ([]int64, error) {
+	var ids []int64
+	for _, item := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(item), 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid chain ID %q", item)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func validNonce(nonce string) bool {
+	if len(nonce) < MinNonceLength {
+		return false
+	}
+	for _, c := range nonce {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// parser walks the lines of a message.
+type parser struct {
+	lines []string
+	pos   int
+}
+
+func (p *parser) next() string {
+	line := p.peek()
+	if p.pos < len(p.lines) {
+		p.pos++
+	}
+	return line
+}
+
+func (p *parser) peek() string {
+	if p.pos >= len(p.lines) {
+		return ""
+	}
+	return p.lines[p.pos]
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.lines)
+}
+
+// field reads the line "<name>: <value>", which may be left out unless it
+// is required.
+func (p *parser) field(name string, required bool) (string, error) {
+	value, ok := strings.CutPrefix(p.peek(), name+": ")
+	if !ok {
+		if required {
+			return "", p.errorf("want %q", name+": ")
+		}
+		return "", nil
+	}
+	p.next()
+	return value, nil
+}
+
+func (p *parser) time(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, p.errorf("invalid timestamp %q", value)
+	}
+	return t, nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid message, line %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,161 @@
+package siwe
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func TestAddressChecksum(t *testing.T) {
+	// Test vectors of EIP-55
+	for _, want := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	} {
+		a, err := ParseAddress(strings.ToLower(want))
+		if err != nil {
+			t.Fatalf("ParseAddress(%s): %v", want, err)
+		}
+		if a.Hex() != want {
+			t.Errorf("got %s, want %s", a.Hex(), want)
+		}
+		if _, err := ParseAddress(want); err != nil {
+			t.Errorf("ParseAddress(%s): %v", want, err)
+		}
+	}
+	if _, err := ParseAddress("0x5aaeb6053F3E94C9b9A09f33669435E7Ef1BeAed"); err == nil {
+		t.Error("accepted an address with a bad checksum")
+	}
+	if _, err := ParseAddress("5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"); err == nil {
+		t.Error("accepted an address without 0x")
+	}
+}
+
+func TestSignAndRecover(t *testing.T) {
+	// The account of the web3.js documentation
+	raw, _ := hex.DecodeString("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+	key := secp256k1.PrivKeyFromBytes(raw)
+	if got := PubkeyToAddress(key.PubKey()).Hex(); got != "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23" {
+		t.Fatalf("got address %s", got)
+	}
+	hash := TextHash([]byte("Hello World"))
+	if got := hex.EncodeToString(hash); got != "a1de988600a42c4b4ab089b619297c17d53cffae5d5120d82d8a92d0bb3b78f2" {
+		t.Fatalf("got text hash %s", got)
+	}
+
+	sig, err := Sign(hash, key)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	for _, v := range []byte{sig[64], sig[64] + 27} {
+		sig := append(sig[:64:64], v)
+		signer, err := SigToAddress(hash, sig)
+		if err != nil {
+			t.Fatalf("SigToAddress: %v", err)
+		}
+		if signer != PubkeyToAddress(key.PubKey()) {
+			t.Fatalf("recovered %s", signer)
+		}
+	}
+	other, _ := SigToAddress(TextHash([]byte("Hello World!")), sig)
+	if other == PubkeyToAddress(key.PubKey()) {
+		t.Fatal("signature verified for another message")
+	}
+}
+
+func testMessage(t *testing.T, key *secp256k1.PrivateKey) *Message {
+	t.Helper()
+	expires := time.Date(2026, 10, 18, 12, 10, 0, 0, time.UTC)
+	return &Message{
+		Domain:         "blog.example.com",
+		Address:        PubkeyToAddress(key.PubKey()),
+		Statement:      "Sign in to the blog.",
+		URI:            "https://blog.example.com/login",
+		Version:        Version,
+		ChainID:        1,
+		Nonce:          "32891756abcdef",
+		IssuedAt:       time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		ExpirationTime: &expires,
+		Resources:      []string{"https://blog.example.com/terms", "ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq"},
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	key, _ := secp256k1.GeneratePrivateKey()
+	for _, m := range []*Message{
+		testMessage(t, key),
+		{Scheme: "https", Domain: "localhost:3000", Address: PubkeyToAddress(key.PubKey()), URI: "http://localhost:3000",
+			Version: Version, ChainID: 137, Nonce: "abcdefgh12", IssuedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			RequestID: "req-1"},
+	} {
+		text := m.String()
+		parsed, err := ParseMessage(text)
+		if err != nil {
+			t.Fatalf("ParseMessage: %v\n%s", err, text)
+		}
+		if parsed.String() != text {
+			t.Fatalf("round trip changed the message:\n%s\n---\n%s", parsed.String(), text)
+		}
+	}
+
+	valid := testMessage(t, key).String()
+	for name, text := range map[string]string{
+		"no header":      strings.Replace(valid, "wants you", "would like you", 1),
+		"bad address":    strings.Replace(valid, PubkeyToAddress(key.PubKey()).Hex(), "0x1234", 1),
+		"no uri":         strings.Replace(valid, "URI: ", "Url: ", 1),
+		"bad chain":      strings.Replace(valid, "Chain ID: 1", "Chain ID: one", 1),
+		"short nonce":    strings.Replace(valid, "Nonce: 32891756abcdef", "Nonce: 1234", 1),
+		"bad time":       strings.Replace(valid, "Issued At: 2026-10-18T12:00:00Z", "Issued At: yesterday", 1),
+		"trailing lines": valid + "\nSomething: else",
+	} {
+		if _, err := ParseMessage(text); err == nil {
+			t.Errorf("%s: parsed an invalid message", name)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	key, _ := secp256k1.GeneratePrivateKey()
+	m := testMessage(t, key)
+	text := m.String()
+	sig, err := Sign(TextHash([]byte(text)), key)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	want := Expectations{
+		Domain:   "blog.example.com",
+		ChainIDs: []int64{1, 10},
+		Nonce:    "32891756abcdef",
+		Now:      time.Date(2026, 10, 18, 12, 5, 0, 0, time.UTC),
+		Skew:     time.Minute,
+	}
+	if err := m.Verify(text, sig, want); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	otherKey, _ := secp256k1.GeneratePrivateKey()
+	otherSig, _ := Sign(TextHash([]byte(text)), otherKey)
+	tests := map[string]func(w *Expectations) ([]byte, string){
+		"other domain": func(w *Expectations) ([]byte, string) { w.Domain = "evil.example.com"; return sig, text },
+		"other chain":  func(w *Expectations) ([]byte, string) { w.ChainIDs = []int64{5}; return sig, text },
+		"other nonce":  func(w *Expectations) ([]byte, string) { w.Nonce = "00000000"; return sig, text },
+		"expired":      func(w *Expectations) ([]byte, string) { w.Now = w.Now.Add(time.Hour); return sig, text },
+		"future":       func(w *Expectations) ([]byte, string) { w.Now = w.Now.Add(-time.Hour); return sig, text },
+		"other signer": func(w *Expectations) ([]byte, string) { return otherSig, text },
+		"edited text": func(w *Expectations) ([]byte, string) {
+			return sig, strings.Replace(text, "Sign in to the blog.", "Sign in to the blog!", 1)
+		},
+	}
+	for name, tweak := range tests {
+		w := want
+		sig, text := tweak(&w)
+		if err := m.Verify(text, sig, w); err == nil {
+			t.Errorf("%s: verified", name)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+// gormPlugin wraps every statement GORM runs in a client span, child of the
+// span in the statement's context.
+type gormPlugin struct {
+	tracer trace.Tracer
+}
+
+// InstrumentDB traces the queries of db with tracer provider tp.
+func InstrumentDB(db *gorm.DB, tp trace.TracerProvider) error {
+	if err := db.Use(&gormPlugin{tracer: tp.Tracer(InstrumentationName)}); err != nil {
+		return fmt.Errorf("failed to register query tracing: %w", err)
+	}
+	return nil
+}
+
+func (p *gormPlugin) Name() string {
+	return "tracing"
+}
+
+func (p *gormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("tracing:before_create", p.startSpan),
+		cb.Create().After("gorm:create").Register("tracing:after_create", endSpan),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", p.startSpan),
+		cb.Query().After("gorm:query").Register("tracing:after_query", endSpan),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", p.startSpan),
+		cb.Update().After("gorm:update").Register("tracing:after_update", endSpan),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", p.startSpan),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", endSpan),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", p.startSpan),
+		cb.Row().After("gorm:row").Register("tracing:after_row", endSpan),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", p.startSpan),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", endSpan),
+	)
+}
+
+func (p *gormPlugin) startSpan(db *gorm.DB) {
+	// The span is named once the statement is built
+	_, span := p.tracer.Start(db.Statement.Context, "db", trace.WithSpanKind(trace.SpanKindClient))
+	db.InstanceSet(spanKey, span)
+}
+
+func endSpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	// The query text holds placeholders, never the bound values
+	query := db.Statement.SQL.String()
+	operation, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	operation = strings.ToUpper(operation)
+	name := operation
+	if db.Statement.Table != "" {
+		name += " " + db.Statement.Table
+		span.SetAttributes(semconv.DBCollectionName(db.Statement.Table))
+	}
+	span.SetName(name)
+	span.SetAttributes(
+		semconv.DBSystemNameKey.String(db.Dialector.Name()),
+		semconv.DBOperationName(operation),
+		semconv.DBQueryText(query),
+	)
+	if operation == "SELECT" {
+		span.SetAttributes(semconv.DBResponseReturnedRows(int(db.Statement.RowsAffected)))
+	}
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		RecordError(span, db.Error)
+	}
+}
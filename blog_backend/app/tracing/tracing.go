@@ -0,0 +1,98 @@
+// Package tracing sets up OpenTelemetry tracing: the tracer provider and
+// its exporter, W3C trace context propagation, and spans for GORM queries.
+package tracing
+
+import (
+	"blog_backend/app/config"
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// InstrumentationName names the tracers of this server.
+const InstrumentationName = "blog_backend"
+
+const serviceName = "blog_backend"
+
+// Propagator reads and writes the W3C traceparent and baggage headers.
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{}, propagation.Baggage{},
+)
+
+// NewProvider returns the tracer provider cfg asks for, and the function
+// that flushes and stops it on shutdown. With the none exporter spans are
+// not recorded at all.
+func NewProvider(ctx context.Context, cfg *config.Config) (trace.TracerProvider, func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	switch cfg.TracingExporter {
+	case "none", "":
+		return noop.NewTracerProvider(), func(context.Context) error { return nil }, nil
+	case "stdout":
+		var err error
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create stdout exporter: %w", err)
+		}
+	case "otlp":
+		// Without an endpoint the OTEL_EXPORTER_OTLP_* variables apply
+		var opts []otlptracehttp.Option
+		if cfg.TracingEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.TracingEndpoint))
+		}
+		var err error
+		exporter, err = otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported tracing exporter %q", cfg.TracingExporter)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to describe tracing resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Follow the caller's sampling decision when it sent one
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TracingSampleRatio))),
+	)
+	return provider, provider.Shutdown, nil
+}
+
+// NewTestProvider returns a provider that records every span, and the
+// exporter holding them once they ended, for assertions in tests.
+func NewTestProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+	)
+	return provider, exporter
+}
+
+// RecordError marks span as failed when err is not nil.
+func RecordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -2,12 +2,161 @@ package utils
 
 import (
 	"blog_backend/app/config"
+	"blog_backend/app/logging"
+	"blog_backend/app/models"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
 
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func InitDatabase(config *config.Config) (*gorm.DB, error) {
-	dsn := "host=localhost user=postgres password=postgres dbname=mydb port=5432 sslmode=disable TimeZone=Asia/Shanghai"
-	return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+const maxConnectBackoff = 30 * time.Second
+
+// InitDatabase connects to the database described by config, retrying with
+// exponential backoff while it is not reachable yet, as happens when the
+// database container starts alongside the server. GORM's messages go to
+// logger.
+func InitDatabase(config *config.Config, logger *slog.Logger) (*gorm.DB, error) {
+	dbConfig := config.Database
+	backoff := dbConfig.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		dialector, err := newDialector(dbConfig)
+		if err != nil {
+			return nil, err
+		}
+		db, err := openDatabase(dialector, dbConfig, logger)
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= dbConfig.ConnectRetries {
+			return nil, fmt.Errorf("failed to connect to %s database after %d attempts: %w", dbConfig.Driver, attempt+1, err)
+		}
+		logger.Warn("database not reachable, retrying", "error", err, "backoff", backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
+
+func openDatabase(dialector gorm.Dialector, dbConfig config.DatabaseConfig, logger *slog.Logger) (*gorm.DB, error) {
+	// gorm.Open pings the database, so a failure here means it is unreachable.
+	// TranslateError reports unique key violations as gorm.ErrDuplicatedKey
+	// whatever the driver.
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true, Logger: logging.NewGormLogger(logger)})
+	if err != nil {
+		if db != nil {
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				sqlDB.Close()
+			}
+		}
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB.SetMaxOpenConns(dbConfig.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(dbConfig.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(dbConfig.ConnMaxLifetime)
+	if dbConfig.Driver == "sqlite" {
+		// SQLite takes one writer at a time, and an in-memory database lives
+		// and dies with its single connection
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+	}
+
+	// Write post tags through our own join model so created_at gets filled
+	if err := db.SetupJoinTable(&models.Post{}, "Tags", &models.PostTag{}); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func newDialector(dbConfig config.DatabaseConfig) (gorm.Dialector, error) {
+	switch dbConfig.Driver {
+	case "postgres":
+		return postgres.Open(postgresDSN(dbConfig)), nil
+	case "mysql":
+		dsn, err := mysqlDSN(dbConfig)
+		if err != nil {
+			return nil, err
+		}
+		return mysql.Open(dsn), nil
+	case "sqlite":
+		return sqlite.Open(sqliteDSN(dbConfig)), nil
+	}
+	return nil, fmt.Errorf("unsupported database driver %q", dbConfig.Driver)
+}
+
+func postgresDSN(dbConfig config.DatabaseConfig) string {
+	params := []string{
+		"host=" + pgQuote(dbConfig.DBhost),
+		"port=" + pgQuote(dbConfig.DBport),
+		"user=" + pgQuote(dbConfig.DBuser),
+		"password=" + pgQuote(dbConfig.DBpassword),
+		"dbname=" + pgQuote(dbConfig.DBname),
+		"sslmode=" + pgQuote(dbConfig.SSLMode),
+		"TimeZone=" + pgQuote(dbConfig.TimeZone),
+	}
+	return strings.Join(params, " ")
+}
+
+// pgQuote quotes a value of a libpq keyword/value connection string.
+func pgQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// mysqlTLS maps the libpq sslmode names used in the config to the tls
+// parameter of the MySQL driver.
+var mysqlTLS = map[string]string{
+	"disable":     "false",
+	"allow":       "preferred",
+	"prefer":      "preferred",
+	"require":     "skip-verify",
+	"verify-ca":   "true",
+	"verify-full": "true",
+}
+
+func mysqlDSN(dbConfig config.DatabaseConfig) (string, error) {
+	loc, err := time.LoadLocation(dbConfig.TimeZone)
+	if err != nil {
+		return "", fmt.Errorf("invalid database time zone: %w", err)
+	}
+	tls, ok := mysqlTLS[dbConfig.SSLMode]
+	if !ok {
+		return "", fmt.Errorf("unsupported sslmode %q for mysql", dbConfig.SSLMode)
+	}
+	cfg := mysqldriver.NewConfig()
+	cfg.User = dbConfig.DBuser
+	cfg.Passwd = dbConfig.DBpassword
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(dbConfig.DBhost, dbConfig.DBport)
+	cfg.DBName = dbConfig.DBname
+	cfg.ParseTime = true
+	cfg.Loc = loc
+	cfg.TLSConfig = tls
+	// SQL migration files may hold several statements
+	cfg.MultiStatements = true
+	cfg.Params = map[string]string{"charset": "utf8mb4"}
+	return cfg.FormatDSN(), nil
+}
+
+func sqliteDSN(dbConfig config.DatabaseConfig) string {
+	params := url.Values{}
+	params.Set("_foreign_keys", "on")
+	params.Set("_busy_timeout", "5000")
+	params.Set("_loc", dbConfig.TimeZone)
+	return "file:" + dbConfig.DBname + "?" + params.Encode()
 }
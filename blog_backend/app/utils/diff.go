@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+type DiffOp byte
+
+const (
+	DiffEqual  DiffOp = ' '
+	DiffInsert DiffOp = '+'
+	DiffDelete DiffOp = '-'
+)
+
+type DiffLine struct {
+	Op   DiffOp
+	Text string
+	// NoNewline is set on the last line of a text that does not end with a
+	// line break, which differs from the same line with one
+	NoNewline bool
+}
+
+// diffCostBudget bounds the work of LineDiff, in steps along the edit graph.
+// Texts too different to diff within it get a valid but longer script.
+const diffCostBudget = 1 << 25
+
+// LineDiff returns the line-by-line edit script turning a into b, based on
+// their longest common subsequence. It uses the linear space variant of
+// Myers' algorithm, so memory grows with the length of the texts only.
+func LineDiff(a, b string) []DiffLine {
+	x, y := splitLines(a), splitLines(b)
+	d := newLineDiffer(x, y)
+	d.compare(0, len(x), 0, len(y))
+
+	lines := make([]DiffLine, 0, len(x)+len(y))
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && d.deleted[i]:
+			lines = append(lines, newDiffLine(DiffDelete, x[i]))
+			i++
+		case j < len(y) && d.inserted[j]:
+			lines = append(lines, newDiffLine(DiffInsert, y[j]))
+			j++
+		default:
+			lines = append(lines, newDiffLine(DiffEqual, x[i]))
+			i++
+			j++
+		}
+	}
+	return lines
+}
+
+func newDiffLine(op DiffOp, line string) DiffLine {
+	text, ok := strings.CutSuffix(line, "\n")
+	return DiffLine{Op: op, Text: text, NoNewline: !ok}
+}
+
+// lineDiffer marks the lines of x deleted and the lines of y inserted by
+// the edit script. Lines are compared by id, the same for equal lines.
+type lineDiffer struct {
+	x, y              []int
+	deleted, inserted []bool
+	// forward and backward hold the furthest x reached on each diagonal,
+	// from the start and from the end
+	forward, backward []int
+}
+
+func newLineDiffer(a, b []string) *lineDiffer {
+	ids := map[string]int{}
+	intern := func(lines []string) []int {
+		out := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	size := (len(a)+len(b)+1)/2 + 2
+	return &lineDiffer{
+		x:        intern(a),
+		y:        intern(b),
+		deleted:  make([]bool, len(a)),
+		inserted: make([]bool, len(b)),
+		forward:  make([]int, 2*size),
+		backward: make([]int, 2*size),
+	}
+}
+
+// compare diffs x[xlo:xhi] against y[ylo:yhi], splitting them where the
+// shortest edit script crosses its middle until one side is empty.
+func (d *lineDiffer) compare(xlo, xhi, ylo, yhi int) {
+	for xlo < xhi && ylo < yhi && d.x[xlo] == d.y[ylo] {
+		xlo++
+		ylo++
+	}
+	for xlo < xhi && ylo < yhi && d.x[xhi-1] == d.y[yhi-1] {
+		xhi--
+		yhi--
+	}
+	if xlo == xhi || ylo == yhi {
+		d.replace(xlo, xhi, ylo, yhi)
+		return
+	}
+	x, y, ok := d.bisect(xlo, xhi, ylo, yhi)
+	if !ok || (x == xlo && y == ylo) || (x == xhi && y == yhi) {
+		d.replace(xlo, xhi, ylo, yhi)
+		return
+	}
+	d.compare(xlo, x, ylo, y)
+	d.compare(x, xhi, y, yhi)
+}
+
+// replace marks x[xlo:xhi] deleted and y[ylo:yhi] inserted.
+func (d *lineDiffer) replace(xlo, xhi, ylo, yhi int) {
+	for i := xlo; i < xhi; i++ {
+		d.deleted[i] = true
+	}
+	for j := ylo; j < yhi; j++ {
+		d.inserted[j] = true
+	}
+}
+
+// bisect finds a point on a shortest edit script of x[xlo:xhi] and
+// y[ylo:yhi] by searching from both ends at once until the paths overlap.
+// It gives up, returning false, once the search would exceed diffCostBudget.
+func (d *lineDiffer) bisect(xlo, xhi, ylo, yhi int) (x, y int, ok bool) {
+	n, m := xhi-xlo, yhi-ylo
+	maxCost := (n + m + 1) / 2
+	costLimit := max(64, diffCostBudget/(n+m))
+	// forward[off+k] is the furthest x reached from the start on diagonal
+	// k, where x-y == k; backward the same from the end. -1 is unreached.
+	off := maxCost + 1
+	forward, backward := d.forward[:2*off+1], d.backward[:2*off+1]
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[off+1], backward[off+1] = 0, 0
+	delta := n - m
+	// With an odd delta the paths meet on a forward step, else a backward one
+	front := delta%2 != 0
+	// Diagonals that ran off the edit graph are not extended again
+	var k1start, k1end, k2start, k2end int
+	for cost := 0; cost < maxCost && cost <= costLimit; cost++ {
+		for k1 := -cost + k1start; k1 <= cost-k1end; k1 += 2 {
+			var x1 int
+			if k1 == -cost || (k1 != cost && forward[off+k1-1] < forward[off+k1+1]) {
+				x1 = forward[off+k1+1]
+			} else {
+				x1 = forward[off+k1-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && d.x[xlo+x1] == d.y[ylo+y1] {
+				x1++
+				y1++
+			}
+			forward[off+k1] = x1
+			switch {
+			case x1 > n:
+				k1end += 2
+			case y1 > m:
+				k1start += 2
+			case front:
+				if k2 := off + delta - k1; k2 >= 0 && k2 < len(backward) && backward[k2] != -1 && x1 >= n-backward[k2] {
+					return xlo + x1, ylo + y1, true
+				}
+			}
+		}
+		for k2 := -cost + k2start; k2 <= cost-k2end; k2 += 2 {
+			var x2 int
+			if k2 == -cost || (k2 != cost && backward[off+k2-1] < backward[off+k2+1]) {
+				x2 = backward[off+k2+1]
+			} else {
+				x2 = backward[off+k2-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && d.x[xhi-1-x2] == d.y[yhi-1-y2] {
+				x2++
+				y2++
+			}
+			backward[off+k2] = x2
+			switch {
+			case x2 > n:
+				k2end += 2
+			case y2 > m:
+				k2start += 2
+			case !front:
+				if k1 := off + delta - k2; k1 >= 0 && k1 < len(forward) && forward[k1] != -1 && forward[k1] >= n-x2 {
+					x1 := forward[k1]
+					return xlo + x1, ylo + x1 - (delta - k2), true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// UnifiedDiff renders the difference between a and b in unified diff format
+// with the given number of context lines. It is empty when a equals b.
+func UnifiedDiff(fromName, toName, a, b string, context int) string {
+	lines := LineDiff(a, b)
+	var out strings.Builder
+	fromLine, toLine := 1, 1
+	for start := 0; start < len(lines); {
+		// Find the next change and the hunk around it
+		for start < len(lines) && lines[start].Op == DiffEqual {
+			start++
+			fromLine++
+			toLine++
+		}
+		if start == len(lines) {
+			break
+		}
+		hunkStart := max(0, start-context)
+		end, equalRun := start, 0
+		for end < len(lines) && equalRun <= 2*context {
+			if lines[end].Op == DiffEqual {
+				equalRun++
+			} else {
+				equalRun = 0
+			}
+			end++
+		}
+		hunkEnd := min(len(lines), end-equalRun+context)
+
+		hunkFrom, hunkTo := fromLine-(start-hunkStart), toLine-(start-hunkStart)
+		fromCount, toCount := 0, 0
+		var body strings.Builder
+		for _, l := range lines[hunkStart:hunkEnd] {
+			body.WriteByte(byte(l.Op))
+			body.WriteString(l.Text)
+			body.WriteByte('\n')
+			if l.NoNewline {
+				body.WriteString("\\ No newline at end of file\n")
+			}
+			if l.Op != DiffInsert {
+				fromCount++
+			}
+			if l.Op != DiffDelete {
+				toCount++
+			}
+		}
+		// An empty side is addressed by the line before it
+		if fromCount == 0 {
+			hunkFrom--
+		}
+		if toCount == 0 {
+			hunkTo--
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(hunkFrom, fromCount), hunkRange(hunkTo, toCount))
+		out.WriteString(body.String())
+
+		for _, l := range lines[start:hunkEnd] {
+			if l.Op != DiffInsert {
+				fromLine++
+			}
+			if l.Op != DiffDelete {
+				toLine++
+			}
+		}
+		start = hunkEnd
+	}
+	return out.String()
+}
+
+// splitLines splits s into lines that keep their line break, so that a last
+// line without one does not compare equal to the same line with one.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// hunkRange formats one side of a hunk header, leaving out the length of
+// single-line ranges as diff -u does.
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// apply rebuilds both sides of an edit script.
+func apply(lines []DiffLine) (string, string) {
+	var a, b strings.Builder
+	for _, l := range lines {
+		text := l.Text + "\n"
+		if l.NoNewline {
+			text = l.Text
+		}
+		if l.Op != DiffInsert {
+			a.WriteString(text)
+		}
+		if l.Op != DiffDelete {
+			b.WriteString(text)
+		}
+	}
+	return a.String(), b.String()
+}
+
+// lcsLength is the textbook quadratic LCS, to check LineDiff is minimal.
+func lcsLength(x, y []string) int {
+	prev, cur := make([]int, len(y)+1), make([]int, len(y)+1)
+	for i := range x {
+		for j := range y {
+			if x[i] == y[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(y)]
+}
+
+func TestLineDiffIsMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	text := func() string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		if len(lines) == 0 || rng.Intn(3) == 0 {
+			return strings.Join(lines, "\n")
+		}
+		return strings.Join(lines, "\n") + "\n"
+	}
+	for range 2000 {
+		a, b := text(), text()
+		lines := LineDiff(a, b)
+		if gotA, gotB := apply(lines); gotA != a || gotB != b {
+			t.Fatalf("LineDiff(%q, %q) rebuilds %q, %q", a, b, gotA, gotB)
+		}
+		equal := 0
+		for _, l := range lines {
+			if l.Op == DiffEqual {
+				equal++
+			}
+		}
+		if want := lcsLength(splitLines(a), splitLines(b)); equal != want {
+			t.Fatalf("LineDiff(%q, %q) keeps %d lines, want %d", a, b, equal, want)
+		}
+	}
+}
+
+func TestLineDiffLargeTexts(t *testing.T) {
+	var a, b strings.Builder
+	for i := range 50000 {
+		fmt.Fprintf(&a, "old %d\n", i)
+		fmt.Fprintf(&b, "new %d\n", i)
+	}
+	// Too different to diff within the budget, they are replaced outright
+	lines := LineDiff(a.String(), b.String())
+	if gotA, gotB := apply(lines); gotA != a.String() || gotB != b.String() {
+		t.Fatal("the edit script does not rebuild the texts")
+	}
+
+	// A few edits to a long text are still found exactly
+	edited := strings.NewReplacer("old 100\n", "", "old 30000\n", "new 30000\n").Replace(a.String()) + "old 50000\n"
+	changed := 0
+	for _, l := range LineDiff(a.String(), edited) {
+		if l.Op != DiffEqual {
+			changed++
+		}
+	}
+	if changed != 4 {
+		t.Fatalf("got %d changed lines, want 4", changed)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	numbered := func(from, to int) string {
+		var s strings.Builder
+		for i := from; i <= to; i++ {
+			fmt.Fprintf(&s, "%d\n", i)
+		}
+		return s.String()
+	}
+	tests := []struct {
+		name, a, b, want string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{"from empty", "", "a\nb\n", "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"to empty", "a\nb\n", "", "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{"insert", numbered(1, 6), strings.Replace(numbered(1, 6), "3\n", "3\nnew\n", 1),
+			"--- a\n+++ b\n@@ -2,4 +2,5 @@\n 2\n 3\n+new\n 4\n 5\n"},
+		{"delete", numbered(1, 6), strings.Replace(numbered(1, 6), "4\n", "", 1),
+			"--- a\n+++ b\n@@ -2,5 +2,4 @@\n 2\n 3\n-4\n 5\n 6\n"},
+		{"single line", "a\n", "b\n", "--- a\n+++ b\n@@ -1 +1 @@\n-a\n+b\n"},
+		// The last line only gains or loses its line break
+		{"newline added", "a\nb", "a\nb\n", "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+		{"newline removed", "a\nb\n", "a\nb", "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n"},
+		{"no newline on both sides", "a\nb", "x\nb", "--- a\n+++ b\n@@ -1,2 +1,2 @@\n-a\n+x\n b\n\\ No newline at end of file\n"},
+		// Changes at most twice the context apart share a hunk
+		{"merged hunks", numbered(1, 10), strings.NewReplacer("3\n", "x\n", "7\n", "y\n").Replace(numbered(1, 10)),
+			"--- a\n+++ b\n@@ -1,9 +1,9 @@\n 1\n 2\n-3\n+x\n 4\n 5\n 6\n-7\n+y\n 8\n 9\n"},
+		{"separate hunks", numbered(1, 12), "1\nx\n" + numbered(3, 10) + "12\n",
+			"--- a\n+++ b\n@@ -1,4 +1,4 @@\n 1\n-2\n+x\n 3\n 4\n@@ -9,4 +9,3 @@\n 9\n 10\n-11\n 12\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := UnifiedDiff("a", "b", tc.a, tc.b, 2); got != tc.want {
+				t.Fatalf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
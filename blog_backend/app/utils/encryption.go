@@ -1,6 +1,11 @@
 package utils
 
 import (
+	"blog_backend/app/keyring"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -24,56 +29,102 @@ func CheckPassword(password, hasedPassword string) bool {
 }
 
 type CustomClaims struct {
-	UserID int    `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    int    `json:"user_id"`
+	Email     string `json:"email"`
+	SessionID string `json:"sid"`
+	Role      string `json:"role"`
+	// EmailVerified is false for users who have not verified their email
+	// address, and for tokens issued before that was recorded
+	EmailVerified bool `json:"email_verified"`
 	jwt.RegisteredClaims
 }
 
-func CreateJWTToken(secretKey string, userId int, email string) (string, error) {
+// CreateJWTToken signs an access token with key, naming it in the kid
+// header so that verifiers pick the matching public key.
+func CreateJWTToken(key *keyring.Key, issuer, audience string, userId int, email, role, sessionID string,
+	emailVerified bool, ttl time.Duration) (string, error) {
+	now := time.Now()
 	claims := CustomClaims{
-		UserID: userId,
-		Email:  email,
+		UserID:        userId,
+		Email:         email,
+		SessionID:     sessionID,
+		Role:          role,
+		EmailVerified: emailVerified,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "Colin's Blog",
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
 			Subject:   "user-auth",
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, err := token.SignedString([]byte(secretKey))
+	token := jwt.NewWithClaims(key.SigningMethod(), claims)
+	token.Header["kid"] = key.ID
+	tokenStr, err := token.SignedString(key.Private)
 	if err != nil {
 		return "", err
 	}
 	return tokenStr, nil
 }
 
-func VerifyJWTToken(secretKey string, tokenStr string) (*CustomClaims, error) {
-	fmt.Println("Verifying JWT Token:", tokenStr)
-
-	// Verity the signing method
+// VerifyJWTToken checks an access token against the key of keys its kid
+// names, and that it was issued by issuer for audience.
+func VerifyJWTToken(keys *keyring.Keyring, issuer, audience, tokenStr string) (*CustomClaims, error) {
 	token, err := jwt.ParseWithClaims(
 		tokenStr,
 		&CustomClaims{},
 		func(token *jwt.Token) (any, error) {
-			// Ensure the token's signing method is HMAC
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			kid, _ := token.Header["kid"].(string)
+			key, ok := keys.Lookup(kid)
+			if !ok {
+				return nil, fmt.Errorf("unknown signing key %q", kid)
+			}
+			// The key only verifies the algorithm it signs with
+			if token.Method.Alg() != key.Algorithm {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
-			return []byte(secretKey), nil
+			return key.Private.Public(), nil
 		},
+		jwt.WithValidMethods([]string{keyring.RS256, keyring.EdDSA}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
 	)
 	if err != nil {
-		fmt.Println("Error parsing JWT Token:", err)
 		return nil, err
 	}
 	// Verify the Claims
 	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-		fmt.Println("JWT Token is valid")
 		return claims, nil
 	} else {
-		fmt.Println("JWT Token is invalid")
 		return nil, fmt.Errorf("invalid token")
 	}
 }
+
+// GenerateOpaqueToken returns a random URL-safe token together with the
+// SHA-256 hash that should be persisted in its place.
+func GenerateOpaqueToken() (token string, tokenHash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken hashes an opaque token for storage and lookup. Opaque tokens are
+// already high-entropy, so a fast hash is sufficient here.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateSessionID returns a random identifier for a refresh token family.
+func GenerateSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -3,26 +3,80 @@ package main
 import (
 	"blog_backend/app"
 	"blog_backend/app/config"
+	"blog_backend/app/logging"
+	"flag"
 	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/gin-gonic/gin"
 )
 
+const usage = `Usage:
+  blog_backend [flags]                          run the server
+  blog_backend config print [--redacted] [flags]  print the effective configuration
+
+Run with -h to list the configuration flags.
+`
+
 func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "config" {
+		configCommand(args[1:])
+		return
+	}
+
 	// Load configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := config.Load(args)
 	if err != nil {
 		fmt.Printf("Error loading config: %v\n", err)
-		return
+		os.Exit(1)
+	}
+
+	logger, err := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		fmt.Printf("Error creating logger: %v\n", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+	// gin prints its routes in debug mode; log them at debug level instead
+	gin.DebugPrintRouteFunc = func(method, path, handler string, _ int) {
+		logger.Debug("route registered", "method", method, "path", path, "handler", handler)
 	}
 
 	// Initialize the application
-	appInstance, err := app.NewApp(cfg)
+	appInstance, err := app.NewApp(cfg, logger)
 	if err != nil {
-		fmt.Printf("Error initializing app: %v\n", err)
-		return
+		logger.Error("failed to initialize app", "error", err)
+		os.Exit(1)
 	}
 
 	// Run the application
 	if err := appInstance.Run(cfg.ServerPort); err != nil {
-		fmt.Printf("Error running app: %v\n", err)
+		logger.Error("failed to run app", "error", err)
+		os.Exit(1)
+	}
+}
+
+func configCommand(args []string) {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("config print", flag.ExitOnError)
+	redact := fs.Bool("redacted", false, "hide secrets")
+	loader := config.NewLoader(fs)
+	fs.Parse(args[1:])
+
+	cfg, err := loader.Load()
+	if cfg != nil {
+		if printErr := config.Print(os.Stdout, cfg, *redact); printErr != nil {
+			fmt.Fprintln(os.Stderr, printErr)
+			os.Exit(1)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
 	}
 }
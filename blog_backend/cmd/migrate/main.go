@@ -2,29 +2,144 @@ package main
 
 import (
 	"blog_backend/app/config"
+	"blog_backend/app/logging"
 	"blog_backend/app/utils"
 	"blog_backend/migrations"
+	"flag"
 	"fmt"
+	"os"
+	"strconv"
+	"time"
 )
 
+const usage = `Usage: migrate <command> [config flags] [arguments]
+
+Commands:
+  up                    apply all pending migrations (default)
+  down [N]              roll back the last N migrations (default 1)
+  status                list migrations and whether they are applied
+  create [-go] <name>   create a new SQL (or Go) migration in ./migrations
+  force <version>       record the schema as migrated up to version, 0 for none
+`
+
 func main() {
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		fmt.Printf("Error loading config: %v\n", err)
+	args := os.Args[1:]
+	command := "up"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "create":
+		// create only writes files and needs no database
+		create(args)
 		return
+	case "up", "down", "status", "force":
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
 	}
 
-	// Create the tables
-	db, err := utils.InitDatabase(cfg)
+	// Load configuration; the command's own arguments follow the flags
+	fs := flag.NewFlagSet("migrate "+command, flag.ExitOnError)
+	loader := config.NewLoader(fs)
+	fs.Parse(args)
+	args = fs.Args()
+	cfg, err := loader.Load()
 	if err != nil {
-		fmt.Printf("Error initializing database: %v\n", err)
-		return
+		fail("Error loading config: %v", err)
 	}
-	if err := migrations.InitTables(db); err != nil {
-		fmt.Printf("Error initializing tables: %v\n", err)
-		return
+
+	// Logs go to stderr, apart from the command's own output
+	logger, err := logging.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		fail("Error creating logger: %v", err)
+	}
+	db, err := utils.InitDatabase(cfg, logger)
+	if err != nil {
+		fail("Error initializing database: %v", err)
+	}
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		fail("Error loading migrations: %v", err)
 	}
-	fmt.Println("Database migration completed successfully.")
 
+	switch command {
+	case "up":
+		applied, err := migrator.Up()
+		printMigrations("Applied", applied)
+		if err != nil {
+			fail("Error applying migrations: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date.")
+		}
+	case "down":
+		n := 1
+		if len(args) > 0 {
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				fail("down expects a positive number of migrations, got %q", args[0])
+			}
+		}
+		reverted, err := migrator.Down(n)
+		printMigrations("Rolled back", reverted)
+		if err != nil {
+			fail("Error rolling back migrations: %v", err)
+		}
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			fail("Error reading migration status: %v", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Dirty {
+				state = "DIRTY"
+			} else if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%d  %-40s %s\n", s.Version, s.Name, state)
+		}
+	case "force":
+		if len(args) != 1 {
+			fail("force expects a version")
+		}
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			fail("invalid version %q", args[0])
+		}
+		if err := migrator.Force(version); err != nil {
+			fail("Error forcing version: %v", err)
+		}
+		fmt.Printf("Schema recorded at version %d.\n", version)
+	}
+}
+
+func create(args []string) {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	asGo := flags.Bool("go", false, "write a Go migration instead of SQL files")
+	dir := flags.String("dir", "migrations", "directory of the migrations package")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	files, err := migrations.Create(*dir, flags.Arg(0), *asGo, time.Now())
+	if err != nil {
+		fail("Error creating migration: %v", err)
+	}
+	for _, file := range files {
+		fmt.Println("Created", file)
+	}
+}
+
+func printMigrations(verb string, list []*migrations.Migration) {
+	for _, m := range list {
+		fmt.Printf("%s %d_%s\n", verb, m.Version, m.Name)
+	}
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
 }
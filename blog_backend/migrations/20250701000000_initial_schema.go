@@ -0,0 +1,275 @@
+package migrations
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The initial schema is the one AutoMigrate used to build. The models are
+// frozen copies so that later changes to app/models only reach the database
+// through new migrations. Every step is idempotent, which lets databases set
+// up by AutoMigrate adopt the migration history by simply running it.
+
+type v1User struct {
+	ID            int         `gorm:"primaryKey"`
+	Username      string      `gorm:"size:100;not null"`
+	Password      string      `gorm:"size:100;not null"`
+	Email         string      `gorm:"size:100;not null;unique"`
+	Role          string      `gorm:"size:20;not null;default:user"`
+	NumberOfPosts int         `gorm:"default:0"`
+	CreatedAt     time.Time   `gorm:"autoCreateTime;not null"`
+	UpdatedAt     time.Time   `gorm:"autoUpdateTime;not null"`
+	Posts         []v1Post    `gorm:"foreignKey:UserID"`
+	Comments      []v1Comment `gorm:"foreignKey:UserID"`
+}
+
+func (v1User) TableName() string { return "users" }
+
+type v1Category struct {
+	ID        int          `gorm:"primaryKey"`
+	Name      string       `gorm:"size:100;not null"`
+	Slug      string       `gorm:"size:100;not null;uniqueIndex"`
+	ParentID  *int         `gorm:"index"`
+	Parent    *v1Category  `gorm:"foreignKey:ParentID"`
+	Children  []v1Category `gorm:"foreignKey:ParentID"`
+	CreatedAt time.Time    `gorm:"autoCreateTime;not null"`
+}
+
+func (v1Category) TableName() string { return "categories" }
+
+type v1Tag struct {
+	ID        int       `gorm:"primaryKey"`
+	Name      string    `gorm:"size:50;not null"`
+	Slug      string    `gorm:"size:50;not null;uniqueIndex"`
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+}
+
+func (v1Tag) TableName() string { return "tags" }
+
+type v1PostTag struct {
+	PostID    int       `gorm:"primaryKey"`
+	TagID     int       `gorm:"primaryKey;index"`
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+}
+
+func (v1PostTag) TableName() string { return "post_tags" }
+
+type v1Post struct {
+	ID          int         `gorm:"primaryKey"`
+	Title       string      `gorm:"size:200;not null"`
+	Content     string      `gorm:"type:text;not null"`
+	UserID      int         `gorm:"not null"`
+	User        v1User      `gorm:"foreignKey:UserID"`
+	CategoryID  *int        `gorm:"index"`
+	Category    *v1Category `gorm:"foreignKey:CategoryID"`
+	Tags        []v1Tag     `gorm:"many2many:post_tags;joinForeignKey:PostID;joinReferences:TagID"`
+	Comments    []v1Comment `gorm:"foreignKey:PostID"`
+	Status      string      `gorm:"size:20;not null;default:published;index"`
+	PublishAt   *time.Time  `gorm:"index"`
+	PublishedAt *time.Time
+	CreatedAt   time.Time `gorm:"autoCreateTime;not null"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime;not null"`
+}
+
+func (v1Post) TableName() string { return "posts" }
+
+type v1Comment struct {
+	ID        int        `gorm:"primaryKey"`
+	Content   string     `gorm:"type:text;not null"`
+	UserID    int        `gorm:"not null"`
+	User      v1User     `gorm:"foreignKey:UserID"`
+	PostID    int        `gorm:"not null"`
+	Post      v1Post     `gorm:"foreignKey:PostID"`
+	ParentID  *int       `gorm:"index"`
+	Parent    *v1Comment `gorm:"foreignKey:ParentID"`
+	Depth     int        `gorm:"not null;default:0"`
+	Path      string     `gorm:"size:255;not null;default:'';index"`
+	DeletedAt *time.Time `gorm:"index"`
+	CreatedAt time.Time  `gorm:"autoCreateTime;not null"`
+}
+
+func (v1Comment) TableName() string { return "comments" }
+
+type v1RefreshToken struct {
+	ID        int        `gorm:"primaryKey"`
+	UserID    int        `gorm:"not null;index"`
+	User      v1User     `gorm:"foreignKey:UserID"`
+	FamilyID  string     `gorm:"size:64;not null;index"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	RevokedAt *time.Time `gorm:"index"`
+	CreatedAt time.Time  `gorm:"autoCreateTime;not null"`
+}
+
+func (v1RefreshToken) TableName() string { return "refresh_tokens" }
+
+type v1LoginAttempt struct {
+	ID           int       `gorm:"primaryKey"`
+	Scope        string    `gorm:"size:20;not null;uniqueIndex:idx_login_attempt_scope_identifier"`
+	Identifier   string    `gorm:"size:255;not null;uniqueIndex:idx_login_attempt_scope_identifier"`
+	FailedCount  int       `gorm:"not null;default:0"`
+	LastFailedAt time.Time `gorm:"not null"`
+	LockedUntil  *time.Time
+}
+
+func (v1LoginAttempt) TableName() string { return "login_attempts" }
+
+type v1PostRevision struct {
+	ID        int       `gorm:"primaryKey"`
+	PostID    int       `gorm:"not null;uniqueIndex:idx_post_revision"`
+	Revision  int       `gorm:"not null;uniqueIndex:idx_post_revision"`
+	Title     string    `gorm:"size:200;not null"`
+	Content   string    `gorm:"type:text;not null"`
+	EditorID  int       `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+}
+
+func (v1PostRevision) TableName() string { return "post_revisions" }
+
+type v1CommentRevision struct {
+	ID        int       `gorm:"primaryKey"`
+	CommentID int       `gorm:"not null;uniqueIndex:idx_comment_revision"`
+	Revision  int       `gorm:"not null;uniqueIndex:idx_comment_revision"`
+	Content   string    `gorm:"type:text;not null"`
+	EditorID  int       `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+}
+
+func (v1CommentRevision) TableName() string { return "comment_revisions" }
+
+var postgresSearchStatements = []string{
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (
+			setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+			setweight(to_tsvector('english', coalesce(content, '')), 'B')
+		) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING GIN (search_vector)`,
+	`ALTER TABLE comments ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('english', coalesce(content, ''))) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_comments_search_vector ON comments USING GIN (search_vector)`,
+}
+
+// The FTS5 tables are external-content indexes kept in sync by triggers.
+var sqliteSearchStatements = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(title, content, content='posts', content_rowid='id')`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_insert AFTER INSERT ON posts BEGIN
+		INSERT INTO posts_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_delete AFTER DELETE ON posts BEGIN
+		INSERT INTO posts_fts(posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_update AFTER UPDATE ON posts BEGIN
+		INSERT INTO posts_fts(posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+		INSERT INTO posts_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+	END`,
+	`INSERT INTO posts_fts(posts_fts) VALUES ('rebuild')`,
+	`CREATE VIRTUAL TABLE IF NOT EXISTS comments_fts USING fts5(content, content='comments', content_rowid='id')`,
+	`CREATE TRIGGER IF NOT EXISTS comments_fts_insert AFTER INSERT ON comments BEGIN
+		INSERT INTO comments_fts(rowid, content) VALUES (new.id, new.content);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS comments_fts_delete AFTER DELETE ON comments BEGIN
+		INSERT INTO comments_fts(comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS comments_fts_update AFTER UPDATE ON comments BEGIN
+		INSERT INTO comments_fts(comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+		INSERT INTO comments_fts(rowid, content) VALUES (new.id, new.content);
+	END`,
+	`INSERT INTO comments_fts(comments_fts) VALUES ('rebuild')`,
+}
+
+var sqliteSearchDropStatements = []string{
+	`DROP TABLE IF EXISTS posts_fts`,
+	`DROP TABLE IF EXISTS comments_fts`,
+}
+
+func init() {
+	register(&Migration{
+		Version: 20250701000000,
+		Name:    "initial_schema",
+		Up:      initialSchemaUp,
+		Down:    initialSchemaDown,
+	})
+}
+
+func initialSchemaUp(tx *gorm.DB) error {
+	// Use our own join model so post_tags gets created_at and a tag_id index
+	if err := tx.SetupJoinTable(&v1Post{}, "Tags", &v1PostTag{}); err != nil {
+		return err
+	}
+	err := tx.AutoMigrate(
+		&v1User{},
+		&v1Category{},
+		&v1Tag{},
+		&v1Post{},
+		&v1Comment{},
+		&v1RefreshToken{},
+		&v1LoginAttempt{},
+		&v1PostRevision{},
+		&v1CommentRevision{},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Posts created before the lifecycle existed were public from the start
+	err = tx.Table("posts").
+		Where("status = ? AND published_at IS NULL", "published").
+		UpdateColumn("published_at", gorm.Expr("created_at")).Error
+	if err != nil {
+		return err
+	}
+
+	// Comments created before threading are top-level comments
+	var ids []int
+	if err := tx.Table("comments").Where("path = ''").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := tx.Table("comments").Where("id = ?", id).UpdateColumn("path", fmt.Sprintf("%010d", id)).Error; err != nil {
+			return err
+		}
+	}
+
+	return execAll(tx, searchStatements(tx, postgresSearchStatements, sqliteSearchStatements))
+}
+
+func initialSchemaDown(tx *gorm.DB) error {
+	if err := execAll(tx, searchStatements(tx, nil, sqliteSearchDropStatements)); err != nil {
+		return err
+	}
+	return tx.Migrator().DropTable(
+		"comment_revisions",
+		"post_revisions",
+		"login_attempts",
+		"refresh_tokens",
+		"comments",
+		"post_tags",
+		"posts",
+		"tags",
+		"categories",
+		"users",
+	)
+}
+
+// searchStatements picks the full-text search statements of the current
+// database. Other databases go without full-text search.
+func searchStatements(tx *gorm.DB, postgres, sqlite []string) []string {
+	switch tx.Dialector.Name() {
+	case "postgres":
+		return postgres
+	case "sqlite":
+		return sqlite
+	}
+	return nil
+}
+
+func execAll(tx *gorm.DB, statements []string) error {
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to update full-text search: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// MySQL gets FULLTEXT indexes for search. The other databases got their
+// full-text search in the initial schema.
+func init() {
+	register(&Migration{
+		Version: 20250712000000,
+		Name:    "mysql_fulltext",
+		Up: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() != "mysql" {
+				return nil
+			}
+			return execAll(tx, []string{
+				`ALTER TABLE posts ADD FULLTEXT INDEX ft_posts_title_content (title, content)`,
+				`ALTER TABLE posts ADD FULLTEXT INDEX ft_posts_title (title)`,
+				`ALTER TABLE comments ADD FULLTEXT INDEX ft_comments_content (content)`,
+			})
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() != "mysql" {
+				return nil
+			}
+			return execAll(tx, []string{
+				`ALTER TABLE comments DROP INDEX ft_comments_content`,
+				`ALTER TABLE posts DROP INDEX ft_posts_title`,
+				`ALTER TABLE posts DROP INDEX ft_posts_title_content`,
+			})
+		},
+	})
+}
@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Users verify their email address, and get single-use tokens by email to
+// do so or to reset their password. Accounts that existed before count as
+// verified, so their owners keep what they could do.
+
+type v2User struct {
+	ID              int `gorm:"primaryKey"`
+	EmailVerifiedAt *time.Time
+}
+
+func (v2User) TableName() string { return "users" }
+
+type v2UserToken struct {
+	ID        int       `gorm:"primaryKey"`
+	UserID    int       `gorm:"not null;index"`
+	User      v1User    `gorm:"foreignKey:UserID"`
+	Purpose   string    `gorm:"size:20;not null"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+}
+
+func (v2UserToken) TableName() string { return "user_tokens" }
+
+func init() {
+	register(&Migration{
+		Version: 20261018120000,
+		Name:    "email_verification",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&v2User{}, "EmailVerifiedAt") {
+				if err := tx.Migrator().AddColumn(&v2User{}, "EmailVerifiedAt"); err != nil {
+					return err
+				}
+				err := tx.Table("users").
+					Where("email_verified_at IS NULL").
+					UpdateColumn("email_verified_at", gorm.Expr("created_at")).Error
+				if err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&v2UserToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable("user_tokens"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&v2User{}, "EmailVerifiedAt")
+		},
+	})
+}
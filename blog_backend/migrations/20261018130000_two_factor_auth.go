@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Users can protect their login with the codes of an authenticator app, and
+// keep one-time recovery codes for when they lose it.
+
+type v3User struct {
+	ID            int    `gorm:"primaryKey"`
+	TOTPSecret    string `gorm:"size:64"`
+	TOTPEnabledAt *time.Time
+	TOTPLastStep  int64 `gorm:"not null;default:0"`
+}
+
+func (v3User) TableName() string { return "users" }
+
+type v3RecoveryCode struct {
+	ID        int    `gorm:"primaryKey"`
+	UserID    int    `gorm:"not null;index"`
+	User      v1User `gorm:"foreignKey:UserID"`
+	CodeHash  string `gorm:"size:64;not null;uniqueIndex"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+}
+
+func (v3RecoveryCode) TableName() string { return "recovery_codes" }
+
+var v3UserColumns = []string{"TOTPSecret", "TOTPEnabledAt", "TOTPLastStep"}
+
+func init() {
+	register(&Migration{
+		Version: 20261018130000,
+		Name:    "two_factor_auth",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range v3UserColumns {
+				if tx.Migrator().HasColumn(&v3User{}, column) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&v3User{}, column); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&v3RecoveryCode{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable("recovery_codes"); err != nil {
+				return err
+			}
+			for _, column := range v3UserColumns {
+				if err := tx.Migrator().DropColumn(&v3User{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Users can sign in with an Ethereum wallet, which proves it controls an
+// address by signing a message carrying a nonce from the server.
+
+type v4User struct {
+	ID            int     `gorm:"primaryKey"`
+	WalletAddress *string `gorm:"size:42;uniqueIndex"`
+}
+
+func (v4User) TableName() string { return "users" }
+
+type v4SIWENonce struct {
+	ID        int       `gorm:"primaryKey"`
+	NonceHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime;not null"`
+}
+
+func (v4SIWENonce) TableName() string { return "siwe_nonces" }
+
+func init() {
+	register(&Migration{
+		Version: 20261018140000,
+		Name:    "sign_in_with_ethereum",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&v4User{}, "WalletAddress") {
+				if err := tx.Migrator().AddColumn(&v4User{}, "WalletAddress"); err != nil {
+					return err
+				}
+			}
+			if !tx.Migrator().HasIndex(&v4User{}, "WalletAddress") {
+				if err := tx.Migrator().CreateIndex(&v4User{}, "WalletAddress"); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&v4SIWENonce{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable("siwe_nonces"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropIndex(&v4User{}, "WalletAddress"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&v4User{}, "WalletAddress")
+		},
+	})
+}
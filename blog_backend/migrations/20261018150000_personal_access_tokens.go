@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Users can create personal access tokens, so that scripts use the API
+// within some scopes without their password.
+
+type v5PersonalAccessToken struct {
+	ID         int    `gorm:"primaryKey"`
+	UserID     int    `gorm:"not null;index"`
+	User       v1User `gorm:"foreignKey:UserID"`
+	Name       string `gorm:"size:100;not null"`
+	TokenHash  string `gorm:"size:64;not null;uniqueIndex"`
+	Scopes     string `gorm:"size:100;not null"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time `gorm:"autoCreateTime;not null"`
+}
+
+func (v5PersonalAccessToken) TableName() string { return "personal_access_tokens" }
+
+func init() {
+	register(&Migration{
+		Version: 20261018150000,
+		Name:    "personal_access_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v5PersonalAccessToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("personal_access_tokens")
+		},
+	})
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Access tokens are signed with rotated key pairs instead of a shared
+// secret, so that other services verify them with the public keys.
+
+type v6SigningKey struct {
+	ID          int       `gorm:"primaryKey"`
+	KID         string    `gorm:"column:kid;size:64;not null;uniqueIndex"`
+	Algorithm   string    `gorm:"size:10;not null"`
+	PrivateKey  string    `gorm:"type:text;not null"`
+	ActivatesAt time.Time `gorm:"not null;index"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;not null"`
+}
+
+func (v6SigningKey) TableName() string { return "signing_keys" }
+
+func init() {
+	register(&Migration{
+		Version: 20261018160000,
+		Name:    "signing_keys",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v6SigningKey{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("signing_keys")
+		},
+	})
+}
@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const versionLayout = "20060102150405"
+
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+var goTemplate = template.Must(template.New("migration").Parse(`package migrations
+
+import "gorm.io/gorm"
+
+func init() {
+	register(&Migration{
+		Version: {{.Version}},
+		Name:    "{{.Name}}",
+		Up: func(tx *gorm.DB) error {
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
+}
+`))
+
+// Create writes the files of a new migration into dir, the migrations package
+// directory, and returns their paths. It writes an up and a down SQL file, or
+// a single Go file when asGo is set.
+func Create(dir, name string, asGo bool, now time.Time) ([]string, error) {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
+	if !migrationName.MatchString(name) {
+		return nil, fmt.Errorf("invalid migration name %q: use letters, digits and underscores", name)
+	}
+	version := now.UTC().Format(versionLayout)
+	base := version + "_" + name
+
+	if asGo {
+		file := filepath.Join(dir, base+".go")
+		var out strings.Builder
+		if err := goTemplate.Execute(&out, map[string]string{"Version": version, "Name": name}); err != nil {
+			return nil, err
+		}
+		if err := writeNewFile(file, out.String()); err != nil {
+			return nil, err
+		}
+		return []string{file}, nil
+	}
+
+	files := []string{
+		filepath.Join(dir, "sql", base+".up.sql"),
+		filepath.Join(dir, "sql", base+".down.sql"),
+	}
+	for _, file := range files {
+		if err := writeNewFile(file, "-- "+filepath.Base(file)+"\n"); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func writeNewFile(file, content string) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", file, err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,125 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one versioned schema change. Versions are UTC timestamps
+// (YYYYMMDDHHMMSS) so migrations written on different branches rarely
+// collide, and they are applied in ascending order.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// goMigrations holds the migrations written in Go. Each one registers itself
+// from an init function in its own file.
+var goMigrations []*Migration
+
+func register(m *Migration) {
+	goMigrations = append(goMigrations, m)
+}
+
+// SQL migrations live in sql/ as <version>_<name>.up.sql and
+// <version>_<name>.down.sql. A file named <version>_<name>.up.<dialect>.sql
+// replaces the generic one on that dialect.
+//
+//go:embed sql
+var sqlFiles embed.FS
+
+var sqlFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)(?:\.(\w+))?\.sql$`)
+
+// loadMigrations returns every Go and SQL migration sorted by version, with
+// the SQL ones resolved for dialect.
+func loadMigrations(dialect string) ([]*Migration, error) {
+	all := append([]*Migration{}, goMigrations...)
+	sqlMigrations, err := loadSQLMigrations(sqlFiles, "sql", dialect)
+	if err != nil {
+		return nil, err
+	}
+	all = append(all, sqlMigrations...)
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	for i := 1; i < len(all); i++ {
+		if all[i].Version == all[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d (%s, %s)", all[i].Version, all[i-1].Name, all[i].Name)
+		}
+	}
+	return all, nil
+}
+
+func loadSQLMigrations(fsys fs.FS, dir, dialect string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SQL migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	// Dialect-specific files win over generic ones whatever order they come in
+	specific := map[string]bool{}
+	for _, entry := range entries {
+		match := sqlFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		name, direction, fileDialect := match[2], match[3], match[4]
+		if fileDialect != "" && fileDialect != dialect {
+			continue
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, m.Name, name)
+		}
+
+		key := match[1] + "." + direction
+		if specific[key] {
+			continue
+		}
+		specific[key] = fileDialect != ""
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+		if direction == "up" {
+			m.Up = execSQL(string(content))
+		} else {
+			m.Down = execSQL(string(content))
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	return migrations, nil
+}
+
+// execSQL runs a whole SQL file as one Exec. Files holding several
+// statements need a driver that accepts them, which MySQL only does with
+// multiStatements=true in its DSN.
+func execSQL(sql string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Exec(sql).Error
+	}
+}
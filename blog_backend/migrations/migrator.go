@@ -0,0 +1,335 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockKey identifies the migration lock among the advisory locks of the
+// database; any constant unlikely to clash with other applications works.
+const (
+	lockKey  int64 = 0x626c6f675f6d6967 // "blog_mig"
+	lockName       = "blog_backend_migrations"
+)
+
+var (
+	ErrNoMigration = errors.New("no such migration")
+	ErrPending     = errors.New("migration not applied")
+)
+
+// DirtyError reports a migration that failed halfway on a database without
+// transactional DDL. The schema has to be repaired by hand and the version
+// recorded with Force before migrating again.
+type DirtyError struct {
+	Version int64
+}
+
+func (e *DirtyError) Error() string {
+	return fmt.Sprintf("database is dirty at version %d, fix the schema and run force", e.Version)
+}
+
+// schemaMigration records one applied migration.
+type schemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	Dirty     bool      `gorm:"not null;default:false"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus tells whether a known migration has been applied.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	Dirty     bool
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []*Migration
+}
+
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up applies every pending migration in version order, including older ones
+// merged after newer ones were applied.
+func (m *Migrator) Up() ([]*Migration, error) {
+	var done []*Migration
+	err := m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(conn, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the n most recently applied migrations.
+func (m *Migrator) Down(n int) ([]*Migration, error) {
+	var done []*Migration
+	err := m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+		if n > len(versions) {
+			n = len(versions)
+		}
+
+		for _, version := range versions[:n] {
+			migration := m.find(version)
+			if migration == nil {
+				return fmt.Errorf("applied migration %d is unknown to this build: %w", version, ErrNoMigration)
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("migration %d_%s cannot be rolled back", migration.Version, migration.Name)
+			}
+			if err := m.revert(conn, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists every known migration and any applied one missing from this
+// build.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	if err := m.db.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	var rows []schemaMigration
+	if err := m.db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	recorded := make(map[int64]schemaMigration, len(rows))
+	for _, row := range rows {
+		recorded[row.Version] = row
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := recorded[migration.Version]; ok {
+			status.Applied = true
+			status.Dirty = row.Dirty
+			status.AppliedAt = &row.AppliedAt
+			delete(recorded, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range recorded {
+		statuses = append(statuses, MigrationStatus{
+			Version: row.Version, Name: row.Name, Applied: true, Dirty: row.Dirty, AppliedAt: &row.AppliedAt,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// CheckCurrent reports an error unless every migration of this build has
+// been applied cleanly. Migrations this build does not know, applied by a
+// newer release, are fine, so old instances stay up during a rolling update.
+// Unlike Status it only reads.
+func (m *Migrator) CheckCurrent(ctx context.Context) error {
+	var rows []schemaMigration
+	if err := m.db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	applied := make(map[int64]bool, len(rows))
+	for _, row := range rows {
+		if row.Dirty {
+			return &DirtyError{Version: row.Version}
+		}
+		applied[row.Version] = true
+	}
+	for _, migration := range m.migrations {
+		if !applied[migration.Version] {
+			return fmt.Errorf("%d_%s: %w", migration.Version, migration.Name, ErrPending)
+		}
+	}
+	return nil
+}
+
+// Force records the schema as migrated exactly up to version without running
+// anything: migrations up to it are marked applied and clean, later ones
+// unapplied. Version 0 marks everything unapplied.
+func (m *Migrator) Force(version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("force version %d: %w", version, ErrNoMigration)
+	}
+	return m.withLock(func(conn *gorm.DB) error {
+		return conn.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("version > ?", version).Delete(&schemaMigration{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&schemaMigration{}).Where("dirty = ?", true).Update("dirty", false).Error; err != nil {
+				return err
+			}
+			var recorded []int64
+			if err := tx.Model(&schemaMigration{}).Pluck("version", &recorded).Error; err != nil {
+				return err
+			}
+			seen := make(map[int64]bool, len(recorded))
+			for _, v := range recorded {
+				seen[v] = true
+			}
+			for _, migration := range m.migrations {
+				if migration.Version > version || seen[migration.Version] {
+					continue
+				}
+				row := &schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}
+				if err := tx.Create(row).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// applied returns the recorded migrations, refusing to go on while one of
+// them is dirty.
+func (m *Migrator) applied(conn *gorm.DB) (map[int64]schemaMigration, error) {
+	var rows []schemaMigration
+	if err := conn.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	applied := make(map[int64]schemaMigration, len(rows))
+	for _, row := range rows {
+		if row.Dirty {
+			return nil, &DirtyError{Version: row.Version}
+		}
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// apply runs migration in a transaction. Its row is written as dirty first so
+// that a failure on a database without transactional DDL stays visible.
+func (m *Migrator) apply(conn *gorm.DB, migration *Migration) error {
+	row := &schemaMigration{Version: migration.Version, Name: migration.Name, Dirty: true, AppliedAt: time.Now()}
+	if err := conn.Create(row).Error; err != nil {
+		return fmt.Errorf("failed to record migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Model(row).Update("dirty", false).Error
+	})
+	if err == nil {
+		return nil
+	}
+	if m.transactionalDDL() {
+		// Everything was rolled back, so the migration simply did not happen
+		conn.Delete(row)
+	}
+	return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+}
+
+func (m *Migrator) revert(conn *gorm.DB, migration *Migration) error {
+	row := &schemaMigration{Version: migration.Version}
+	if err := conn.Model(row).Update("dirty", true).Error; err != nil {
+		return fmt.Errorf("failed to record rollback of %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(row).Error
+	})
+	if err == nil {
+		return nil
+	}
+	if m.transactionalDDL() {
+		conn.Model(row).Update("dirty", false)
+	}
+	return fmt.Errorf("rollback of %d_%s failed: %w", migration.Version, migration.Name, err)
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) transactionalDDL() bool {
+	switch m.db.Dialector.Name() {
+	case "postgres", "sqlite":
+		return true
+	}
+	return false
+}
+
+// withLock runs fn on a single connection holding the migration lock, so that
+// concurrent deploys migrate one after the other. SQLite needs no lock of its
+// own as it only allows one writer at a time.
+func (m *Migrator) withLock(fn func(conn *gorm.DB) error) error {
+	return m.db.Connection(func(conn *gorm.DB) (err error) {
+		// Start every statement from the pinned connection, not from the last one
+		conn = conn.Session(&gorm.Session{})
+		switch m.db.Dialector.Name() {
+		case "postgres":
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+				return fmt.Errorf("failed to acquire migration lock: %w", err)
+			}
+			defer func() {
+				if unlockErr := conn.Exec("SELECT pg_advisory_unlock(?)", lockKey).Error; err == nil && unlockErr != nil {
+					err = fmt.Errorf("failed to release migration lock: %w", unlockErr)
+				}
+			}()
+		case "mysql":
+			var acquired int
+			if err := conn.Raw("SELECT GET_LOCK(?, -1)", lockName).Scan(&acquired).Error; err != nil || acquired != 1 {
+				return fmt.Errorf("failed to acquire migration lock: %v", err)
+			}
+			defer func() {
+				if unlockErr := conn.Exec("SELECT RELEASE_LOCK(?)", lockName).Error; err == nil && unlockErr != nil {
+					err = fmt.Errorf("failed to release migration lock: %w", unlockErr)
+				}
+			}()
+		}
+
+		if err := conn.AutoMigrate(&schemaMigration{}); err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+		return fn(conn)
+	})
+}
@@ -0,0 +1,2 @@
+-- Comments are always listed per post
+CREATE INDEX idx_comments_post_id ON comments (post_id);